@@ -0,0 +1,33 @@
+package config
+
+import (
+	"log"
+	"net/url"
+	"strings"
+)
+
+// normalizeBaseURLs 去掉模型 base_url 末尾的"/"，检查 openai 类型的 base_url 是否缺少 /v1 或者多写了接口路径，
+// 这两种错误都会导致请求返回难以排查的404。设置了 chat_path 的模型由用户自行决定完整路径，不做检查
+func (c *Config) normalizeBaseURLs() {
+	for i := range c.Models {
+		model := &c.Models[i]
+		model.BaseURL = strings.TrimRight(strings.TrimSpace(model.BaseURL), "/")
+		if model.Type != "openai" || model.BaseURL == "" || model.ChatPath != "" {
+			continue
+		}
+		parsed, err := url.Parse(model.BaseURL)
+		if err != nil || parsed.Host == "" {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(parsed.Path, "/chat/completions"):
+			log.Printf("警告: 模型 %s 的 base_url 已包含 /chat/completions，请求将发送到 %s/chat/completions，base_url 应只包含 /v1 等接口前缀",
+				model.Name, model.BaseURL)
+		case parsed.Path == "" && model.AppendV1:
+			model.BaseURL += "/v1"
+		case parsed.Path == "":
+			log.Printf("警告: 模型 %s 的 base_url 没有包含 /v1，请求将发送到 %s/chat/completions；如果服务端的接口以 /v1 开头，请设置 append_v1: true 自动追加",
+				model.Name, model.BaseURL)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigError 配置中的一个问题，Path 为出错的配置项路径，例如 test.concurrency_levels、models[0].params.temperature
+type ConfigError struct {
+	Path string
+	Err  error
+}
+
+func (e ConfigError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigErrors 加载配置时发现的所有问题，按发现的顺序排列
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return fmt.Sprintf("配置中有 %d 个问题:\n  %s", len(e), strings.Join(lines, "\n  "))
+}
+
+func (e ConfigErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// add 记录一个问题
+func (e *ConfigErrors) add(path string, err error) {
+	*e = append(*e, ConfigError{Path: path, Err: err})
+}
+
+// err 没有问题时返回nil，避免返回非nil的空切片
+func (e ConfigErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// yaml.v3 类型错误中每一项的格式
+var (
+	yamlErrorLine    = regexp.MustCompile(`^line (\d+): (.*)$`)
+	yamlUnknownField = regexp.MustCompile(`^field (\S+) not found in type`)
+	yamlTypeMismatch = regexp.MustCompile("^cannot unmarshal !!\\w+ `(.*)` into (\\S+)$")
+)
+
+// decodeStrict 严格解析合并后的配置，配置文件中出现未知的配置项（通常是拼写错误）或者类型不匹配时返回 ConfigErrors，
+// 其中包含所有问题，而不只是第一个
+func decodeStrict(data []byte, config *Config) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(config)
+	var typeErr *yaml.TypeError
+	if err == nil || !errors.As(err, &typeErr) {
+		if err != nil {
+			return fmt.Errorf("解析配置文件失败: %w", err)
+		}
+		return nil
+	}
+
+	// 错误信息中的行号对应合并后的YAML，转换为配置项路径
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	paths := make(map[int]string)
+	collectNodePaths(&root, "", paths)
+
+	var errs ConfigErrors
+	for _, message := range typeErr.Errors {
+		path := ""
+		if match := yamlErrorLine.FindStringSubmatch(message); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			path, message = paths[line], match[2]
+		}
+		if match := yamlUnknownField.FindStringSubmatch(message); match != nil {
+			errs.add(path, fmt.Errorf("未知的配置项 %s", match[1]))
+		} else if match := yamlTypeMismatch.FindStringSubmatch(message); match != nil {
+			errs.add(path, fmt.Errorf("类型错误: %q 不能解析为 %s", match[1], match[2]))
+		} else {
+			errs.add(path, errors.New(message))
+		}
+	}
+	return errs
+}
+
+// collectNodePaths 记录每一行对应的配置项路径，同一行有多个节点时使用最深的路径，
+// 例如"- name: a"所在的行对应 models[0].name 而不是 models[0]
+func collectNodePaths(node *yaml.Node, path string, paths map[int]string) {
+	if path != "" {
+		paths[node.Line] = path
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			collectNodePaths(child, path, paths)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			collectNodePaths(child, fmt.Sprintf("%s[%d]", path, i), paths)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			childPath := key.Value
+			if path != "" {
+				childPath = path + "." + key.Value
+			}
+			paths[key.Line] = childPath
+			collectNodePaths(value, childPath, paths)
+		}
+	}
+}
+
+// paramKinds 内置模型类型读取的参数及其类型，其他参数原样发送或被忽略，不做检查
+var paramKinds = map[string]string{
+	"model":                "string",
+	"model_id":             "string",
+	"region":               "string",
+	"anthropic_version":    "string",
+	"cache_control":        "string",
+	"anthropic_beta":       "strings",
+	"stop":                 "strings",
+	"max_tokens":           "int",
+	"output_tokens":        "int",
+	"error_status":         "int",
+	"temperature":          "float",
+	"latency_ms":           "float",
+	"latency_jitter_ms":    "float",
+	"token_interval_ms":    "float",
+	"error_rate":           "float",
+	"stream_include_usage": "bool",
+}
+
+// validateParams 检查模型参数的类型，避免测试开始后所有请求才因为参数错误失败
+func (m ModelConfig) validateParams(path string, errs *ConfigErrors) {
+	keys := make([]string, 0, len(paramKinds))
+	for key := range paramKinds {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		kind := paramKinds[key]
+		if !m.HasParam(key) {
+			continue
+		}
+		var err error
+		switch kind {
+		case "string":
+			_, err = m.StringParam(key, "")
+		case "strings":
+			_, err = m.StringListParam(key)
+		case "int":
+			_, err = m.IntParam(key, 0)
+		case "float":
+			_, err = m.FloatParam(key, 0)
+		case "bool":
+			if _, ok := m.Params[key].(bool); !ok {
+				err = fmt.Errorf("模型 %s 的 %s 参数必须是布尔值，实际为 %v", m.Name, key, m.Params[key])
+			}
+		}
+		if err != nil {
+			errs.add(path+".params."+key, err)
+		}
+	}
+}
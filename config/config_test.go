@@ -0,0 +1,157 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// loadTestConfig 将YAML写入临时文件并加载
+func loadTestConfig(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return LoadConfig(file)
+}
+
+const minimalModels = `
+models:
+  - name: m
+    type: mock
+prompt:
+  user_message: hi
+`
+
+func TestMaxRetries(t *testing.T) {
+	for _, tc := range []struct {
+		test string
+		want int
+	}{
+		{"test: {}", 0},
+		{"test: {max_retries: 0}", 0},
+		{"test: {max_retries: 2}", 2},
+	} {
+		cfg, err := loadTestConfig(t, tc.test+minimalModels)
+		if err != nil {
+			t.Fatalf("%s: 加载配置失败: %v", tc.test, err)
+		}
+		if cfg.Test.MaxRetries != tc.want {
+			t.Errorf("%s: max_retries 为 %d，期望 %d", tc.test, cfg.Test.MaxRetries, tc.want)
+		}
+	}
+
+	if _, err := loadTestConfig(t, "test: {max_retries: -1}"+minimalModels); err == nil {
+		t.Error("负数的 max_retries 应当报错")
+	}
+}
+
+func TestRequiresAPIKey(t *testing.T) {
+	required, notRequired := true, false
+	for i, tc := range []struct {
+		modelType string
+		override  *bool
+		want      bool
+	}{
+		{"openai", nil, true},
+		{"anthropic", nil, true},
+		{"gemini", nil, true},
+		{"cohere", nil, true},
+		{"ollama", nil, false},
+		{"bedrock", nil, false},
+		{"mock", nil, false},
+		{"my-gateway", nil, false},
+		{"openai", &notRequired, false},
+		{"ollama", &required, true},
+	} {
+		m := ModelConfig{Type: tc.modelType, APIKeyRequired: tc.override}
+		if got := m.RequiresAPIKey(); got != tc.want {
+			t.Errorf("第 %d 项：类型 %s 的 RequiresAPIKey 为 %v，期望 %v", i, tc.modelType, got, tc.want)
+		}
+	}
+}
+
+func TestLatencyPercentiles(t *testing.T) {
+	for _, tc := range []struct {
+		test string
+		want []int
+	}{
+		{"test: {}", []int{50, 90, 95, 99}},
+		{"test: {latency_percentiles: [1, 100]}", []int{1, 100}},
+		{"test: {latency_percentiles: [99, 50, 99]}", []int{99, 50}},
+	} {
+		cfg, err := loadTestConfig(t, tc.test+minimalModels)
+		if err != nil {
+			t.Fatalf("%s: 加载配置失败: %v", tc.test, err)
+		}
+		if !slices.Equal(cfg.Test.LatencyPercentiles, tc.want) {
+			t.Errorf("%s: 百分位为 %v，期望 %v", tc.test, cfg.Test.LatencyPercentiles, tc.want)
+		}
+	}
+
+	for _, invalid := range []string{"0", "101", "-5"} {
+		if _, err := loadTestConfig(t, "test: {latency_percentiles: ["+invalid+"]}"+minimalModels); err == nil {
+			t.Errorf("百分位 %s 超出1到100的范围，应当报错", invalid)
+		}
+	}
+}
+
+func TestApplyHTTPDefaults(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+test:
+  concurrency_levels: [1, 8, 32]
+models:
+  - name: default
+    type: mock
+  - name: limited
+    type: mock
+    max_concurrency: 4
+  - name: levels
+    type: mock
+    concurrency_levels: [200]
+prompt:
+  user_message: hi
+`)
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	cfg.ApplyHTTPDefaults()
+
+	for i, want := range []HTTPConfig{
+		{MaxIdleConns: 100, MaxIdleConnsPerHost: 32, MaxConnsPerHost: 32},
+		{MaxIdleConns: 100, MaxIdleConnsPerHost: 4, MaxConnsPerHost: 4},
+		{MaxIdleConns: 200, MaxIdleConnsPerHost: 200, MaxConnsPerHost: 200},
+	} {
+		got := cfg.Models[i].HTTP
+		if got.MaxIdleConns != want.MaxIdleConns || got.MaxIdleConnsPerHost != want.MaxIdleConnsPerHost || got.MaxConnsPerHost != want.MaxConnsPerHost {
+			t.Errorf("模型 %s 的连接池配置为 %d/%d/%d，期望 %d/%d/%d", cfg.Models[i].Name,
+				got.MaxIdleConns, got.MaxIdleConnsPerHost, got.MaxConnsPerHost,
+				want.MaxIdleConns, want.MaxIdleConnsPerHost, want.MaxConnsPerHost)
+		}
+	}
+}
+
+func TestApplyHTTPDefaultsKeepsExplicitValues(t *testing.T) {
+	for _, tc := range []struct {
+		http string
+		want int
+	}{
+		{"http: {max_conns_per_host: 10}", 10},
+		{"http: {max_conns_per_host: -1}", -1},
+	} {
+		cfg, err := loadTestConfig(t, "test: {concurrency: 50}\n"+tc.http+minimalModels)
+		if err != nil {
+			t.Fatalf("%s: 加载配置失败: %v", tc.http, err)
+		}
+		cfg.ApplyHTTPDefaults()
+		if got := cfg.Models[0].HTTP.MaxConnsPerHost; got != tc.want {
+			t.Errorf("%s: max_conns_per_host 为 %d，期望 %d", tc.http, got, tc.want)
+		}
+	}
+
+	if _, err := loadTestConfig(t, "http: {max_conns_per_host: -2}"+minimalModels); err == nil {
+		t.Error("小于-1的 max_conns_per_host 应当报错")
+	}
+}
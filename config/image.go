@@ -0,0 +1,120 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ImageConfig 定义随用户消息发送的一张图片，url 和 file 只能设置一个
+type ImageConfig struct {
+	// 图片URL，支持 http(s) URL 和 data:image/png;base64,... 格式的base64数据
+	URL string `yaml:"url,omitempty"`
+	// 本地图片文件路径，加载配置时读取并编码为base64数据
+	File string `yaml:"file,omitempty"`
+	// 图片精度：auto、low 或 high，只对OpenAI兼容接口生效
+	Detail string `yaml:"detail,omitempty"`
+
+	// data 从 file 读取的图片编码成的data URL，不写入报告中的配置
+	data string
+}
+
+// Source 返回发送给模型的图片地址：file 读取的data URL或者配置的 url
+func (i ImageConfig) Source() string {
+	if i.data != "" {
+		return i.data
+	}
+	return i.URL
+}
+
+// Size 返回图片数据的字节数，图片为远程URL时返回0
+func (i ImageConfig) Size() int {
+	_, data, ok := ParseDataURL(i.Source())
+	if !ok {
+		return 0
+	}
+	return base64.StdEncoding.DecodedLen(len(data))
+}
+
+// ParseDataURL 解析 data:<媒体类型>;base64,<数据> 格式的URL，返回媒体类型和base64数据
+func ParseDataURL(s string) (mediaType, data string, ok bool) {
+	rest, found := strings.CutPrefix(s, "data:")
+	if !found {
+		return "", "", false
+	}
+	header, data, found := strings.Cut(rest, ",")
+	if !found {
+		return "", "", false
+	}
+	mediaType, found = strings.CutSuffix(header, ";base64")
+	if !found {
+		return "", "", false
+	}
+	return mediaType, data, true
+}
+
+// redactImages 返回图片配置的副本，内联的base64数据替换为媒体类型和大小
+func redactImages(images []ImageConfig) []ImageConfig {
+	if len(images) == 0 {
+		return images
+	}
+	redacted := make([]ImageConfig, len(images))
+	for i, image := range images {
+		if mediaType, _, ok := ParseDataURL(image.URL); ok {
+			image.URL = fmt.Sprintf("data:%s;base64,...(%d字节)", mediaType, image.Size())
+		}
+		redacted[i] = image
+	}
+	return redacted
+}
+
+// validateImages 校验提示词场景中的图片配置
+func validateImages(images []ImageConfig) error {
+	for i, image := range images {
+		if (image.URL == "") == (image.File == "") {
+			return fmt.Errorf("images 第 %d 张图片必须且只能设置 url 和 file 中的一个", i+1)
+		}
+		if image.URL != "" && !strings.HasPrefix(image.URL, "http://") && !strings.HasPrefix(image.URL, "https://") {
+			if _, _, ok := ParseDataURL(image.URL); !ok {
+				return fmt.Errorf("images 第 %d 张图片的 url 必须是 http(s) URL 或 base64 编码的 data URL", i+1)
+			}
+		}
+		switch image.Detail {
+		case "", "auto", "low", "high":
+		default:
+			return fmt.Errorf("images 第 %d 张图片的 detail 无效: %q，支持 auto、low、high", i+1, image.Detail)
+		}
+	}
+	return nil
+}
+
+// loadImageFiles 读取所有场景中 file 指定的图片，编码为data URL
+func (c *Config) loadImageFiles() error {
+	for i := range c.Prompts {
+		for j := range c.Prompts[i].Images {
+			image := &c.Prompts[i].Images[j]
+			if image.File == "" {
+				continue
+			}
+			content, err := os.ReadFile(image.File)
+			if err != nil {
+				return fmt.Errorf("读取图片文件失败 (%s): %w", image.File, err)
+			}
+			// 优先按扩展名判断图片类型，无法判断时根据文件内容检测
+			mediaType := mime.TypeByExtension(strings.ToLower(filepath.Ext(image.File)))
+			if mediaType == "" {
+				mediaType = http.DetectContentType(content)
+			}
+			mediaType, _, _ = strings.Cut(mediaType, ";")
+			if !strings.HasPrefix(mediaType, "image/") {
+				return fmt.Errorf("图片文件 %s 不是支持的图片格式: %s", image.File, mediaType)
+			}
+			image.data = fmt.Sprintf("data:%s;base64,%s", mediaType, base64.StdEncoding.EncodeToString(content))
+		}
+	}
+	return nil
+}
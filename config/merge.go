@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 合并多个配置文件时需要拼接而不是覆盖的顶层列表
+var concatenatedKeys = map[string]bool{
+	"models":  true,
+	"proxies": true,
+	"prompts": true,
+}
+
+// readConfigFile 读取配置文件内容，路径为"-"时从标准输入读取
+func readConfigFile(filePath string) ([]byte, error) {
+	if filePath == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(filePath)
+}
+
+// mergeConfigFiles 按顺序读取并合并多个配置文件，返回合并后的YAML
+//
+// 合并规则：
+//   - 后面文件中的标量字段覆盖前面文件中的同名字段
+//   - 嵌套的配置块（例如 test、prompt）按字段递归合并
+//   - 顶层的 models、proxies、prompts 列表按顺序拼接
+//   - 其他列表（例如 concurrency_levels）整体覆盖
+func mergeConfigFiles(filePaths []string) ([]byte, error) {
+	merged := make(map[string]interface{})
+	stdinUsed := false
+
+	for _, filePath := range filePaths {
+		if filePath == "-" {
+			if stdinUsed {
+				return nil, fmt.Errorf("标准输入只能作为配置文件使用一次")
+			}
+			stdinUsed = true
+		}
+
+		data, err := readConfigFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("读取配置文件 %s 失败: %w", filePath, err)
+		}
+
+		var values map[string]interface{}
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("解析配置文件 %s 失败: %w", filePath, err)
+		}
+
+		for key, value := range values {
+			if existing, ok := merged[key].([]interface{}); ok && concatenatedKeys[key] {
+				if list, ok := value.([]interface{}); ok {
+					merged[key] = append(existing, list...)
+					continue
+				}
+			}
+			merged[key] = mergeValue(merged[key], value)
+		}
+	}
+
+	return yaml.Marshal(merged)
+}
+
+// mergeValue 递归合并两个配置值，两者都是配置块时按字段合并，否则使用新值
+func mergeValue(dst, src interface{}) interface{} {
+	dstMap, dstOK := dst.(map[string]interface{})
+	srcMap, srcOK := src.(map[string]interface{})
+	if !dstOK || !srcOK {
+		return src
+	}
+
+	for key, value := range srcMap {
+		dstMap[key] = mergeValue(dstMap[key], value)
+	}
+	return dstMap
+}
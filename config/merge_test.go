@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// 后面的配置文件覆盖前面文件中的标量和普通列表，配置块按字段合并，models 列表拼接
+func TestLoadConfigsMergeOverride(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yaml")
+	override := filepath.Join(dir, "override.yaml")
+	files := map[string]string{
+		base: `
+test:
+  concurrency_levels: [1, 2]
+  request_timeout: 30s
+  total_requests: 10
+models:
+  - name: a
+    type: mock
+prompt:
+  user_message: hi
+  system_message: base
+`,
+		override: `
+test:
+  concurrency_levels: [8]
+  total_requests: 20
+models:
+  - name: b
+    type: mock
+prompt:
+  system_message: override
+`,
+	}
+	for file, content := range files {
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := LoadConfigs([]string{base, override})
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	if !slices.Equal(cfg.Test.ConcurrencyLevels, []int{8}) {
+		t.Errorf("concurrency_levels 为 %v，期望被覆盖为 [8]", cfg.Test.ConcurrencyLevels)
+	}
+	if cfg.Test.TotalRequests != 20 {
+		t.Errorf("total_requests 为 %d，期望被覆盖为 20", cfg.Test.TotalRequests)
+	}
+	if cfg.Test.RequestTimeout != 30*time.Second {
+		t.Errorf("request_timeout 为 %s，后面的文件没有设置时应当保留 30s", cfg.Test.RequestTimeout)
+	}
+	if len(cfg.Models) != 2 || cfg.Models[0].Name != "a" || cfg.Models[1].Name != "b" {
+		t.Errorf("models 应当按顺序拼接为 a、b，实际为 %+v", cfg.Models)
+	}
+	if prompt := cfg.Prompts[0]; prompt.UserMessage != "hi" || prompt.SystemMessage != "override" {
+		t.Errorf("prompt 应当按字段合并，实际为 user=%q system=%q", prompt.UserMessage, prompt.SystemMessage)
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"math"
+)
+
+// StringParam 读取字符串类型的模型参数，未设置时返回默认值
+func (m ModelConfig) StringParam(key, defaultValue string) (string, error) {
+	value, ok := m.Params[key]
+	if !ok || value == nil {
+		return defaultValue, nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("模型 %s 的 %s 参数必须是字符串，实际为 %T", m.Name, key, value)
+	}
+	return s, nil
+}
+
+// FloatParam 读取数值类型的模型参数，未设置时返回默认值
+// YAML中写成整数（例如 temperature: 1）时也会转换为浮点数
+func (m ModelConfig) FloatParam(key string, defaultValue float64) (float64, error) {
+	value, ok := m.Params[key]
+	if !ok || value == nil {
+		return defaultValue, nil
+	}
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	default:
+		return 0, fmt.Errorf("模型 %s 的 %s 参数必须是数字，实际为 %T", m.Name, key, value)
+	}
+}
+
+// IntParam 读取整数类型的模型参数，未设置时返回默认值
+// 没有小数部分的浮点数（例如 max_tokens: 1024.0）也可以接受
+func (m ModelConfig) IntParam(key string, defaultValue int) (int, error) {
+	value, ok := m.Params[key]
+	if !ok || value == nil {
+		return defaultValue, nil
+	}
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		if v >= math.MinInt && v <= math.MaxInt {
+			return int(v), nil
+		}
+	case uint64:
+		if v <= math.MaxInt {
+			return int(v), nil
+		}
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt && v <= math.MaxInt {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("模型 %s 的 %s 参数必须是整数，实际为 %v", m.Name, key, value)
+}
+
+// StringListParam 读取字符串列表类型的模型参数，单个字符串视为只有一个元素的列表，未设置时返回nil
+func (m ModelConfig) StringListParam(key string) ([]string, error) {
+	value, ok := m.Params[key]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		list := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("模型 %s 的 %s 参数必须是字符串列表，第%d个元素为 %T", m.Name, key, i+1, item)
+			}
+			list[i] = s
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("模型 %s 的 %s 参数必须是字符串或字符串列表，实际为 %T", m.Name, key, value)
+	}
+}
+
+// HasParam 返回是否设置了指定的模型参数
+func (m ModelConfig) HasParam(key string) bool {
+	value, ok := m.Params[key]
+	return ok && value != nil
+}
+
+// OverrideParam 为所有模型设置同一个参数，覆盖配置文件中的值，用于命令行参数覆盖生成参数
+// value 需要是 StringParam、FloatParam、IntParam 能够读取的类型
+func (c *Config) OverrideParam(key string, value interface{}) {
+	for i := range c.Models {
+		params := make(map[string]interface{}, len(c.Models[i].Params)+1)
+		for k, v := range c.Models[i].Params {
+			params[k] = v
+		}
+		params[key] = value
+		c.Models[i].Params = params
+	}
+}
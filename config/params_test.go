@@ -0,0 +1,106 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// YAML中写成整数的参数可以作为浮点数读取，没有小数部分的浮点数可以作为整数读取
+func TestNumericParamsFromYAML(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+models:
+  - name: m
+    type: mock
+    params:
+      temperature: 1
+      top_p: 0.5
+      big: 4294967296
+      max_tokens: 1024.0
+      frequency_penalty: -1
+      fraction: 1.5
+      text: "1"
+prompt:
+  user_message: hi
+`)
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	m := cfg.Models[0]
+
+	for key, want := range map[string]float64{
+		"temperature":       1,
+		"top_p":             0.5,
+		"big":               4294967296,
+		"max_tokens":        1024,
+		"frequency_penalty": -1,
+		"missing":           0.7,
+	} {
+		got, err := m.FloatParam(key, 0.7)
+		if err != nil {
+			t.Errorf("FloatParam(%s) 失败: %v", key, err)
+		} else if got != want {
+			t.Errorf("FloatParam(%s) 为 %g，期望 %g", key, got, want)
+		}
+	}
+	for key, want := range map[string]int{
+		"temperature": 1,
+		"max_tokens":  1024,
+		"missing":     50,
+	} {
+		got, err := m.IntParam(key, 50)
+		if err != nil {
+			t.Errorf("IntParam(%s) 失败: %v", key, err)
+		} else if got != want {
+			t.Errorf("IntParam(%s) 为 %d，期望 %d", key, got, want)
+		}
+	}
+
+	if _, err := m.IntParam("fraction", 0); err == nil || !strings.Contains(err.Error(), "fraction") {
+		t.Errorf("有小数部分的值作为整数读取时应当报错，实际为 %v", err)
+	}
+	if _, err := m.FloatParam("text", 0); err == nil {
+		t.Error("字符串作为数字读取时应当报错")
+	}
+	if _, err := m.IntParam("text", 0); err == nil {
+		t.Error("字符串作为整数读取时应当报错")
+	}
+}
+
+// 命令行覆盖的参数与配置文件中的参数一样可以按数值读取
+func TestOverrideParamNumeric(t *testing.T) {
+	cfg, err := loadTestConfig(t, minimalModels)
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	cfg.OverrideParam("temperature", 0.2)
+	cfg.OverrideParam("max_tokens", 256)
+	if got, err := cfg.Models[0].FloatParam("temperature", 1); err != nil || got != 0.2 {
+		t.Errorf("覆盖后的 temperature 为 %g (%v)，期望 0.2", got, err)
+	}
+	if got, err := cfg.Models[0].IntParam("max_tokens", 0); err != nil || got != 256 {
+		t.Errorf("覆盖后的 max_tokens 为 %d (%v)，期望 256", got, err)
+	}
+}
+
+// 配置文件中的浮点数字段同样接受整数写法
+func TestFloatFieldsAcceptIntegers(t *testing.T) {
+	cfg, err := loadTestConfig(t, `
+test:
+  near_timeout_ratio: 1
+models:
+  - name: m
+    type: mock
+    input_price_per_1k: 3
+prompt:
+  user_message: hi
+`)
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	if cfg.Test.NearTimeoutRatio != 1 {
+		t.Errorf("near_timeout_ratio 为 %g，期望 1", cfg.Test.NearTimeoutRatio)
+	}
+	if cfg.Models[0].InputPricePer1K != 3 {
+		t.Errorf("input_price_per_1k 为 %g，期望 3", cfg.Models[0].InputPricePer1K)
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// responseSchemaURL 编译 response_schema 时使用的资源地址，只用于Schema内部的 $ref 解析
+const responseSchemaURL = "response_schema.json"
+
+// CompileResponseSchema 使用JSON Schema库编译场景的 response_schema，未设置时返回nil
+// 没有 $schema 时按 draft 2020-12 处理，format 关键字作为断言校验；$ref 可以引用Schema内部的定义或本地文件
+func (p PromptConfig) CompileResponseSchema() (*jsonschema.Schema, error) {
+	if p.ResponseSchema == "" {
+		return nil, nil
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+	if err := compiler.AddResource(responseSchemaURL, strings.NewReader(p.ResponseSchema)); err != nil {
+		return nil, fmt.Errorf("response_schema 不是合法的JSON: %w", err)
+	}
+	schema, err := compiler.Compile(responseSchemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("response_schema 不是合法的JSON Schema: %w", err)
+	}
+	return schema, nil
+}
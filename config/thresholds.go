@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ThresholdsConfig 定义性能门槛，任何一个测试结果未达到时命令以非0状态退出，用于CI中的性能回归检查
+// 未设置（为0）的字段不检查
+type ThresholdsConfig struct {
+	// P99延迟上限
+	MaxP99 time.Duration `yaml:"max_p99,omitempty"`
+	// 最低成功率，取值0到1，例如0.99表示99%
+	MinSuccessRate float64 `yaml:"min_success_rate,omitempty"`
+	// 最低RPS
+	MinRPS float64 `yaml:"min_rps,omitempty"`
+}
+
+// validate 检查门槛的取值范围，name 为出错时提示的配置项名称
+func (t *ThresholdsConfig) validate(name string) error {
+	if t == nil {
+		return nil
+	}
+	if t.MaxP99 < 0 {
+		return fmt.Errorf("%s.max_p99 不能为负数", name)
+	}
+	if t.MinSuccessRate < 0 || t.MinSuccessRate > 1 {
+		return fmt.Errorf("%s.min_success_rate 必须在0到1之间: %g", name, t.MinSuccessRate)
+	}
+	if t.MinRPS < 0 {
+		return fmt.Errorf("%s.min_rps 不能为负数", name)
+	}
+	return nil
+}
+
+// ThresholdsFor 返回模型实际使用的性能门槛：模型的 thresholds 中设置的字段覆盖全局 test.thresholds，
+// 都没有配置时返回nil
+func (c *Config) ThresholdsFor(modelName string) *ThresholdsConfig {
+	var thresholds ThresholdsConfig
+	configured := false
+	if global := c.Test.Thresholds; global != nil {
+		thresholds = *global
+		configured = true
+	}
+	for _, mdl := range c.Models {
+		if mdl.Name != modelName || mdl.Thresholds == nil {
+			continue
+		}
+		configured = true
+		if mdl.Thresholds.MaxP99 > 0 {
+			thresholds.MaxP99 = mdl.Thresholds.MaxP99
+		}
+		if mdl.Thresholds.MinSuccessRate > 0 {
+			thresholds.MinSuccessRate = mdl.Thresholds.MinSuccessRate
+		}
+		if mdl.Thresholds.MinRPS > 0 {
+			thresholds.MinRPS = mdl.Thresholds.MinRPS
+		}
+	}
+	if !configured {
+		return nil
+	}
+	return &thresholds
+}
+
+// checksP99 返回是否有任何模型设置了P99延迟门槛
+func (c *Config) checksP99() bool {
+	if c.Test.Thresholds != nil && c.Test.Thresholds.MaxP99 > 0 {
+		return true
+	}
+	for _, mdl := range c.Models {
+		if mdl.Thresholds != nil && mdl.Thresholds.MaxP99 > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/lemonlinger/llm-test/config"
+)
+
+// sampleThroughput 每隔 window 统计一次每个模型在该窗口内完成的请求数，换算为RPS记录到 rpsWindows
+// done 关闭时返回，最后不足一个窗口的部分不记录
+func sampleThroughput(runs []*levelRun, window time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+
+	last := make([]int64, len(runs))
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			for i, run := range runs {
+				completed := atomic.LoadInt64(&run.successCount) + atomic.LoadInt64(&run.failedCount)
+				run.rpsWindows = append(run.rpsWindows, float64(completed-last[i])/window.Seconds())
+				last[i] = completed
+			}
+		}
+	}
+}
+
+// detectConvergence 查找第一组连续 Windows 个变异系数不超过 MaxCV 的窗口，
+// 返回从测试开始到这组窗口开始的时间，没有找到时返回false
+func detectConvergence(windows []float64, cfg *config.ConvergenceConfig) (time.Duration, bool) {
+	for start := 0; start+cfg.Windows <= len(windows); start++ {
+		if coefficientOfVariation(windows[start:start+cfg.Windows]) <= cfg.MaxCV {
+			return time.Duration(start) * cfg.Window, true
+		}
+	}
+	return 0, false
+}
+
+// coefficientOfVariation 计算变异系数（总体标准差/平均值），平均值为0时返回+Inf
+func coefficientOfVariation(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return math.Inf(1)
+	}
+
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance/float64(len(values))) / mean
+}
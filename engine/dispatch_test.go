@@ -0,0 +1,35 @@
+package engine
+
+import "testing"
+
+// 模型的并发名额已满时应当重新选择有空闲名额的模型，而不是让工作协程等待
+func TestAcquireNextRepicksWhenCapIsFull(t *testing.T) {
+	capped := &levelRun{sem: make(chan struct{}, 1)}
+	uncapped := &levelRun{}
+	runs := []*levelRun{capped, uncapped}
+
+	picks := []int{0, 1}
+	pick := func() int {
+		next := picks[0]
+		picks = append(picks[1:], next)
+		return next
+	}
+
+	next, acquired := acquireNext(runs, 0, pick)
+	if next != 0 || !acquired {
+		t.Fatalf("有空闲名额时应当派发给模型0，实际为 %d, %v", next, acquired)
+	}
+	next, acquired = acquireNext(runs, 0, pick)
+	if next != 1 || !acquired {
+		t.Fatalf("模型0名额已满时应当重新选择模型1，实际为 %d, %v", next, acquired)
+	}
+
+	next, acquired = acquireNext([]*levelRun{capped}, 0, func() int { return 0 })
+	if acquired {
+		t.Fatalf("所有模型的名额都已满时不应占用名额，实际选择了 %d", next)
+	}
+	capped.release()
+	if _, acquired = acquireNext([]*levelRun{capped}, 0, func() int { return 0 }); !acquired {
+		t.Fatal("释放名额后应当可以再次占用")
+	}
+}
@@ -3,51 +3,214 @@ package engine
 import (
 	"context"
 	"fmt"
-	"log"
+	"math"
+	"math/rand"
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/briandowns/spinner"
 	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
 	"github.com/lemonlinger/llm-test/model"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 )
 
 // 测试结果结构体
 type TestResult struct {
-	ModelName          string
-	ConcurrencyLevel   int // 添加并发度字段
-	TotalRequests      int
-	SuccessRequests    int
-	FailedRequests     int
-	TotalDuration      time.Duration
-	AvgLatency         time.Duration
-	InputTokens        int64
-	OutputTokens       int64
-	TotalTokens        int64
-	AvgInputTokens     float64
-	AvgOutputTokens    float64
-	AvgTotalTokens     float64
-	RequestsPerSec     float64
-	TokensPerSec       float64
-	Errors             []string
-	LatencyPercentiles map[int]time.Duration // 存储各个百分位的延迟
-	AllLatencies       []time.Duration       // 所有请求的延迟记录
+	ModelName              string
+	Scenario               string // 提示词场景名称，单场景时为空
+	ConcurrencyLevel       int    // 添加并发度字段
+	TotalRequests          int
+	SuccessRequests        int
+	FailedRequests         int
+	IsKnee                 bool   // 是否为自动并发扫描选出的拐点并发度
+	StreamMode             string // 对比流式模式（compare_streaming）下该结果的模式：StreamModeStream 或 StreamModeStandard，其他情况为空
+	TotalDuration          time.Duration
+	AvgLatency             time.Duration
+	AvgTimeToFirstToken    time.Duration // 流式成功请求的平均首Token时间，非流式时为0
+	AvgTokensPerSecond     float64       // 流式成功请求的平均单请求输出速率（token/s），只统计能计算速率的请求，非流式时为0
+	AvgTTFB                time.Duration // 成功请求从发送到收到响应第一个字节的平均时间，模型没有记录连接耗时（例如模拟模型）时为0
+	NewConnRequests        int           // 新建连接（没有复用连接池中的连接）的成功请求数
+	AvgDNSTime             time.Duration // 新建连接的请求的平均DNS解析耗时
+	AvgConnectTime         time.Duration // 新建连接的请求的平均TCP连接耗时
+	AvgTLSTime             time.Duration // 新建连接的请求的平均TLS握手耗时，未使用HTTPS时为0
+	MinLatency             time.Duration // 成功请求的最小延迟
+	MaxLatency             time.Duration // 成功请求的最大延迟
+	StdDevLatency          time.Duration // 成功请求延迟的标准差
+	InputTokens            int64
+	OutputTokens           int64
+	TotalTokens            int64
+	AvgInputTokens         float64
+	AvgOutputTokens        float64
+	AvgTotalTokens         float64
+	ReasoningTokens        int64   // 推理模型的推理Token数，包含在 OutputTokens 中
+	AvgReasoningTokens     float64 // 每个成功请求的平均推理Token数
+	RequestsPerSec         float64
+	TokensPerSec           float64
+	ResponseBytes          int64   // 成功请求的响应体字节数之和，流式响应为累计接收的字节数
+	AvgResponseBytes       float64 // 每个成功请求的平均响应字节数
+	BytesPerSec            float64 // 每秒接收的响应字节数，不依赖各服务商的Token统计
+	TotalCost              float64 // 按配置的Token价格估算的总费用，未配置价格时为0
+	AvgCostPerRequest      float64 // 每个成功请求的平均费用
+	EstimatedTokenRequests int     // Token数为估算值的成功请求数（服务端未返回usage）
+	TruncatedRequests      int     // 因达到最大token数被截断（finish_reason为length）的请求数
+	StoppedRequests        int     // 正常结束（finish_reason为stop）的请求数，包括命中停止序列提前结束的请求
+	ResponseFormat         string  // 请求使用的response_format类型，未使用时为空
+	DiscardedRequests      int     // 按 discard_first_n 丢弃指标的成功请求数，计入成功请求数但不计入其他统计
+	RetriedRequests        int     // 限流或服务端错误后的重试次数，重试的请求只按最后一次的结果统计
+	NearTimeoutRequests    int     // 延迟超过请求超时时间 NearTimeoutRatio（默认90%）的成功请求数
+	ConcurrencyCap         int     // 模型的 max_concurrency 小于并发度时生效的并发上限，未限制时为0
+	Incomplete             bool    // 因测试中断或达到总时长上限，该并发度没有完整运行
+	ModelError             string  // 模型测试出错（开启 ContinueOnModelError 时）的错误信息，不为空时其他指标无效
+	PeakConcurrency        int     // 实际达到的最大同时进行中请求数
+	AvgConcurrency         float64 // 测试期间平均同时进行中的请求数，明显低于并发度时说明没有达到预期压力
+	ToolCallRequests       int     // 返回工具调用的成功请求数，其余成功请求返回文本
+	SchemaValidRequests    int     // 场景配置了 response_schema 时响应内容满足Schema的成功请求数
+	SchemaInvalidRequests  int     // 场景配置了 response_schema 时响应内容不满足Schema的成功请求数
+	TopErrors              []ErrorCount
+	ErrorsByCategory       map[string]int        // 按错误类型统计的失败请求数
+	LatencyPercentiles     map[int]time.Duration // 存储各个百分位的延迟
+	TTFTPercentiles        map[int]time.Duration // 流式成功请求的首Token时间百分位，百分位与延迟相同，非流式时为nil
+	AllLatencies           []time.Duration       // 用于计算百分位的延迟记录，默认只包含成功请求
+	// 延迟直方图，键为桶上界，值为落在该桶（不超过上界且超过上一个上界）的请求数，与百分位统计相同的请求
+	// 超过最后一个上界的请求计入键为 LatencyHistogramOverflow 的桶
+	LatencyHistogram map[time.Duration]int
+	SampleResponses  []string // 前N个成功请求的响应内容样例
+	SchemaErrors     []string // 不满足 response_schema 的响应的校验错误示例，相同的错误只保存一次
+	// 配置了吞吐量收敛检测时每个窗口的RPS，按时间顺序排列，不足一个窗口的结尾部分不统计
+	RPSWindows []float64
+	// 吞吐量是否在测试期间收敛，收敛时 TimeToSteadyState 为从测试开始到第一组稳定窗口开始的时间
+	Converged         bool
+	TimeToSteadyState time.Duration
+	// 响应头中的限流信息和429响应数，服务端没有返回限流信息且没有被限流时为nil
+	RateLimit *RateLimitStats
+	// 提示词缓存写入和读取的输入Token数及每个成功请求的平均值，服务端没有返回时为0，不包含在 InputTokens 中
+	CacheCreationInputTokens    int64
+	CacheReadInputTokens        int64
+	AvgCacheCreationInputTokens float64
+	AvgCacheReadInputTokens     float64
+	// 模型配置了多个API密钥时每个密钥的请求统计，按密钥的配置顺序排列，只有一个密钥时为nil
+	APIKeys []APIKeyStats
+	// 模型配置了代理池时每个代理的请求统计，按代理的配置顺序排列，未配置代理池时为nil
+	Proxies []ProxyStats
+	// 场景配置了 prompt_length 时按输入Token数区间统计的成功请求延迟，按区间从小到大排列，只包括有请求的区间
+	InputLengths []InputLengthStats
+	// 开启 RecordRequests 时每个请求的明细，按完成顺序排列，不包括被取消和按 discard_first_n 丢弃的请求
+	Requests []RequestRecord
 }
 
+// RequestRecord 单个请求的明细
+type RequestRecord struct {
+	StartTime        time.Time     // 请求开始时间，重试的请求为第一次发送的时间
+	Latency          time.Duration // 请求延迟，重试的请求为最后一次请求的延迟
+	TimeToFirstToken time.Duration // 流式请求的首Token时间，非流式时为0
+	InputTokens      int
+	OutputTokens     int
+	Success          bool
+	ErrorCategory    string // 失败请求的错误分类，成功时为空
+}
+
+// RequestEvent 请求完成时通过 SetRequestCallback 发送的事件，包括请求所属的模型、场景和并发度
+type RequestEvent struct {
+	ModelName        string
+	Scenario         string
+	StreamMode       string
+	ConcurrencyLevel int
+	RequestRecord
+}
+
+// ErrorCount 相同错误信息的失败请求数，TestResult.TopErrors 按次数从多到少保存出现次数最多的几种错误，没有失败时为nil
+// 错误信息去掉了请求ID、时间戳等每次不同的部分，过长时被截断
+type ErrorCount struct {
+	Message string
+	Count   int
+}
+
+// RateLimitStats 测试期间观察到的限流信息
+type RateLimitStats struct {
+	// 收到429响应的次数，包括之后重试成功的请求
+	RateLimitedRequests int
+	// 响应头中剩余请求数和Token数的最小值，服务端未返回时为-1
+	MinRemainingRequests int
+	MinRemainingTokens   int
+}
+
+// APIKeyStats 模型配置了多个API密钥时每个密钥的请求统计，每次尝试（包括重试）按使用的密钥分别计数
+type APIKeyStats struct {
+	Key      string // 密钥在报告中显示的名称，不包含完整的密钥
+	Requests int
+	Failed   int
+}
+
+// ProxyStats 模型配置了代理池时每个代理的请求统计，每次尝试（包括重试）按使用的代理分别计数
+type ProxyStats struct {
+	Name     string
+	Requests int
+	Failed   int
+}
+
+// InputLengthStats 输入Token数在 (MinTokens, MaxTokens] 区间内的成功请求的延迟统计，
+// MaxTokens 为0表示超过最后一个区间上界的请求；输入Token数优先使用服务端返回的值，未返回时使用抽取的目标值
+type InputLengthStats struct {
+	MinTokens           int
+	MaxTokens           int
+	Requests            int
+	AvgInputTokens      float64
+	AvgLatency          time.Duration
+	P50Latency          time.Duration
+	P95Latency          time.Duration
+	AvgTimeToFirstToken time.Duration // 流式请求的平均首Token时间，非流式时为0
+}
+
+// 对比流式模式下结果的模式
+const (
+	StreamModeStream   = "stream"
+	StreamModeStandard = "standard"
+)
+
+// LatencyHistogramOverflow 延迟直方图中超过最后一个桶上界的请求所在桶的键
+const LatencyHistogramOverflow = time.Duration(math.MaxInt64)
+
 // 测试引擎结构体
 type TestEngine struct {
 	config  config.TestConfig
 	models  []model.LLMModel
-	prompt  config.PromptConfig
+	prompts []config.PromptConfig
 	results map[string]*TestResult
 	spinner *spinner.Spinner
 	proxies map[string]string // 代理名称到URL的映射
+	// 每完成一个（模型, 场景, 并发度）组合就发送一次结果，Run结束时关闭
+	resultCh chan<- *TestResult
+	// 每完成一个（模型, 场景, 并发度）组合调用一次的回调
+	resultCallback func(*TestResult)
+	// 测试进行中定期调用的回调，参数为每个模型的实时统计
+	liveStatsCallback func([]LiveStats)
+	// 每个请求完成时调用的回调
+	requestCallback func(RequestEvent)
+	// 由 RandomSeed 初始化的随机数生成器，引擎内所有随机行为都使用它以保证可复现
+	// rand.Rand 不是并发安全的，多个工作协程使用时需要持有 rngMutex
+	rng      *rand.Rand
+	rngMutex sync.Mutex
+	// 派发抖动使用独立的随机数生成器，不影响流量混合等其他随机行为的序列
+	jitterRng   *rand.Rand
+	jitterMutex sync.Mutex
+	// 合成提示词的长度和填充内容同样使用独立的随机数生成器
+	lengthRng   *rand.Rand
+	lengthMutex sync.Mutex
+	// 流量混合选择模型使用独立的随机数生成器，只由派发协程使用，选择序列不受延迟采样次数的影响
+	mixRng *rand.Rand
+	// 编译后的 response_schema，以Schema原文为键
+	schemas map[string]*jsonschema.Schema
+	// 每个请求的调试日志，nil 等同于 quiet 级别
+	logger *logging.Logger
 }
 
 // 创建新的测试引擎
-func NewTestEngine(testConfig config.TestConfig, models []model.LLMModel, prompt config.PromptConfig, proxies []config.ProxyConfig) *TestEngine {
+// prompts 为要测试的提示词场景列表，每个模型会依次测试所有场景
+func NewTestEngine(testConfig config.TestConfig, models []model.LLMModel, prompts []config.PromptConfig, proxies []config.ProxyConfig) *TestEngine {
 	// 创建代理映射
 	proxyMap := make(map[string]string)
 	for _, proxy := range proxies {
@@ -55,20 +218,82 @@ func NewTestEngine(testConfig config.TestConfig, models []model.LLMModel, prompt
 	}
 
 	return &TestEngine{
-		config:  testConfig,
-		models:  models,
-		prompt:  prompt,
-		results: make(map[string]*TestResult),
-		proxies: proxyMap,
+		config:    testConfig,
+		models:    models,
+		prompts:   prompts,
+		results:   make(map[string]*TestResult),
+		proxies:   proxyMap,
+		rng:       rand.New(rand.NewSource(testConfig.RandomSeed)),
+		jitterRng: rand.New(rand.NewSource(testConfig.RandomSeed + 1)),
+		lengthRng: rand.New(rand.NewSource(testConfig.RandomSeed + 2)),
+		mixRng:    rand.New(rand.NewSource(testConfig.RandomSeed + 3)),
 	}
 }
 
+// SetResultChannel 设置接收已完成测试结果的通道，需要在Run之前调用
+// 每完成一个（模型, 场景, 并发度）组合，引擎会发送该结果的副本；Run返回前关闭通道。
+// 调用方需要持续读取通道，否则测试会被阻塞
+func (e *TestEngine) SetResultChannel(ch chan<- *TestResult) {
+	e.resultCh = ch
+}
+
+// SetResultCallback 设置每完成一个（模型, 场景, 并发度）组合时调用的回调，需要在Run之前调用
+// 回调在测试引擎的goroutine中同步执行，参数为结果的副本，耗时的处理会推迟后续测试
+func (e *TestEngine) SetResultCallback(callback func(*TestResult)) {
+	e.resultCallback = callback
+}
+
+// SetRequestCallback 设置每个请求完成时调用的回调，需要在Run之前调用，用于在测试进行中导出逐请求的指标
+// 与 RecordRequests 记录的请求相同，不包括被取消和按 discard_first_n 丢弃的请求；不需要开启 RecordRequests。
+// 回调在工作协程中同步执行，会被多个工作协程并发调用，耗时的处理会推迟后续请求，应当尽快返回
+func (e *TestEngine) SetRequestCallback(callback func(RequestEvent)) {
+	e.requestCallback = callback
+}
+
+// SetLogger 设置引擎的日志输出，需要在Run之前调用。失败请求的错误信息只在debug级别逐条输出，
+// 其他级别下由结果中的 ErrorsByCategory 和 TopErrors 汇总
+func (e *TestEngine) SetLogger(logger *logging.Logger) {
+	e.logger = logger
+}
+
 // 运行测试
-func (e *TestEngine) Run() (map[string]*TestResult, error) {
+// 每个请求的超时都从ctx派生。当ctx被取消时（例如收到中断信号），停止派发新请求，
+// 进行中的请求随之取消且不计入统计，并返回已经累积的测试结果。
+// 配置了 MaxTotalDuration 时，达到总时长上限同样按取消处理
+func (e *TestEngine) Run(ctx context.Context) (map[string]*TestResult, error) {
 	// 使用复合键（模型名称+并发度）来存储结果
 	results := make(map[string]*TestResult)
 
+	if e.resultCh != nil {
+		defer close(e.resultCh)
+	}
+
+	if e.config.MaxTotalDuration > 0 {
+		runCtx, cancel := context.WithTimeout(ctx, e.config.MaxTotalDuration)
+		defer cancel()
+		defer func() {
+			// 由总时长上限而不是外部取消导致的停止，输出已完成的并发度
+			if ctx.Err() == nil && runCtx.Err() == context.DeadlineExceeded {
+				printDeadlineSummary(e.config.MaxTotalDuration, results)
+			}
+		}()
+		ctx = runCtx
+	}
+
+	// 流量混合模式：所有混合的模型同时测试
+	if len(e.config.TrafficMix) > 0 {
+		if err := e.runTrafficMix(ctx, results); err != nil {
+			return nil, err
+		}
+		e.results = results
+		return results, nil
+	}
+
 	for _, mdl := range e.models {
+		if ctx.Err() != nil {
+			break
+		}
+
 		modelName := mdl.GetName()
 		fmt.Printf("正在测试模型: %s\n", modelName)
 
@@ -76,7 +301,9 @@ func (e *TestEngine) Run() (map[string]*TestResult, error) {
 		var concurrencyLevels []int
 		modelConcurrencyLevels := mdl.GetConcurrencyLevels()
 
-		if len(modelConcurrencyLevels) > 0 {
+		if e.config.AutoSweep != nil {
+			fmt.Printf("  使用自动并发扫描: 最大并发度=%d\n", e.config.AutoSweep.MaxConcurrency)
+		} else if len(modelConcurrencyLevels) > 0 {
 			// 使用模型特定的并发度配置
 			concurrencyLevels = modelConcurrencyLevels
 			fmt.Printf("  使用模型特定的并发度配置: %v\n", concurrencyLevels)
@@ -90,21 +317,23 @@ func (e *TestEngine) Run() (map[string]*TestResult, error) {
 			fmt.Printf("  使用基础并发度: %d\n", e.config.Concurrency)
 		}
 
-		// 对每个并发级别运行测试
-		for _, concurrency := range concurrencyLevels {
-			// 为每个并发度创建一个新的结果对象
-			resultKey := fmt.Sprintf("%s-%d", modelName, concurrency)
-			result := &TestResult{
-				ModelName:        modelName,
-				ConcurrencyLevel: concurrency,
-				Errors:           make([]string, 0),
+		var err error
+		if !e.config.SkipPreflight {
+			err = e.preflight(ctx, mdl)
+			if ctx.Err() != nil {
+				break
 			}
-			results[resultKey] = result
-
-			err := e.runTestWithConcurrency(mdl, concurrency, result)
-			if err != nil {
+		}
+		if err == nil {
+			err = e.runModel(ctx, mdl, concurrencyLevels, results)
+		}
+		if err != nil {
+			if !e.config.ContinueOnModelError {
 				return nil, fmt.Errorf("测试模型 %s 失败: %w", modelName, err)
 			}
+			// 记录失败原因，继续测试其他模型
+			fmt.Printf("  测试模型 %s 失败，跳过该模型: %v\n", modelName, err)
+			recordModelError(results, modelName, err)
 		}
 	}
 
@@ -114,29 +343,176 @@ func (e *TestEngine) Run() (map[string]*TestResult, error) {
 	return results, nil
 }
 
-// 以指定并发度运行测试
-func (e *TestEngine) runTestWithConcurrency(mdl model.LLMModel, concurrency int, result *TestResult) error {
-	// 获取模型名称
-	modelName := mdl.GetName()
+// recordModelError 记录模型测试出错的结果（开启 ContinueOnModelError 时），报告中显示为该模型的错误信息
+func recordModelError(results map[string]*TestResult, modelName string, err error) {
+	results[ResultKey(modelName, "", 0)] = &TestResult{
+		ModelName:        modelName,
+		ModelError:       err.Error(),
+		ErrorsByCategory: make(map[string]int),
+	}
+}
 
-	fmt.Printf("  并发度: %d\n", concurrency)
+// runModel 对每个提示词场景和并发级别运行一个模型的测试
+func (e *TestEngine) runModel(ctx context.Context, mdl model.LLMModel, concurrencyLevels []int, results map[string]*TestResult) error {
+	for _, prompt := range e.prompts {
+		if prompt.Name != "" {
+			fmt.Printf("  场景: %s\n", prompt.Name)
+		}
+
+		// 自动并发扫描模式
+		if e.config.AutoSweep != nil {
+			if err := e.runAutoSweep(ctx, mdl, prompt, results); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, concurrency := range concurrencyLevels {
+			if ctx.Err() != nil {
+				fmt.Println("  测试已中断，跳过剩余的并发度")
+				break
+			}
+
+			// 对比流式模式下同一个并发度先后以非流式和流式各测试一次
+			streamModes := []string{""}
+			if e.config.CompareStreaming {
+				streamModes = []string{StreamModeStandard, StreamModeStream}
+			}
+			for _, streamMode := range streamModes {
+				if _, err := e.runLevel(ctx, mdl, prompt, concurrency, streamMode, results); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// 以指定并发度运行一个场景的测试，并将结果存入results
+// streamMode 不为空时使用指定的流式模式（对比流式模式），否则使用模型或场景的流式设置
+func (e *TestEngine) runLevel(ctx context.Context, mdl model.LLMModel, prompt config.PromptConfig, concurrency int, streamMode string, results map[string]*TestResult) (*TestResult, error) {
+	// 为每个场景和并发度创建一个新的结果对象
+	result := &TestResult{
+		ModelName:        mdl.GetName(),
+		Scenario:         prompt.Name,
+		ConcurrencyLevel: concurrency,
+		StreamMode:       streamMode,
+		ResponseFormat:   mdl.GetResponseFormat(),
+		ErrorsByCategory: make(map[string]int),
+	}
+	resultKey := result.Key()
+	results[resultKey] = result
+
+	runs := []*levelRun{e.newLevelRun(mdl, prompt, result)}
+	if err := e.runTestWithConcurrency(ctx, runs, prompt, concurrency, func() int { return 0 }); err != nil {
+		delete(results, resultKey)
+		return nil, err
+	}
+	result.Incomplete = ctx.Err() != nil
+
+	e.publishResult(result)
+	return result, nil
+}
+
+// publishResult 将已完成的结果通知给回调和结果通道
+// 发送的是副本，避免与之后对结果的修改（例如标记拐点）产生数据竞争
+func (e *TestEngine) publishResult(result *TestResult) {
+	if e.resultCallback != nil {
+		completed := *result
+		e.resultCallback(&completed)
+	}
+	if e.resultCh != nil {
+		completed := *result
+		e.resultCh <- &completed
+	}
+}
+
+// printDeadlineSummary 输出达到总时长上限时已完成和未完整运行的并发度
+func printDeadlineSummary(limit time.Duration, results map[string]*TestResult) {
+	all := make([]*TestResult, 0, len(results))
+	for _, result := range results {
+		all = append(all, result)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].ModelName != all[j].ModelName {
+			return all[i].ModelName < all[j].ModelName
+		}
+		if all[i].Scenario != all[j].Scenario {
+			return all[i].Scenario < all[j].Scenario
+		}
+		return all[i].ConcurrencyLevel < all[j].ConcurrencyLevel
+	})
+
+	fmt.Printf("\n已达到总测试时长上限 %s，停止测试\n", limit)
+	for _, result := range all {
+		if result.ModelError != "" {
+			fmt.Printf("  %s: 测试出错\n", result.ModelName)
+			continue
+		}
+		name := result.ModelName
+		if result.Scenario != "" {
+			name += "/" + result.Scenario
+		}
+		status := "已完成"
+		if result.Incomplete {
+			status = "未完整运行"
+		}
+		fmt.Printf("  %s 并发度 %d: %s\n", name, result.ConcurrencyLevel, status)
+	}
+}
 
-	// 初始化计数器
-	var successCount int64
-	var failedCount int64
-	var totalLatency int64
-	var inputTokens int64
-	var outputTokens int64
+// ResultKey 返回测试结果在结果集中的键（模型名称+场景+并发度），场景为空时省略
+func ResultKey(modelName, scenario string, concurrency int) string {
+	if scenario == "" {
+		return fmt.Sprintf("%s-%d", modelName, concurrency)
+	}
+	return fmt.Sprintf("%s-%s-%d", modelName, scenario, concurrency)
+}
+
+// Key 返回结果在结果集中的键，对比流式模式下在 ResultKey 后加上模式后缀，例如 "gpt-4o-10-stream"
+func (r *TestResult) Key() string {
+	key := ResultKey(r.ModelName, r.Scenario, r.ConcurrencyLevel)
+	if r.StreamMode != "" {
+		key += "-" + r.StreamMode
+	}
+	return key
+}
 
-	// 确定是否使用流式输出：优先使用模型特定设置，如果未设置则使用全局设置
-	useStream := e.prompt.Stream
-	if modelStream := mdl.GetStreamSetting(); modelStream != nil {
-		useStream = *modelStream
-		fmt.Printf("  使用模型特定的流式设置: %v\n", useStream)
-	} else {
-		fmt.Printf("  使用全局流式设置: %v\n", useStream)
+// MergeResults 将 current 中的结果合并到 previous 中并返回合并后的结果集，用于跨多次运行累积测试结果
+// 两者有相同键的结果时使用 current 中的结果，previous 为nil时创建新的结果集
+func MergeResults(previous, current map[string]*TestResult) map[string]*TestResult {
+	merged := make(map[string]*TestResult, len(previous)+len(current))
+	for key, result := range previous {
+		merged[key] = result
+	}
+	for key, result := range current {
+		merged[key] = result
+	}
+	return merged
+}
+
+// 以指定并发度运行测试
+// runs 为共用同一个工作协程池的模型，每个请求由 pick 选择发送给哪个模型；只测试单个模型时 runs 只有一个元素
+func (e *TestEngine) runTestWithConcurrency(ctx context.Context, runs []*levelRun, prompt config.PromptConfig, concurrency int, pick func() int) error {
+	fmt.Printf("  并发度: %d\n", concurrency)
+	for _, run := range runs {
+		prefix := "  "
+		if len(runs) > 1 {
+			prefix = fmt.Sprintf("  %s: ", run.mdl.GetName())
+		}
+		if run.result.ConcurrencyCap > 0 {
+			fmt.Printf("%s并发上限: %d (模型配置的 max_concurrency)\n", prefix, run.result.ConcurrencyCap)
+		}
+		if run.result.StreamMode != "" {
+			fmt.Printf("%s对比流式模式: %s\n", prefix, run.result.StreamMode)
+		} else if run.mdl.GetStreamSetting() != nil {
+			fmt.Printf("%s使用模型特定的流式设置: %v\n", prefix, run.useStream)
+		} else {
+			fmt.Printf("%s使用全局流式设置: %v\n", prefix, run.useStream)
+		}
 	}
 
+	e.spinner = nil
 	if e.config.ShowProgress {
 		e.spinner = spinner.New(spinner.CharSets[9], 100*time.Millisecond)
 		e.spinner.Prefix = "  正在测试 "
@@ -144,123 +520,300 @@ func (e *TestEngine) runTestWithConcurrency(mdl model.LLMModel, concurrency int,
 		defer e.spinner.Stop()
 	}
 
-	// 创建工作通道和等待组
-	jobs := make(chan struct{}, concurrency*2)
+	// 创建工作通道和等待组，通道中传递处理请求的模型下标
+	jobs := make(chan int, concurrency*2)
 	var wg sync.WaitGroup
 
-	// 创建延迟数据切片和互斥锁
-	var latencies []time.Duration
-	var latenciesMutex sync.Mutex
-
 	// 创建信号量控制并发
 	sem := make(chan struct{}, concurrency)
 
 	// 如果有预热时间，先进行预热
 	if e.config.WarmupDuration > 0 {
 		// 预热逻辑...
-		time.Sleep(e.config.WarmupDuration)
+		select {
+		case <-time.After(e.config.WarmupDuration):
+		case <-ctx.Done():
+		}
 	}
 
 	// 记录开始时间
 	startTime := time.Now()
 
-	// 启动工作协程
+	// 定期在进度提示中刷新已完成请求数、成功率和RPS，并调用实时统计回调
+	progressDone := make(chan struct{})
+	var progressWg sync.WaitGroup
+	// 配置了收敛检测时按固定窗口记录RPS，与进度刷新一起停止
+	if convergence := e.config.Convergence; convergence != nil {
+		progressWg.Add(1)
+		go func() {
+			defer progressWg.Done()
+			sampleThroughput(runs, convergence.Window, progressDone)
+		}()
+	}
+	if e.spinner != nil || e.liveStatsCallback != nil {
+		progressWg.Add(1)
+		go func() {
+			defer progressWg.Done()
+			ticker := time.NewTicker(500 * time.Millisecond)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-progressDone:
+					return
+				case <-ticker.C:
+					if e.liveStatsCallback != nil {
+						e.liveStatsCallback(e.liveStats(runs, startTime))
+					}
+					if e.spinner == nil {
+						continue
+					}
+
+					var success, failed int64
+					for _, run := range runs {
+						success += atomic.LoadInt64(&run.successCount)
+						failed += atomic.LoadInt64(&run.failedCount)
+					}
+					completed := success + failed
+
+					okRate := 0.0
+					if completed > 0 {
+						okRate = float64(success) / float64(completed) * 100
+					}
+					rps := float64(completed) / time.Since(startTime).Seconds()
+
+					e.spinner.Lock()
+					e.spinner.Prefix = fmt.Sprintf("  正在测试 [%d reqs, %.1f%% ok, %.1f rps] ", completed, okRate, rps)
+					e.spinner.Unlock()
+				}
+			}
+		}()
+	}
+
+	// 启动工作协程，放弃等待卡住的请求时取消 workCtx，让响应取消的客户端尽快返回
+	workCtx, cancelWork := context.WithCancel(ctx)
+	defer cancelWork()
 	for i := 0; i < concurrency; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 
-			for range jobs {
+			for index := range jobs {
+				e.waitJitter(workCtx)
 				sem <- struct{}{}
-
-				// 执行单个请求
-				ctx, cancel := context.WithTimeout(context.Background(), e.config.RequestTimeout)
-
-				start := time.Now()
-				resp, err := mdl.GenerateResponse(ctx, e.prompt.SystemMessage, e.prompt.UserMessage, useStream)
-				latency := time.Since(start)
-
-				// 记录延迟数据
-				latenciesMutex.Lock()
-				latencies = append(latencies, latency)
-				latenciesMutex.Unlock()
-
-				if err != nil {
-					log.Printf("测试模型 %s 失败: %v", modelName, err)
-					atomic.AddInt64(&failedCount, 1)
-					result.Errors = append(result.Errors, err.Error()) // 注意：这里有并发安全问题，实际应该使用互斥锁
-				} else {
-					atomic.AddInt64(&successCount, 1)
-					atomic.AddInt64(&totalLatency, int64(latency))
-					atomic.AddInt64(&inputTokens, int64(resp.InputTokens))
-					atomic.AddInt64(&outputTokens, int64(resp.OutputTokens))
-				}
-
-				cancel()
+				runs[index].do(workCtx, prompt)
 				<-sem
+				runs[index].release()
 			}
 		}()
 	}
 
-	// 发送工作
-	timeout := time.After(e.config.Duration)
+	// 发送工作：固定请求数模式下发送完指定数量即停止，否则持续到测试时间结束
+	var timeout <-chan time.Time
+	if e.config.TotalRequests == 0 {
+		timeout = time.After(e.config.LevelDuration(concurrency))
+	}
 	requestCount := 0
 
+	// 所有工作协程都卡住时派发会一直阻塞，定期检查以便停止派发
+	stuckCheck := time.NewTicker(500 * time.Millisecond)
+	defer stuckCheck.Stop()
+
+	// 派发前先占用模型的并发名额，工作协程不会因为等待某个模型的名额而空闲
+	// 名额已满时重新选择模型，所有重新选择的模型都已满时等待 next 的名额
+	next, acquired := pick(), false
 loop:
-	for {
+	for e.config.TotalRequests == 0 || requestCount < e.config.TotalRequests {
+		if !acquired {
+			next, acquired = acquireNext(runs, next, pick)
+		}
+		var send chan<- int
+		var slot chan struct{}
+		if acquired {
+			send = jobs
+		} else {
+			slot = runs[next].sem
+		}
 		select {
 		case <-timeout:
 			break loop
-		default:
-			jobs <- struct{}{}
+		case <-ctx.Done():
+			// 收到取消信号，停止派发新请求
+			break loop
+		case now := <-stuckCheck.C:
+			if allStuck(runs, now) {
+				break loop
+			}
+			// 所有模型都只返回了致命错误时停止派发，剩余的请求不会成功
+			if allFatal(runs) {
+				break loop
+			}
+		case slot <- struct{}{}:
+			acquired = true
+		case send <- next:
 			requestCount++
+			runs[next].requestCount++
+			next, acquired = pick(), false
 		}
 	}
+	if acquired {
+		runs[next].release()
+	}
 
 	close(jobs)
-	wg.Wait()
+	if !waitForWorkers(&wg, runs) {
+		// 所有进行中的请求都已卡住：放弃等待，未派发的请求不计入请求数
+		cancelWork()
+		var stuck int64
+		for _, run := range runs {
+			stuck += run.abandon()
+		}
+		for index := range jobs {
+			runs[index].requestCount--
+		}
+		fmt.Printf("  警告: %d 个请求超过超时时间仍未返回，放弃等待并计为超时失败，结果只包含已完成的请求\n", stuck)
+	}
 
-	// 计算总持续时间
-	totalDuration := time.Since(startTime)
+	// 停止进度刷新
+	close(progressDone)
+	progressWg.Wait()
 
-	// 更新结果
-	result.TotalRequests += requestCount
-	result.SuccessRequests += int(successCount)
-	result.FailedRequests += int(failedCount)
-	result.TotalDuration += totalDuration
+	// 计算总持续时间并汇总每个模型的结果
+	totalDuration := time.Since(startTime)
+	for _, run := range runs {
+		run.finish(totalDuration)
+		printRateLimit(run.result)
+		if n := run.result.NearTimeoutRequests; n > 0 {
+			fmt.Printf("  警告: %s 有 %d 个成功请求的延迟超过请求超时时间(%s)的%.0f%%，超时设置可能过紧\n",
+				run.result.ModelName, n, run.timeout, e.config.NearTimeoutRatio*100)
+		}
+	}
 
-	if successCount > 0 {
-		avgLatency := time.Duration(totalLatency / successCount)
-		result.AvgLatency = avgLatency
+	// 没有成功请求并且遇到了致命错误的模型作为测试出错返回，由调用方决定终止测试还是跳过该模型
+	for _, run := range runs {
+		if err := run.fatal(); err != nil {
+			return fmt.Errorf("模型 %s 没有成功的请求: %w", run.mdl.GetName(), err)
+		}
+	}
+	return nil
+}
 
-		result.InputTokens += inputTokens
-		result.OutputTokens += outputTokens
-		result.TotalTokens += inputTokens + outputTokens
+// allFatal 返回是否所有模型都没有成功请求并且遇到了致命错误
+func allFatal(runs []*levelRun) bool {
+	for _, run := range runs {
+		if run.fatal() == nil {
+			return false
+		}
+	}
+	return true
+}
 
-		result.AvgInputTokens = float64(result.InputTokens) / float64(result.SuccessRequests)
-		result.AvgOutputTokens = float64(result.OutputTokens) / float64(result.SuccessRequests)
-		result.AvgTotalTokens = float64(result.TotalTokens) / float64(result.SuccessRequests)
+// acquireNext 占用 next 的并发名额，名额已满时按权重重新选择模型，最多重新选择 4*len(runs) 次
+// 返回最后选择的模型以及是否占用了它的名额
+func acquireNext(runs []*levelRun, next int, pick func() int) (int, bool) {
+	if runs[next].tryAcquire() {
+		return next, true
+	}
+	for i := 0; len(runs) > 1 && i < 4*len(runs); i++ {
+		next = pick()
+		if runs[next].tryAcquire() {
+			return next, true
+		}
+	}
+	return next, false
+}
 
-		result.RequestsPerSec = float64(result.SuccessRequests) / totalDuration.Seconds()
-		result.TokensPerSec = float64(result.TotalTokens) / totalDuration.Seconds()
+// printRateLimit 输出测试期间观察到的限流信息，便于根据剩余配额调整并发度
+func printRateLimit(result *TestResult) {
+	rateLimit := result.RateLimit
+	if rateLimit == nil {
+		return
+	}
+	var parts []string
+	if rateLimit.MinRemainingRequests >= 0 {
+		parts = append(parts, fmt.Sprintf("最少剩余请求数 %d", rateLimit.MinRemainingRequests))
+	}
+	if rateLimit.MinRemainingTokens >= 0 {
+		parts = append(parts, fmt.Sprintf("最少剩余Token数 %d", rateLimit.MinRemainingTokens))
 	}
+	if len(parts) > 0 {
+		fmt.Printf("  %s 限流余量: %s\n", result.ModelName, strings.Join(parts, ", "))
+	}
+	if rateLimit.RateLimitedRequests > 0 {
+		fmt.Printf("  警告: %s 收到 %d 次429限流响应，重试 %d 次\n", result.ModelName, rateLimit.RateLimitedRequests, result.RetriedRequests)
+	}
+}
 
-	// 存储所有延迟数据
-	result.AllLatencies = latencies
+// waitForWorkers 等待所有工作协程结束，所有进行中的请求都已卡住时返回false
+// 卡住的工作协程会在请求最终返回后自行退出，不会再修改统计
+func waitForWorkers(wg *sync.WaitGroup, runs []*levelRun) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return true
+		case now := <-ticker.C:
+			if allStuck(runs, now) {
+				return false
+			}
+		}
+	}
+}
 
-	// 计算延迟百分位
-	if len(latencies) > 0 && len(e.config.LatencyPercentiles) > 0 {
-		result.LatencyPercentiles = make(map[int]time.Duration)
-		for _, p := range e.config.LatencyPercentiles {
-			result.LatencyPercentiles[p] = calculatePercentile(latencies, p)
+// allStuck 返回是否有进行中的请求，并且所有有进行中请求的模型都已卡住
+func allStuck(runs []*levelRun, now time.Time) bool {
+	stuck := false
+	for _, run := range runs {
+		if atomic.LoadInt64(&run.inFlight) == 0 {
+			continue
+		}
+		if !run.stuck(now) {
+			return false
 		}
+		stuck = true
 	}
+	return stuck
+}
 
-	return nil
+// randInt63n 使用引擎的随机数生成器返回[0, n)范围内的随机数，并发安全
+func (e *TestEngine) randInt63n(n int64) int64 {
+	e.rngMutex.Lock()
+	defer e.rngMutex.Unlock()
+	return e.rng.Int63n(n)
+}
+
+// waitJitter 配置了 DispatchJitter 时等待[0, DispatchJitter)范围内的随机时间，ctx取消时提前返回
+// 等待时间由 RandomSeed 初始化的随机数生成器产生，相同的种子产生相同的等待时间序列
+func (e *TestEngine) waitJitter(ctx context.Context) {
+	if e.config.DispatchJitter <= 0 {
+		return
+	}
+
+	e.jitterMutex.Lock()
+	delay := time.Duration(e.jitterRng.Int63n(int64(e.config.DispatchJitter)))
+	e.jitterMutex.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
 }
 
-// 计算百分位数
+// 计算百分位数，没有样本时返回0
 func calculatePercentile(latencies []time.Duration, percentile int) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+
 	// 创建副本并排序
 	sortedLatencies := make([]time.Duration, len(latencies))
 	copy(sortedLatencies, latencies)
@@ -0,0 +1,207 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/lemonlinger/llm-test/model"
+)
+
+// 错误分类
+const (
+	ErrorCategoryTimeout = "timeout"  // 请求超时（context deadline）
+	ErrorCategoryHTTP4xx = "http_4xx" // HTTP 4xx 错误，例如 401、429
+	ErrorCategoryHTTP5xx = "http_5xx" // HTTP 5xx 错误
+	ErrorCategoryNetwork = "network"  // 网络/连接错误，例如拨号失败、连接重置
+	ErrorCategoryParse   = "parse"    // 响应解析错误
+	ErrorCategoryInvalid = "invalid"  // 严格模式下的无效响应，例如空内容或被截断
+	ErrorCategoryOther   = "other"    // 其他错误
+)
+
+// ErrInvalidResponse 严格模式下HTTP请求成功但响应内容不合格
+var ErrInvalidResponse = errors.New("无效响应")
+
+// ErrorCategories 按报告展示顺序排列的所有错误分类
+var ErrorCategories = []string{
+	ErrorCategoryTimeout,
+	ErrorCategoryHTTP4xx,
+	ErrorCategoryHTTP5xx,
+	ErrorCategoryNetwork,
+	ErrorCategoryParse,
+	ErrorCategoryInvalid,
+	ErrorCategoryOther,
+}
+
+// isFatalModelError 返回错误是否说明模型配置有误，例如认证失败（401、403）或模型、接口不存在（404），
+// 这类错误不会随着重试或降低并发而恢复
+func isFatalModelError(err error) bool {
+	var apiErr *model.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case 401, 403, 404:
+		return true
+	}
+	return false
+}
+
+// classifyError 根据错误类型对请求失败进行分类
+func classifyError(err error) string {
+	if errors.Is(err, ErrInvalidResponse) {
+		return ErrorCategoryInvalid
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorCategoryTimeout
+	}
+
+	var apiErr *model.APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode >= 500:
+			return ErrorCategoryHTTP5xx
+		case apiErr.StatusCode >= 400:
+			return ErrorCategoryHTTP4xx
+		default:
+			return ErrorCategoryOther
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorCategoryTimeout
+		}
+		return ErrorCategoryNetwork
+	}
+
+	if errors.Is(err, model.ErrParseResponse) {
+		return ErrorCategoryParse
+	}
+
+	return ErrorCategoryOther
+}
+
+// validateResponse 检查响应是否为空或未正常结束，未返回结束原因时不检查
+func validateResponse(resp *model.LLMResponse) error {
+	// 返回工具调用的响应没有文本内容，以 tool_calls 结束
+	if resp.ToolCalls > 0 {
+		if resp.FinishReason != "" && resp.FinishReason != model.FinishReasonToolCalls && resp.FinishReason != model.FinishReasonStop {
+			return fmt.Errorf("%w: finish_reason=%s", ErrInvalidResponse, resp.FinishReason)
+		}
+		return nil
+	}
+	if strings.TrimSpace(resp.Content) == "" {
+		return fmt.Errorf("%w: 响应内容为空", ErrInvalidResponse)
+	}
+	if resp.FinishReason != "" && resp.FinishReason != model.FinishReasonStop {
+		return fmt.Errorf("%w: finish_reason=%s", ErrInvalidResponse, resp.FinishReason)
+	}
+	return nil
+}
+
+const (
+	// 每个结果保存的出现次数最多的不同错误数
+	maxTopErrors = 10
+	// 错误信息保存的最大字符数，超过时截断
+	maxErrorMessageRunes = 200
+	// 单独计数的不同错误数上限，超过后新出现的错误合并计数，避免错误信息各不相同时占用过多内存
+	maxDistinctErrors = 1000
+	// 超过 maxDistinctErrors 后合并计数的错误信息
+	otherErrorsMessage = "其他错误（不同的错误过多，未单独统计）"
+)
+
+var (
+	// 时间戳，例如 2024-05-01T12:00:00.123Z、2024-05-01 12:00:00
+	timestampPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`)
+	// UUID形式的请求ID
+	uuidPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	// 连接错误中每次不同的本地端口，例如 read tcp 127.0.0.1:53122->127.0.0.1:8080
+	localPortPattern = regexp.MustCompile(`:\d+->`)
+	// 可能是请求ID的较长字符串，由 isRequestID 判断是否替换
+	idPattern = regexp.MustCompile(`[A-Za-z0-9_\-]{16,}`)
+)
+
+// 常见服务商的请求ID前缀，例如 chatcmpl-9xYz...、req_011CKx...、msg_01XF...
+var requestIDPrefixes = []string{"chatcmpl-", "cmpl-", "resp_", "req_", "msg_", "gen-"}
+
+// isRequestID 返回较长的字符串是否像请求ID：以已知的请求ID前缀开头，或者是不含 - 和 _ 分隔的单词、
+// 包含数字的十六进制、base62或纯数字字符串。模型名称（例如 gpt-4o-mini-2024-07-18、
+// claude-3-5-sonnet-20241022）由 - 分隔的单词组成，不会被替换
+func isRequestID(token string) bool {
+	for _, prefix := range requestIDPrefixes {
+		if strings.HasPrefix(token, prefix) {
+			return true
+		}
+	}
+	if strings.ContainsAny(token, "-_") {
+		return false
+	}
+	return strings.ContainsAny(token, "0123456789")
+}
+
+// normalizeErrorMessage 去掉错误信息中每个请求都不同的部分（请求ID、时间戳、本地端口），并截断过长的信息，
+// 使相同原因的错误可以合并计数
+func normalizeErrorMessage(message string) string {
+	message = timestampPattern.ReplaceAllString(message, "<time>")
+	message = uuidPattern.ReplaceAllString(message, "<id>")
+	message = localPortPattern.ReplaceAllString(message, ":<port>->")
+	message = idPattern.ReplaceAllStringFunc(message, func(token string) string {
+		if isRequestID(token) {
+			return "<id>"
+		}
+		return token
+	})
+	message = strings.Join(strings.Fields(message), " ")
+	if runes := []rune(message); len(runes) > maxErrorMessageRunes {
+		message = string(runes[:maxErrorMessageRunes]) + "..."
+	}
+	return message
+}
+
+// errorCounter 并发安全地按规范化后的错误信息统计失败请求数
+type errorCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newErrorCounter() *errorCounter {
+	return &errorCounter{counts: make(map[string]int)}
+}
+
+// add 记录一次失败
+func (c *errorCounter) add(err error) {
+	message := normalizeErrorMessage(err.Error())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.counts[message]; !ok && len(c.counts) >= maxDistinctErrors {
+		message = otherErrorsMessage
+	}
+	c.counts[message]++
+}
+
+// top 返回出现次数最多的n个不同错误，按次数从多到少排列，次数相同时按错误信息排序
+func (c *errorCounter) top(n int) []ErrorCount {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.counts) == 0 {
+		return nil
+	}
+	errs := make([]ErrorCount, 0, len(c.counts))
+	for message, count := range c.counts {
+		errs = append(errs, ErrorCount{Message: message, Count: count})
+	}
+	slices.SortFunc(errs, func(a, b ErrorCount) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Message, b.Message)
+	})
+	return errs[:min(n, len(errs))]
+}
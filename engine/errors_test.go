@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lemonlinger/llm-test/model"
+)
+
+func TestNormalizeErrorMessage(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{"timestamp", "rate limited at 2024-05-01T12:00:00.123Z, retry later", "rate limited at <time>, retry later"},
+		{"timestamp without zone", "server busy 2024-05-01 12:00:00", "server busy <time>"},
+		{"uuid", "request 123e4567-e89b-12d3-a456-426614174000 failed", "request <id> failed"},
+		{"local port", "read tcp 127.0.0.1:53122->127.0.0.1:8080: connection reset by peer", "read tcp 127.0.0.1:<port>->127.0.0.1:8080: connection reset by peer"},
+		{"openai request id", `{"id": "chatcmpl-9xYzAbCdEfGhIjKlMn"}`, `{"id": "<id>"}`},
+		{"anthropic request id", `{"request_id": "req_011CKxAbCdEfGh12"}`, `{"request_id": "<id>"}`},
+		{"hex id", "trace 4bf92f3577b34da6a3ce929d0e0e4736", "trace <id>"},
+		{"numeric id", "request 1714567890123456789 throttled", "request <id> throttled"},
+		{"openai model name", "API请求失败: 模型=gpt-4o-mini-2024-07-18, 状态码=429", "API请求失败: 模型=gpt-4o-mini-2024-07-18, 状态码=429"},
+		{"anthropic model name", "API请求失败: 模型=claude-3-5-sonnet-20241022, 状态码=529", "API请求失败: 模型=claude-3-5-sonnet-20241022, 状态码=529"},
+		{"long word without digits", "unsupported_parameter_combination", "unsupported_parameter_combination"},
+		{"whitespace", "bad\n  gateway", "bad gateway"},
+		{"truncation", strings.Repeat("错", maxErrorMessageRunes+10), strings.Repeat("错", maxErrorMessageRunes) + "..."},
+	} {
+		if got := normalizeErrorMessage(tc.message); got != tc.want {
+			t.Errorf("%s: 规范化结果为 %q，期望 %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// 只有请求ID不同的错误合并计数，按次数从多到少、次数相同时按错误信息排列
+func TestErrorCounterTop(t *testing.T) {
+	c := newErrorCounter()
+	for i := 0; i < 3; i++ {
+		c.add(fmt.Errorf("overloaded, request req_011CKxAbCdEf%04d", i))
+	}
+	c.add(errors.New("b: bad gateway"))
+	c.add(errors.New("a: bad gateway"))
+	c.add(errors.New("b: bad gateway"))
+
+	want := []ErrorCount{
+		{Message: "overloaded, request <id>", Count: 3},
+		{Message: "b: bad gateway", Count: 2},
+		{Message: "a: bad gateway", Count: 1},
+	}
+	if got := c.top(maxTopErrors); !slices.Equal(got, want) {
+		t.Errorf("top 为 %v，期望 %v", got, want)
+	}
+	if got := c.top(1); !slices.Equal(got, want[:1]) {
+		t.Errorf("top(1) 为 %v，期望 %v", got, want[:1])
+	}
+	if got := newErrorCounter().top(maxTopErrors); got != nil {
+		t.Errorf("没有错误时 top 应当为nil，实际为 %v", got)
+	}
+}
+
+// 不同的错误超过 maxDistinctErrors 后，新出现的错误合并到同一项，已有的错误仍然单独计数
+func TestErrorCounterOverflow(t *testing.T) {
+	c := newErrorCounter()
+	for i := 0; i < maxDistinctErrors; i++ {
+		c.add(fmt.Errorf("error %d", i))
+	}
+	c.add(errors.New("error 0"))
+	for i := 0; i < 5; i++ {
+		c.add(fmt.Errorf("new error %d", i))
+	}
+
+	top := c.top(2)
+	want := []ErrorCount{
+		{Message: otherErrorsMessage, Count: 5},
+		{Message: "error 0", Count: 2},
+	}
+	if !slices.Equal(top, want) {
+		t.Errorf("top 为 %v，期望 %v", top, want)
+	}
+	if len(c.counts) != maxDistinctErrors+1 {
+		t.Errorf("不同的错误数为 %d，期望 %d", len(c.counts), maxDistinctErrors+1)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"invalid response", fmt.Errorf("%w: 响应内容为空", ErrInvalidResponse), ErrorCategoryInvalid},
+		{"deadline", fmt.Errorf("发送HTTP请求失败: %w", context.DeadlineExceeded), ErrorCategoryTimeout},
+		{"http 429", &model.APIError{StatusCode: 429}, ErrorCategoryHTTP4xx},
+		{"http 503", &model.APIError{StatusCode: 503}, ErrorCategoryHTTP5xx},
+		{"network", fmt.Errorf("发送HTTP请求失败: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), ErrorCategoryNetwork},
+		{"parse response", fmt.Errorf("%w: %w", model.ErrParseResponse, errors.New("unexpected end of JSON input")), ErrorCategoryParse},
+		// 请求体模板和配置的解析错误不是响应解析失败
+		{"request body template", fmt.Errorf("解析请求体失败: %w", &json.SyntaxError{}), ErrorCategoryOther},
+		{"base url", fmt.Errorf("解析基础URL失败: %w", errors.New("missing protocol scheme")), ErrorCategoryOther},
+	} {
+		if got := classifyError(tc.err); got != tc.want {
+			t.Errorf("%s: 分类为 %s，期望 %s", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lemonlinger/llm-test/config"
+)
+
+const fatalTestConfig = `
+test:
+  concurrency: 2
+  total_requests: 6
+  skip_preflight: true
+  continue_on_model_error: %s
+models:
+  - name: good
+    type: mock
+    params: {latency_ms: 1, output_tokens: 3}
+  - name: unauthorized
+    type: mock
+    params: {latency_ms: 1, error_rate: 1, error_status: 401}
+prompt:
+  user_message: hi
+`
+
+func loadFatalTestConfig(t *testing.T, continueOnModelError string) *config.Config {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := strings.Replace(fatalTestConfig, "%s", continueOnModelError, 1)
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(file)
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	return cfg
+}
+
+// 跳过预检时，认证失败的模型同样应当作为测试出错处理：开启 continue_on_model_error 时跳过该模型
+func TestFatalModelErrorSkipsModelWithoutPreflight(t *testing.T) {
+	results, err := Run(context.Background(), loadFatalTestConfig(t, "true"))
+	if err != nil {
+		t.Fatalf("开启 continue_on_model_error 时不应返回错误: %v", err)
+	}
+
+	failed, ok := results[ResultKey("unauthorized", "", 0)]
+	if !ok || !strings.Contains(failed.ModelError, "401") {
+		t.Fatalf("认证失败的模型应当记录测试出错，实际结果: %+v", failed)
+	}
+	if _, ok := results[ResultKey("unauthorized", "", 2)]; ok {
+		t.Error("测试出错的模型不应保留并发度的结果")
+	}
+	good, ok := results[ResultKey("good", "", 2)]
+	if !ok || good.SuccessRequests == 0 {
+		t.Fatalf("其他模型应当正常测试，实际结果: %+v", good)
+	}
+}
+
+// 未开启 continue_on_model_error 时认证失败终止整个测试
+func TestFatalModelErrorStopsTest(t *testing.T) {
+	_, err := Run(context.Background(), loadFatalTestConfig(t, "false"))
+	if err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Fatalf("认证失败应当终止测试并返回模型的错误，实际为 %v", err)
+	}
+}
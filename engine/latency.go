@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 流式百分位模式下最多保留的延迟样本数，超过后使用水塘抽样，样本用于图表等需要原始数据的场景
+const maxRetainedLatencies = 10000
+
+// latencyRecorder 并发安全地记录请求延迟，同时维护最小值、最大值和标准差等运行统计
+// 默认保留所有样本用于精确计算百分位；流式模式下用t-digest估算百分位，只保留有限的样本
+type latencyRecorder struct {
+	mu        sync.Mutex
+	streaming bool
+	samples   []time.Duration
+	digest    *tDigest
+	// 运行统计，使用Welford算法计算方差
+	count    int64
+	mean     float64
+	m2       float64
+	min, max time.Duration
+	// 直方图桶上界（从小到大）及每个桶的计数，最后一个计数为超过所有上界的请求数
+	buckets []time.Duration
+	counts  []int
+	// 水塘抽样使用的随机数，由调用方提供以便使用引擎的随机种子
+	randInt63n func(n int64) int64
+}
+
+// newLatencyRecorder 创建延迟记录器，streaming为true时使用t-digest估算百分位，buckets为直方图的桶上界
+func newLatencyRecorder(streaming bool, buckets []time.Duration, randInt63n func(n int64) int64) *latencyRecorder {
+	r := &latencyRecorder{
+		streaming:  streaming,
+		buckets:    buckets,
+		counts:     make([]int, len(buckets)+1),
+		randInt63n: randInt63n,
+	}
+	if streaming {
+		r.digest = newTDigest()
+	}
+	return r
+}
+
+// add 记录一个延迟
+func (r *latencyRecorder) add(latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.count++
+	if r.count == 1 || latency < r.min {
+		r.min = latency
+	}
+	if latency > r.max {
+		r.max = latency
+	}
+	x := float64(latency)
+	delta := x - r.mean
+	r.mean += delta / float64(r.count)
+	r.m2 += delta * (x - r.mean)
+	r.counts[sort.Search(len(r.buckets), func(i int) bool { return latency <= r.buckets[i] })]++
+
+	if !r.streaming {
+		r.samples = append(r.samples, latency)
+		return
+	}
+
+	r.digest.add(x)
+	if len(r.samples) < maxRetainedLatencies {
+		r.samples = append(r.samples, latency)
+	} else if i := r.randInt63n(r.count); i < maxRetainedLatencies {
+		r.samples[i] = latency
+	}
+}
+
+// histogram 将other（可以为nil）的计数合并后返回直方图，两者的桶上界必须相同
+func (r *latencyRecorder) histogram(other *latencyRecorder) map[time.Duration]int {
+	histogram := make(map[time.Duration]int, len(r.counts))
+	for i, count := range r.counts {
+		if other != nil {
+			count += other.counts[i]
+		}
+		bound := LatencyHistogramOverflow
+		if i < len(r.buckets) {
+			bound = r.buckets[i]
+		}
+		histogram[bound] = count
+	}
+	return histogram
+}
+
+// stats 返回最小值、最大值和总体标准差，需要在所有请求完成后调用
+func (r *latencyRecorder) stats() (time.Duration, time.Duration, time.Duration) {
+	if r.count == 0 {
+		return 0, 0, 0
+	}
+	return r.min, r.max, time.Duration(math.Sqrt(r.m2 / float64(r.count)))
+}
+
+// percentiles 返回已记录延迟的百分位，测试进行中也可以与 add 并发调用，没有记录时返回nil
+// 精确模式下只在持有锁时复制样本，排序在锁外进行，实时统计不会阻塞工作协程记录延迟
+func (r *latencyRecorder) percentiles(percentiles []int) map[int]time.Duration {
+	r.mu.Lock()
+	if r.count == 0 {
+		r.mu.Unlock()
+		return nil
+	}
+
+	result := make(map[int]time.Duration, len(percentiles))
+	if r.streaming {
+		for _, p := range percentiles {
+			result[p] = time.Duration(r.digest.quantile(float64(p) / 100))
+		}
+		r.mu.Unlock()
+		return result
+	}
+
+	sorted := make([]time.Duration, len(r.samples))
+	copy(sorted, r.samples)
+	r.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for _, p := range percentiles {
+		result[p] = sorted[int(float64(len(sorted)-1)*float64(p)/100.0)]
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试进行中计算百分位与记录延迟并发进行，使用 -race 运行时可以发现锁外访问样本的问题
+func TestLatencyRecorderPercentilesConcurrentWithAdd(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var rngMutex sync.Mutex
+	randInt63n := func(n int64) int64 {
+		rngMutex.Lock()
+		defer rngMutex.Unlock()
+		return rng.Int63n(n)
+	}
+	r := newLatencyRecorder(false, nil, randInt63n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 1; j <= 1000; j++ {
+				r.add(time.Duration(j) * time.Millisecond)
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		if p := r.percentiles([]int{50, 99}); p != nil && p[50] > p[99] {
+			t.Fatalf("P50 %s 大于 P99 %s", p[50], p[99])
+		}
+	}
+	wg.Wait()
+
+	p := r.percentiles([]int{100})
+	if p[100] != time.Second {
+		t.Fatalf("P100 为 %s，期望 1s", p[100])
+	}
+}
+
+// 百分位的边界：P0为最小值、P100为最大值，只有一个样本时所有百分位都是该样本，没有样本时为0或nil
+func TestPercentileBoundaries(t *testing.T) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	latencies := []time.Duration{ms(30), ms(10), ms(50), ms(20), ms(40)}
+
+	for _, tc := range []struct {
+		name      string
+		latencies []time.Duration
+		want      map[int]time.Duration
+	}{
+		{"多个样本", latencies, map[int]time.Duration{0: ms(10), 1: ms(10), 50: ms(30), 100: ms(50)}},
+		{"单个样本", []time.Duration{ms(7)}, map[int]time.Duration{0: ms(7), 1: ms(7), 50: ms(7), 100: ms(7)}},
+	} {
+		for p, want := range tc.want {
+			if got := calculatePercentile(tc.latencies, p); got != want {
+				t.Errorf("%s: calculatePercentile P%d 为 %s，期望 %s", tc.name, p, got, want)
+			}
+		}
+
+		for _, streaming := range []bool{false, true} {
+			r := newLatencyRecorder(streaming, nil, func(n int64) int64 { return 0 })
+			for _, latency := range tc.latencies {
+				r.add(latency)
+			}
+			got := r.percentiles([]int{0, 1, 50, 100})
+			for p, want := range tc.want {
+				// t-digest 只保证最小值、最大值和单个样本精确
+				if streaming && len(tc.latencies) > 1 && p != 0 && p != 100 {
+					continue
+				}
+				if got[p] != want {
+					t.Errorf("%s: 流式=%v 的 P%d 为 %s，期望 %s", tc.name, streaming, p, got[p], want)
+				}
+			}
+		}
+	}
+
+	if got := calculatePercentile(nil, 99); got != 0 {
+		t.Errorf("没有样本时 calculatePercentile 为 %s，期望 0", got)
+	}
+	for _, streaming := range []bool{false, true} {
+		r := newLatencyRecorder(streaming, nil, func(n int64) int64 { return 0 })
+		if got := r.percentiles([]int{50, 100}); got != nil {
+			t.Errorf("流式=%v 没有样本时百分位为 %v，期望 nil", streaming, got)
+		}
+	}
+	if got := newTDigest().quantile(0.99); got != 0 {
+		t.Errorf("空的 t-digest 的 P99 为 %g，期望 0", got)
+	}
+}
@@ -0,0 +1,731 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/model"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// 请求超过超时时间后继续等待的宽限期，超过后认为请求卡住（客户端没有响应context取消），测试中会调小
+var stuckGracePeriod = 5 * time.Second
+
+// errStuckRequest 超过超时时间和宽限期仍未返回、被放弃等待的请求
+var errStuckRequest = fmt.Errorf("请求超过超时时间仍未返回，已放弃等待: %w", context.DeadlineExceeded)
+
+// levelRun 一个模型在一个并发度下的请求统计
+// 同一个并发度可以有多个levelRun共用一个工作协程池（流量混合模式），do 由工作协程并发调用
+type levelRun struct {
+	engine    *TestEngine
+	mdl       model.LLMModel
+	result    *TestResult
+	useStream bool
+	timeout   time.Duration
+
+	// 派发给该模型的请求数，只由派发协程修改
+	requestCount int
+
+	// 模型的 max_concurrency 小于并发度时限制同时发送给该模型的请求数，不限制时为nil
+	// 名额由派发协程在派发前占用，工作协程处理完请求后释放
+	sem chan struct{}
+
+	// 计数器
+	successCount    int64
+	failedCount     int64
+	canceledCount   int64
+	totalLatency    int64
+	inputTokens     int64
+	outputTokens    int64
+	reasoningTokens int64
+	responseBytes   int64
+	// 提示词缓存写入和读取的输入Token数
+	cacheCreationTokens int64
+	cacheReadTokens     int64
+	// 流式成功请求的首Token时间之和及请求数
+	ttftTotal      int64
+	ttftCount      int64
+	estimatedCount int64
+	truncatedCount int64
+	stoppedCount   int64
+	toolCallCount  int64
+	discardedCount int64
+	// 场景配置了 response_schema 时编译后的Schema和响应内容满足、不满足Schema的成功请求数
+	schema             *jsonschema.Schema
+	schemaValidCount   int64
+	schemaInvalidCount int64
+	// 记录了连接耗时的成功请求的TTFB之和及请求数，新建连接的请求数及其DNS、TCP连接、TLS握手耗时之和
+	ttfbTotal    int64
+	ttfbCount    int64
+	newConnCount int64
+	dnsTotal     int64
+	connectTotal int64
+	tlsTotal     int64
+	// 场景配置了 prompt_length 时的提示词填充器和按输入Token数区间的延迟统计
+	padder       *promptPadder
+	inputLengths *inputLengthRecorder
+	// 重试次数和收到的429响应数（包括被重试的请求）
+	retryCount       int64
+	rateLimitedCount int64
+	// 延迟超过请求超时时间 NearTimeoutRatio 的成功请求数
+	nearTimeoutCount int64
+	// 模型配置了多个API密钥时，按请求轮流分配的计数器和每个密钥的请求数、失败数
+	keyCounter  uint64
+	keyRequests []int64
+	keyFailures []int64
+	// 模型配置了代理池时，按请求轮流分配的计数器和每个代理的请求数、失败数
+	proxyCounter  uint64
+	proxyRequests []int64
+	proxyFailures []int64
+	// 响应头中剩余请求数和Token数的最小值，未返回时为-1
+	minRemainingRequests int64
+	minRemainingTokens   int64
+
+	// 进行中的请求数及其峰值，busyTime 为所有请求耗时之和，用于计算平均并发度
+	inFlight     int64
+	peakInFlight int64
+	busyTime     int64
+	// 最近一次有请求开始或结束的时间（UnixNano），用于判断进行中的请求是否卡住
+	lastActivity int64
+
+	// 放弃等待卡住的请求后置为true，之后返回的请求不再修改任何统计
+	// 请求开始和记录结果时持有读锁，放弃时持有写锁，保证放弃之后 finish 读取的统计不会再被修改
+	abandoned   bool
+	recordMutex sync.RWMutex
+
+	// 吞吐量收敛检测记录的每个窗口的RPS，只由采样协程修改
+	rpsWindows []float64
+
+	// 丢弃的请求全部完成的时间，吞吐量从该时间开始计算
+	discardEnd   time.Time
+	discardMutex sync.Mutex
+
+	// 成功和失败请求的延迟分开记录，失败请求（例如超时）默认不计入百分位
+	successLatencies *latencyRecorder
+	failedLatencies  *latencyRecorder
+	// 流式成功请求的首Token时间，用于计算首Token时间的百分位
+	ttfts *latencyRecorder
+	// 能计算输出速率的流式成功请求的单请求输出速率之和及请求数
+	// 只输出了一个token或者所有内容在同一个数据块中到达的请求没有速率，不计入平均值
+	tpsTotal float64
+	tpsCount int64
+	tpsMutex sync.Mutex
+
+	// 保护错误记录的互斥锁
+	errorsMutex sync.Mutex
+	// 按错误信息统计的失败请求数
+	errors *errorCounter
+	// 第一个致命错误（例如认证失败），由 errorsMutex 保护
+	fatalErr error
+
+	// 保护响应样例的互斥锁
+	samplesMutex sync.Mutex
+
+	// 开启 RecordRequests 时保存的请求明细
+	records      []RequestRecord
+	recordsMutex sync.Mutex
+}
+
+// newLevelRun 创建模型在一个并发度下的请求统计
+func (e *TestEngine) newLevelRun(mdl model.LLMModel, prompt config.PromptConfig, result *TestResult) *levelRun {
+	// 确定是否使用流式输出：优先使用模型特定设置，如果未设置则使用全局设置
+	// 对比流式模式下由结果的模式决定
+	useStream := prompt.Stream
+	if modelStream := mdl.GetStreamSetting(); modelStream != nil {
+		useStream = *modelStream
+	}
+	if result.StreamMode != "" {
+		useStream = result.StreamMode == StreamModeStream
+	}
+
+	// 确定请求超时：优先使用模型特定设置，如果未设置则使用全局设置
+	timeout := e.config.RequestTimeout
+	if modelTimeout := mdl.GetRequestTimeout(); modelTimeout > 0 {
+		timeout = modelTimeout
+	}
+
+	run := &levelRun{
+		engine:               e,
+		mdl:                  mdl,
+		result:               result,
+		useStream:            useStream,
+		timeout:              timeout,
+		minRemainingRequests: -1,
+		minRemainingTokens:   -1,
+		successLatencies:     newLatencyRecorder(e.config.StreamingPercentiles, e.config.LatencyBuckets, e.randInt63n),
+		failedLatencies:      newLatencyRecorder(e.config.StreamingPercentiles, e.config.LatencyBuckets, e.randInt63n),
+		ttfts:                newLatencyRecorder(e.config.StreamingPercentiles, nil, e.randInt63n),
+		errors:               newErrorCounter(),
+	}
+	if limiter, ok := mdl.(model.ConcurrencyLimiter); ok {
+		if maxConcurrency := limiter.MaxConcurrency(); maxConcurrency > 0 && maxConcurrency < result.ConcurrencyLevel {
+			run.sem = make(chan struct{}, maxConcurrency)
+			result.ConcurrencyCap = maxConcurrency
+		}
+	}
+	run.schema = e.responseSchema(prompt)
+	if padder := newPromptPadder(prompt); padder != nil {
+		run.padder = padder
+		run.inputLengths = newInputLengthRecorder(prompt.PromptLength.Buckets, e.config.StreamingPercentiles, e.randInt63n)
+	}
+	if rotator, ok := mdl.(model.APIKeyRotator); ok && rotator.APIKeyCount() > 1 {
+		run.keyRequests = make([]int64, rotator.APIKeyCount())
+		run.keyFailures = make([]int64, rotator.APIKeyCount())
+	}
+	if rotator, ok := mdl.(model.ProxyRotator); ok && len(rotator.ProxyNames()) > 0 {
+		run.proxyRequests = make([]int64, len(rotator.ProxyNames()))
+		run.proxyFailures = make([]int64, len(rotator.ProxyNames()))
+	}
+	return run
+}
+
+// tryAcquire 尝试占用模型的一个并发名额，模型没有并发上限或有空闲名额时返回true
+func (r *levelRun) tryAcquire() bool {
+	if r.sem == nil {
+		return true
+	}
+	select {
+	case r.sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release 释放派发时占用的并发名额
+func (r *levelRun) release() {
+	if r.sem != nil {
+		<-r.sem
+	}
+}
+
+// do 发送一个请求并记录结果
+func (r *levelRun) do(ctx context.Context, prompt config.PromptConfig) {
+	cfg := r.engine.config
+
+	// 合成提示词在请求开始前生成，重试的请求使用相同的提示词
+	targetTokens := 0
+	if r.padder != nil {
+		prompt, targetTokens = r.engine.padPrompt(r.padder, prompt)
+	}
+
+	r.recordMutex.RLock()
+	if r.abandoned {
+		r.recordMutex.RUnlock()
+		return
+	}
+	r.enter()
+	r.recordMutex.RUnlock()
+
+	// 状态码在 RetryableStatuses 中的错误最多重试 MaxRetries 次，延迟只统计最后一次请求
+	start := time.Now()
+	resp, latency, err := r.attempt(ctx, prompt)
+	for retries := 0; err != nil && retries < cfg.MaxRetries && ctx.Err() == nil; retries++ {
+		var apiErr *model.APIError
+		if !errors.As(err, &apiErr) || !apiErr.Retryable(cfg.RetryableStatuses) {
+			break
+		}
+		if !sleepContext(ctx, r.retryDelay(apiErr, retries)) {
+			break
+		}
+		atomic.AddInt64(&r.retryCount, 1)
+		resp, latency, err = r.attempt(ctx, prompt)
+	}
+
+	// 请求已被当作卡住的请求放弃，结果不再计入统计
+	r.recordMutex.RLock()
+	defer r.recordMutex.RUnlock()
+	if r.abandoned {
+		return
+	}
+	r.leave(time.Since(start))
+
+	// 因整体测试被取消而中断的请求不计入统计
+	if err != nil && ctx.Err() != nil {
+		atomic.AddInt64(&r.canceledCount, 1)
+		return
+	}
+
+	if err == nil {
+		switch resp.FinishReason {
+		case model.FinishReasonLength:
+			atomic.AddInt64(&r.truncatedCount, 1)
+		case model.FinishReasonStop:
+			atomic.AddInt64(&r.stoppedCount, 1)
+		}
+		// 严格模式下，空响应或未正常结束的响应计为失败
+		if cfg.StrictSuccess {
+			err = validateResponse(resp)
+		}
+	}
+
+	if err != nil {
+		r.engine.logger.Debugf("测试模型 %s 失败: %v", r.mdl.GetName(), err)
+		atomic.AddInt64(&r.failedCount, 1)
+		r.failedLatencies.add(latency)
+		r.errorsMutex.Lock()
+		r.result.ErrorsByCategory[classifyError(err)]++
+		if r.fatalErr == nil && isFatalModelError(err) {
+			r.fatalErr = err
+		}
+		r.errorsMutex.Unlock()
+		r.errors.add(err)
+		r.record(start, latency, resp, err)
+		return
+	}
+
+	// 延迟接近超时时间的成功请求说明超时设置过紧，继续加压可能开始超时失败
+	if float64(latency) > float64(r.timeout)*cfg.NearTimeoutRatio {
+		atomic.AddInt64(&r.nearTimeoutCount, 1)
+	}
+
+	if discarded := atomic.AddInt64(&r.discardedCount, 1); discarded <= int64(cfg.DiscardFirstN) {
+		// 前N个成功请求只计入请求数，不计入延迟、Token等统计
+		atomic.AddInt64(&r.successCount, 1)
+		if discarded == int64(cfg.DiscardFirstN) {
+			r.discardMutex.Lock()
+			r.discardEnd = time.Now()
+			r.discardMutex.Unlock()
+		}
+		return
+	}
+
+	atomic.AddInt64(&r.successCount, 1)
+	atomic.AddInt64(&r.totalLatency, int64(latency))
+	r.record(start, latency, resp, nil)
+	r.successLatencies.add(latency)
+	atomic.AddInt64(&r.inputTokens, int64(resp.InputTokens))
+	atomic.AddInt64(&r.outputTokens, int64(resp.OutputTokens))
+	atomic.AddInt64(&r.reasoningTokens, int64(resp.ReasoningTokens))
+	atomic.AddInt64(&r.responseBytes, resp.ResponseBytes)
+	atomic.AddInt64(&r.cacheCreationTokens, int64(resp.CacheCreationInputTokens))
+	atomic.AddInt64(&r.cacheReadTokens, int64(resp.CacheReadInputTokens))
+	if resp.TimeToFirstToken > 0 {
+		atomic.AddInt64(&r.ttftTotal, int64(resp.TimeToFirstToken))
+		atomic.AddInt64(&r.ttftCount, 1)
+		r.ttfts.add(resp.TimeToFirstToken)
+	}
+	if resp.TokensPerSecond > 0 {
+		r.tpsMutex.Lock()
+		r.tpsTotal += resp.TokensPerSecond
+		r.tpsCount++
+		r.tpsMutex.Unlock()
+	}
+	if resp.TTFB > 0 {
+		atomic.AddInt64(&r.ttfbTotal, int64(resp.TTFB))
+		atomic.AddInt64(&r.ttfbCount, 1)
+		if !resp.ConnReused {
+			atomic.AddInt64(&r.newConnCount, 1)
+			atomic.AddInt64(&r.dnsTotal, int64(resp.DNSTime))
+			atomic.AddInt64(&r.connectTotal, int64(resp.ConnectTime))
+			atomic.AddInt64(&r.tlsTotal, int64(resp.TLSTime))
+		}
+	}
+	if r.inputLengths != nil {
+		inputTokens := resp.InputTokens
+		if inputTokens == 0 {
+			inputTokens = targetTokens
+		}
+		r.inputLengths.add(inputTokens, latency, resp.TimeToFirstToken)
+	}
+	if resp.TokensEstimated {
+		atomic.AddInt64(&r.estimatedCount, 1)
+	}
+	if resp.ToolCalls > 0 {
+		atomic.AddInt64(&r.toolCallCount, 1)
+	}
+	// 返回工具调用的响应没有文本内容，不参与Schema校验
+	if r.schema != nil && resp.ToolCalls == 0 {
+		if err := validateSchemaContent(r.schema, resp.Content); err != nil {
+			atomic.AddInt64(&r.schemaInvalidCount, 1)
+			r.errorsMutex.Lock()
+			if len(r.result.SchemaErrors) < maxSchemaErrors && !slices.Contains(r.result.SchemaErrors, err.Error()) {
+				r.result.SchemaErrors = append(r.result.SchemaErrors, err.Error())
+			}
+			r.errorsMutex.Unlock()
+		} else {
+			atomic.AddInt64(&r.schemaValidCount, 1)
+		}
+	}
+	if cfg.SampleResponses > 0 {
+		r.samplesMutex.Lock()
+		if len(r.result.SampleResponses) < cfg.SampleResponses {
+			r.result.SampleResponses = append(r.result.SampleResponses, resp.Content)
+		}
+		r.samplesMutex.Unlock()
+	}
+}
+
+// record 开启 RecordRequests 时保存一个请求的明细，设置了请求回调时发送给回调
+func (r *levelRun) record(start time.Time, latency time.Duration, resp *model.LLMResponse, err error) {
+	callback := r.engine.requestCallback
+	if !r.engine.config.RecordRequests && callback == nil {
+		return
+	}
+	record := RequestRecord{
+		StartTime: start,
+		Latency:   latency,
+		Success:   err == nil,
+	}
+	if resp != nil {
+		record.TimeToFirstToken = resp.TimeToFirstToken
+		record.InputTokens = resp.InputTokens
+		record.OutputTokens = resp.OutputTokens
+	}
+	if err != nil {
+		record.ErrorCategory = classifyError(err)
+	}
+
+	if callback != nil {
+		callback(RequestEvent{
+			ModelName:        r.result.ModelName,
+			Scenario:         r.result.Scenario,
+			StreamMode:       r.result.StreamMode,
+			ConcurrencyLevel: r.result.ConcurrencyLevel,
+			RequestRecord:    record,
+		})
+	}
+	if !r.engine.config.RecordRequests {
+		return
+	}
+	r.recordsMutex.Lock()
+	r.records = append(r.records, record)
+	r.recordsMutex.Unlock()
+}
+
+// attempt 发送一次请求并记录响应头中的限流信息
+func (r *levelRun) attempt(ctx context.Context, prompt config.PromptConfig) (*model.LLMResponse, time.Duration, error) {
+	// 单个请求的超时从根上下文派生，根上下文取消时进行中的请求也会被取消
+	reqCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	if prompt.Tools != "" {
+		reqCtx = context.WithValue(reqCtx, model.ToolsContextKey, json.RawMessage(prompt.Tools))
+	}
+	if len(prompt.Messages) > 0 {
+		reqCtx = context.WithValue(reqCtx, model.MessagesContextKey, prompt.Messages)
+	}
+	if len(prompt.Images) > 0 {
+		reqCtx = context.WithValue(reqCtx, model.ImagesContextKey, prompt.Images)
+	}
+	// 配置了多个API密钥时按请求轮流分配，重试的请求换用下一个密钥
+	keyIndex := -1
+	if len(r.keyRequests) > 0 {
+		keyIndex = int((atomic.AddUint64(&r.keyCounter, 1) - 1) % uint64(len(r.keyRequests)))
+		reqCtx = context.WithValue(reqCtx, model.APIKeyIndexContextKey, keyIndex)
+	}
+	// 配置了代理池时同样按请求轮流分配代理
+	proxyIndex := -1
+	if len(r.proxyRequests) > 0 {
+		proxyIndex = int((atomic.AddUint64(&r.proxyCounter, 1) - 1) % uint64(len(r.proxyRequests)))
+		reqCtx = context.WithValue(reqCtx, model.ProxyIndexContextKey, proxyIndex)
+	}
+
+	// 重试时同样刷新活动时间，避免多次重试的请求被误判为卡住
+	atomic.StoreInt64(&r.lastActivity, time.Now().UnixNano())
+	start := time.Now()
+	resp, err := r.mdl.GenerateResponse(reqCtx, prompt.SystemMessage, prompt.UserMessage, r.useStream)
+	latency := time.Since(start)
+	atomic.StoreInt64(&r.lastActivity, time.Now().UnixNano())
+	// 因整体测试被取消而中断的请求不计入密钥和代理统计
+	if keyIndex >= 0 && ctx.Err() == nil {
+		atomic.AddInt64(&r.keyRequests[keyIndex], 1)
+		if err != nil {
+			atomic.AddInt64(&r.keyFailures[keyIndex], 1)
+		}
+	}
+	if proxyIndex >= 0 && ctx.Err() == nil {
+		atomic.AddInt64(&r.proxyRequests[proxyIndex], 1)
+		if err != nil {
+			atomic.AddInt64(&r.proxyFailures[proxyIndex], 1)
+		}
+	}
+
+	if err == nil {
+		r.observeRateLimit(resp.RateLimit)
+		return resp, latency, nil
+	}
+	var apiErr *model.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.StatusCode == http.StatusTooManyRequests {
+			atomic.AddInt64(&r.rateLimitedCount, 1)
+		}
+		r.observeRateLimit(apiErr.RateLimit)
+	}
+	return resp, latency, err
+}
+
+// observeRateLimit 更新观察到的剩余请求数和Token数的最小值
+func (r *levelRun) observeRateLimit(rateLimit *model.RateLimit) {
+	if rateLimit == nil {
+		return
+	}
+	storeMin(&r.minRemainingRequests, int64(rateLimit.RemainingRequests))
+	storeMin(&r.minRemainingTokens, int64(rateLimit.RemainingTokens))
+}
+
+// storeMin 将 value 与 addr 中的值比较并保存较小者，addr 为-1（尚未记录）时直接保存，value 为负数时忽略
+func storeMin(addr *int64, value int64) {
+	if value < 0 {
+		return
+	}
+	for {
+		current := atomic.LoadInt64(addr)
+		if current >= 0 && current <= value {
+			return
+		}
+		if atomic.CompareAndSwapInt64(addr, current, value) {
+			return
+		}
+	}
+}
+
+// 没有 Retry-After 时重试的初始等待时间，之后每次翻倍
+const retryBaseDelay = 100 * time.Millisecond
+
+// retryDelay 返回第 retries+1 次重试前的等待时间：优先使用服务端返回的 Retry-After，
+// 否则按100ms、200ms、400ms...指数退避；最长不超过请求超时时间，避免等待中的请求被判断为卡住
+func (r *levelRun) retryDelay(apiErr *model.APIError, retries int) time.Duration {
+	delay := apiErr.RetryAfter()
+	if delay <= 0 {
+		delay = retryBaseDelay << min(retries, 10)
+	}
+	return min(delay, r.timeout)
+}
+
+// sleepContext 等待指定时间，ctx取消时提前返回false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// enter 请求开始时增加进行中的请求数并更新峰值
+func (r *levelRun) enter() {
+	atomic.StoreInt64(&r.lastActivity, time.Now().UnixNano())
+	current := atomic.AddInt64(&r.inFlight, 1)
+	for {
+		peak := atomic.LoadInt64(&r.peakInFlight)
+		if current <= peak || atomic.CompareAndSwapInt64(&r.peakInFlight, peak, current) {
+			return
+		}
+	}
+}
+
+// leave 请求结束时减少进行中的请求数，elapsed 为包括重试在内的总耗时
+func (r *levelRun) leave(elapsed time.Duration) {
+	atomic.StoreInt64(&r.lastActivity, time.Now().UnixNano())
+	atomic.AddInt64(&r.inFlight, -1)
+	atomic.AddInt64(&r.busyTime, int64(elapsed))
+}
+
+// stuck 返回是否有进行中的请求，并且在超时时间加宽限期内没有任何请求开始或结束
+// 正常的请求最晚在超时时间后返回，满足该条件说明所有进行中的请求都已卡住
+func (r *levelRun) stuck(now time.Time) bool {
+	if atomic.LoadInt64(&r.inFlight) == 0 {
+		return false
+	}
+	return now.Sub(time.Unix(0, atomic.LoadInt64(&r.lastActivity))) > r.timeout+stuckGracePeriod
+}
+
+// fatal 返回没有成功请求时遇到的致命错误，此时继续测试该模型没有意义
+// 有成功请求或者没有致命错误时返回nil
+func (r *levelRun) fatal() error {
+	if atomic.LoadInt64(&r.successCount) > 0 {
+		return nil
+	}
+	r.errorsMutex.Lock()
+	defer r.errorsMutex.Unlock()
+	return r.fatalErr
+}
+
+// abandon 放弃等待进行中的请求，将它们计为超时失败，返回放弃的请求数
+func (r *levelRun) abandon() int64 {
+	r.recordMutex.Lock()
+	defer r.recordMutex.Unlock()
+
+	r.abandoned = true
+	stuck := atomic.LoadInt64(&r.inFlight)
+	r.failedCount += stuck
+	for i := int64(0); i < stuck; i++ {
+		r.result.ErrorsByCategory[classifyError(errStuckRequest)]++
+		r.errors.add(errStuckRequest)
+	}
+	return stuck
+}
+
+// finish 在所有请求完成后汇总统计到测试结果
+func (r *levelRun) finish(totalDuration time.Duration) {
+	cfg := r.engine.config
+	result := r.result
+
+	// 丢弃的请求不计入统计，吞吐量只按丢弃结束后的时间计算
+	discarded := min(r.discardedCount, int64(cfg.DiscardFirstN))
+	measuredCount := r.successCount - discarded
+	measuredDuration := totalDuration
+	if !r.discardEnd.IsZero() {
+		measuredDuration = time.Since(r.discardEnd)
+	}
+
+	// 更新结果
+	result.TotalRequests += r.requestCount - int(r.canceledCount)
+	result.SuccessRequests += int(r.successCount)
+	result.FailedRequests += int(r.failedCount)
+	result.EstimatedTokenRequests += int(r.estimatedCount)
+	result.TruncatedRequests += int(r.truncatedCount)
+	result.NearTimeoutRequests += int(r.nearTimeoutCount)
+	result.StoppedRequests += int(r.stoppedCount)
+	result.ToolCallRequests += int(r.toolCallCount)
+	result.SchemaValidRequests += int(r.schemaValidCount)
+	result.SchemaInvalidRequests += int(r.schemaInvalidCount)
+	result.DiscardedRequests += int(discarded)
+	// 放弃等待的请求可能仍在重试，限流统计使用原子读取
+	result.RetriedRequests += int(atomic.LoadInt64(&r.retryCount))
+	rateLimited := atomic.LoadInt64(&r.rateLimitedCount)
+	minRequests := atomic.LoadInt64(&r.minRemainingRequests)
+	minTokens := atomic.LoadInt64(&r.minRemainingTokens)
+	if rateLimited > 0 || minRequests >= 0 || minTokens >= 0 {
+		result.RateLimit = &RateLimitStats{
+			RateLimitedRequests:  int(rateLimited),
+			MinRemainingRequests: int(minRequests),
+			MinRemainingTokens:   int(minTokens),
+		}
+	}
+	result.TotalDuration += totalDuration
+	result.TopErrors = r.errors.top(maxTopErrors)
+
+	if rotator, ok := r.mdl.(model.APIKeyRotator); ok && len(r.keyRequests) > 0 {
+		result.APIKeys = make([]APIKeyStats, len(r.keyRequests))
+		for i := range r.keyRequests {
+			result.APIKeys[i] = APIKeyStats{
+				Key:      rotator.APIKeyLabel(i),
+				Requests: int(atomic.LoadInt64(&r.keyRequests[i])),
+				Failed:   int(atomic.LoadInt64(&r.keyFailures[i])),
+			}
+		}
+	}
+	if rotator, ok := r.mdl.(model.ProxyRotator); ok && len(r.proxyRequests) > 0 {
+		names := rotator.ProxyNames()
+		result.Proxies = make([]ProxyStats, len(r.proxyRequests))
+		for i := range r.proxyRequests {
+			result.Proxies[i] = ProxyStats{
+				Name:     names[i],
+				Requests: int(atomic.LoadInt64(&r.proxyRequests[i])),
+				Failed:   int(atomic.LoadInt64(&r.proxyFailures[i])),
+			}
+		}
+	}
+
+	result.Requests = r.records
+	if r.inputLengths != nil {
+		result.InputLengths = r.inputLengths.stats()
+	}
+
+	if convergence := cfg.Convergence; convergence != nil {
+		result.RPSWindows = r.rpsWindows
+		result.TimeToSteadyState, result.Converged = detectConvergence(r.rpsWindows, convergence)
+	}
+
+	// 实际达到的并发度：平均值为请求耗时之和除以测试时长（即时间加权的平均进行中请求数）
+	result.PeakConcurrency = int(r.peakInFlight)
+	if totalDuration > 0 {
+		result.AvgConcurrency = float64(r.busyTime) / float64(totalDuration)
+	}
+
+	if measuredCount > 0 {
+		avgLatency := time.Duration(r.totalLatency / measuredCount)
+		result.AvgLatency = avgLatency
+		result.MinLatency, result.MaxLatency, result.StdDevLatency = r.successLatencies.stats()
+		if r.ttftCount > 0 {
+			result.AvgTimeToFirstToken = time.Duration(r.ttftTotal / r.ttftCount)
+			result.TTFTPercentiles = r.ttfts.percentiles(cfg.LatencyPercentiles)
+		}
+		if r.tpsCount > 0 {
+			result.AvgTokensPerSecond = r.tpsTotal / float64(r.tpsCount)
+		}
+		if r.ttfbCount > 0 {
+			result.AvgTTFB = time.Duration(r.ttfbTotal / r.ttfbCount)
+			result.NewConnRequests = int(r.newConnCount)
+		}
+		if r.newConnCount > 0 {
+			result.AvgDNSTime = time.Duration(r.dnsTotal / r.newConnCount)
+			result.AvgConnectTime = time.Duration(r.connectTotal / r.newConnCount)
+			result.AvgTLSTime = time.Duration(r.tlsTotal / r.newConnCount)
+		}
+
+		result.InputTokens += r.inputTokens
+		result.OutputTokens += r.outputTokens
+		result.TotalTokens += r.inputTokens + r.outputTokens
+		result.ReasoningTokens += r.reasoningTokens
+		result.ResponseBytes += r.responseBytes
+		result.CacheCreationInputTokens += r.cacheCreationTokens
+		result.CacheReadInputTokens += r.cacheReadTokens
+
+		result.AvgInputTokens = float64(result.InputTokens) / float64(measuredCount)
+		result.AvgOutputTokens = float64(result.OutputTokens) / float64(measuredCount)
+		result.AvgTotalTokens = float64(result.TotalTokens) / float64(measuredCount)
+		result.AvgReasoningTokens = float64(result.ReasoningTokens) / float64(measuredCount)
+		result.AvgResponseBytes = float64(result.ResponseBytes) / float64(measuredCount)
+		result.AvgCacheCreationInputTokens = float64(result.CacheCreationInputTokens) / float64(measuredCount)
+		result.AvgCacheReadInputTokens = float64(result.CacheReadInputTokens) / float64(measuredCount)
+
+		result.RequestsPerSec = float64(measuredCount) / measuredDuration.Seconds()
+		result.TokensPerSec = float64(result.TotalTokens) / measuredDuration.Seconds()
+		result.BytesPerSec = float64(result.ResponseBytes) / measuredDuration.Seconds()
+
+		// 根据Token价格估算费用
+		inputPrice, outputPrice := r.mdl.GetPricing()
+		if inputPrice > 0 || outputPrice > 0 {
+			result.TotalCost = float64(result.InputTokens)/1000*inputPrice + float64(result.OutputTokens)/1000*outputPrice
+			result.AvgCostPerRequest = result.TotalCost / float64(measuredCount)
+		}
+	}
+
+	// 存储延迟数据，默认只包含成功请求；流式百分位模式下只是有限的抽样
+	latencies := r.successLatencies.samples
+	if cfg.IncludeFailedInLatency {
+		latencies = append(latencies, r.failedLatencies.samples...)
+	}
+	result.AllLatencies = latencies
+
+	// 延迟直方图统计与百分位相同的请求
+	if len(latencies) > 0 && len(cfg.LatencyBuckets) > 0 {
+		var failed *latencyRecorder
+		if cfg.IncludeFailedInLatency {
+			failed = r.failedLatencies
+		}
+		result.LatencyHistogram = r.successLatencies.histogram(failed)
+	}
+
+	// 计算延迟百分位
+	if len(latencies) > 0 && len(cfg.LatencyPercentiles) > 0 {
+		result.LatencyPercentiles = make(map[int]time.Duration)
+		if cfg.StreamingPercentiles {
+			digest := r.successLatencies.digest
+			if cfg.IncludeFailedInLatency {
+				digest = newTDigest()
+				digest.merge(r.successLatencies.digest)
+				digest.merge(r.failedLatencies.digest)
+			}
+			for _, p := range cfg.LatencyPercentiles {
+				result.LatencyPercentiles[p] = time.Duration(digest.quantile(float64(p) / 100))
+			}
+		} else {
+			for _, p := range cfg.LatencyPercentiles {
+				result.LatencyPercentiles[p] = calculatePercentile(latencies, p)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// LiveStats 测试进行中某个模型在当前并发度下的实时统计
+type LiveStats struct {
+	ModelName        string
+	Scenario         string
+	StreamMode       string
+	ConcurrencyLevel int
+	Elapsed          time.Duration // 从该并发度开始到现在的时间
+	Completed        int           // 已完成的请求数（成功和失败）
+	Success          int
+	Failed           int
+	InFlight         int     // 当前进行中的请求数
+	RequestsPerSec   float64 // 从该并发度开始到现在的平均每秒完成请求数
+	// 成功请求的延迟百分位，百分位与 LatencyPercentiles 配置相同，还没有成功请求时为nil
+	LatencyPercentiles map[int]time.Duration
+}
+
+// SuccessRate 返回已完成请求的成功率（0-100），没有完成的请求时为0
+func (s LiveStats) SuccessRate() float64 {
+	if s.Completed == 0 {
+		return 0
+	}
+	return float64(s.Success) / float64(s.Completed) * 100
+}
+
+// SetLiveStatsCallback 设置测试进行中定期调用的回调，需要在Run之前调用
+// 每个并发度测试期间大约每500ms调用一次，参数为同时测试的每个模型的实时统计（流量混合模式下有多个）。
+// 回调在单独的goroutine中执行，与 SetResultCallback 的回调可能并发调用
+func (e *TestEngine) SetLiveStatsCallback(callback func([]LiveStats)) {
+	e.liveStatsCallback = callback
+}
+
+// liveStats 汇总每个模型当前的实时统计
+func (e *TestEngine) liveStats(runs []*levelRun, startTime time.Time) []LiveStats {
+	elapsed := time.Since(startTime)
+	stats := make([]LiveStats, 0, len(runs))
+	for _, run := range runs {
+		success := int(atomic.LoadInt64(&run.successCount))
+		failed := int(atomic.LoadInt64(&run.failedCount))
+		stats = append(stats, LiveStats{
+			ModelName:          run.result.ModelName,
+			Scenario:           run.result.Scenario,
+			StreamMode:         run.result.StreamMode,
+			ConcurrencyLevel:   run.result.ConcurrencyLevel,
+			Elapsed:            elapsed,
+			Completed:          success + failed,
+			Success:            success,
+			Failed:             failed,
+			InFlight:           int(atomic.LoadInt64(&run.inFlight)),
+			RequestsPerSec:     float64(success+failed) / elapsed.Seconds(),
+			LatencyPercentiles: run.successLatencies.percentiles(e.config.LatencyPercentiles),
+		})
+	}
+	return stats
+}
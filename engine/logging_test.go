@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
+)
+
+// 失败请求的错误信息只在debug级别逐条输出，quiet和info级别下只有汇总结果
+func TestFailedRequestLoggedOnlyAtDebug(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+test:
+  concurrency: 2
+  total_requests: 10
+  skip_preflight: true
+  max_retries: 0
+models:
+  - name: failing
+    type: mock
+    params: {latency_ms: 0, error_rate: 1, error_status: 500}
+prompt:
+  user_message: hi
+`
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	for _, tc := range []struct {
+		level logging.Level
+		lines int
+	}{
+		{logging.LevelQuiet, 0},
+		{logging.LevelInfo, 0},
+		{logging.LevelDebug, 10},
+	} {
+		cfg, err := config.LoadConfig(file)
+		if err != nil {
+			t.Fatalf("加载配置失败: %v", err)
+		}
+		testEngine, err := NewTestEngineFromConfig(cfg, logging.New(tc.level))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Reset()
+		results, err := testEngine.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := results[ResultKey("failing", "", 2)].FailedRequests; got != 10 {
+			t.Fatalf("%s: 失败请求数为 %d，期望 10", tc.level, got)
+		}
+		if got := strings.Count(buf.String(), "测试模型 failing 失败"); got != tc.lines {
+			t.Errorf("%s 级别下输出了 %d 条失败日志，期望 %d", tc.level, got, tc.lines)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package engine
+
+import "testing"
+
+// 合并结果时相同键的结果使用本次的结果，其他结果保留
+func TestMergeResults(t *testing.T) {
+	oldShared := &TestResult{ModelName: "a", ConcurrencyLevel: 1, TotalRequests: 10}
+	oldOnly := &TestResult{ModelName: "a", ConcurrencyLevel: 2, TotalRequests: 10}
+	newShared := &TestResult{ModelName: "a", ConcurrencyLevel: 1, TotalRequests: 20}
+	newOnly := &TestResult{ModelName: "b", ConcurrencyLevel: 1, TotalRequests: 20}
+
+	previous := map[string]*TestResult{oldShared.Key(): oldShared, oldOnly.Key(): oldOnly}
+	current := map[string]*TestResult{newShared.Key(): newShared, newOnly.Key(): newOnly}
+	merged := MergeResults(previous, current)
+
+	want := map[string]*TestResult{
+		oldShared.Key(): newShared,
+		oldOnly.Key():   oldOnly,
+		newOnly.Key():   newOnly,
+	}
+	if len(merged) != len(want) {
+		t.Fatalf("合并后有 %d 个结果，期望 %d 个", len(merged), len(want))
+	}
+	for key, result := range want {
+		if merged[key] != result {
+			t.Errorf("%s 的结果为 %+v，期望 %+v", key, merged[key], result)
+		}
+	}
+	if len(previous) != 2 {
+		t.Error("合并不应修改之前的结果集")
+	}
+
+	if merged := MergeResults(nil, current); len(merged) != len(current) {
+		t.Errorf("之前的结果集为nil时合并后有 %d 个结果，期望 %d 个", len(merged), len(current))
+	}
+}
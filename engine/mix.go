@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/model"
+)
+
+// runTrafficMix 流量混合模式：每个并发度下所有混合的模型共用一个工作协程池，
+// 每个请求按权重随机选择模型，结果仍然按模型分别统计
+func (e *TestEngine) runTrafficMix(ctx context.Context, results map[string]*TestResult) error {
+	modelsByName := make(map[string]model.LLMModel, len(e.models))
+	for _, mdl := range e.models {
+		modelsByName[mdl.GetName()] = mdl
+	}
+
+	models := make([]model.LLMModel, 0, len(e.config.TrafficMix))
+	weights := make([]int64, 0, len(e.config.TrafficMix))
+	for _, entry := range e.config.TrafficMix {
+		mdl, ok := modelsByName[entry.Model]
+		if !ok {
+			return fmt.Errorf("流量混合中的模型 %s 未初始化", entry.Model)
+		}
+		models = append(models, mdl)
+		weights = append(weights, int64(entry.Weight))
+	}
+
+	concurrencyLevels := e.config.ConcurrencyLevels
+	if len(concurrencyLevels) == 0 {
+		concurrencyLevels = []int{e.config.Concurrency}
+	}
+
+	mix := make([]string, 0, len(e.config.TrafficMix))
+	for _, entry := range e.config.TrafficMix {
+		mix = append(mix, fmt.Sprintf("%s(权重%d)", entry.Model, entry.Weight))
+	}
+	fmt.Printf("正在进行流量混合测试: %s\n", strings.Join(mix, ", "))
+	fmt.Printf("  使用并发度配置: %v\n", concurrencyLevels)
+
+	// 混合的模型共用工作协程池，预检失败的模型无法按配置的比例测试：
+	// 开启 continue_on_model_error 时从混合中去掉该模型，其余模型按原来的权重继续测试，否则终止测试
+	if !e.config.SkipPreflight {
+		failed := make(map[string]bool)
+		for _, mdl := range models {
+			err := e.preflight(ctx, mdl)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				if !e.config.ContinueOnModelError {
+					return fmt.Errorf("模型 %s %w", mdl.GetName(), err)
+				}
+				fmt.Printf("  模型 %s 预检失败，从流量混合中去掉该模型: %v\n", mdl.GetName(), err)
+				recordModelError(results, mdl.GetName(), err)
+				failed[mdl.GetName()] = true
+			}
+		}
+		models, weights = withoutModels(models, weights, failed)
+	}
+
+	for _, prompt := range e.prompts {
+		if prompt.Name != "" {
+			fmt.Printf("  场景: %s\n", prompt.Name)
+		}
+
+		for _, concurrency := range concurrencyLevels {
+			if ctx.Err() != nil {
+				fmt.Println("  测试已中断，跳过剩余的并发度")
+				return nil
+			}
+			if len(models) == 0 {
+				fmt.Println("  流量混合中的模型都已出错，跳过剩余的测试")
+				return nil
+			}
+
+			failed, err := e.runMixedLevel(ctx, models, prompt, concurrency, e.mixPicker(weights), results)
+			if err != nil {
+				return err
+			}
+			models, weights = withoutModels(models, weights, failed)
+		}
+	}
+	return nil
+}
+
+// withoutModels 从流量混合中去掉 failed 中的模型及其权重
+func withoutModels(models []model.LLMModel, weights []int64, failed map[string]bool) ([]model.LLMModel, []int64) {
+	if len(failed) == 0 {
+		return models, weights
+	}
+	keptModels := make([]model.LLMModel, 0, len(models))
+	keptWeights := make([]int64, 0, len(weights))
+	for i, mdl := range models {
+		if !failed[mdl.GetName()] {
+			keptModels = append(keptModels, mdl)
+			keptWeights = append(keptWeights, weights[i])
+		}
+	}
+	return keptModels, keptWeights
+}
+
+// runMixedLevel 以指定并发度运行一个场景的流量混合测试，每个模型的结果分别存入results。
+// 开启 continue_on_model_error 时，没有成功请求并且遇到致命错误的模型记录为测试出错，
+// 通过 failed 返回以便调用方从之后的测试中去掉，其他模型的结果照常发布
+func (e *TestEngine) runMixedLevel(ctx context.Context, models []model.LLMModel, prompt config.PromptConfig, concurrency int, pick func() int, results map[string]*TestResult) (failed map[string]bool, err error) {
+	runs := make([]*levelRun, 0, len(models))
+	for _, mdl := range models {
+		result := &TestResult{
+			ModelName:        mdl.GetName(),
+			Scenario:         prompt.Name,
+			ConcurrencyLevel: concurrency,
+			ResponseFormat:   mdl.GetResponseFormat(),
+			ErrorsByCategory: make(map[string]int),
+		}
+		results[ResultKey(result.ModelName, prompt.Name, concurrency)] = result
+		runs = append(runs, e.newLevelRun(mdl, prompt, result))
+	}
+
+	if err := e.runTestWithConcurrency(ctx, runs, prompt, concurrency, pick); err != nil && !e.config.ContinueOnModelError {
+		for _, run := range runs {
+			delete(results, run.result.Key())
+		}
+		return nil, err
+	}
+
+	failed = make(map[string]bool)
+	for _, run := range runs {
+		if fatalErr := run.fatal(); fatalErr != nil {
+			modelName := run.mdl.GetName()
+			fmt.Printf("  模型 %s 没有成功的请求，从流量混合中去掉该模型: %v\n", modelName, fatalErr)
+			delete(results, run.result.Key())
+			recordModelError(results, modelName, fmt.Errorf("模型 %s 没有成功的请求: %w", modelName, fatalErr))
+			failed[modelName] = true
+			continue
+		}
+		run.result.Incomplete = ctx.Err() != nil
+		e.publishResult(run.result)
+	}
+	return failed, nil
+}
+
+// mixPicker 返回按权重随机选择模型下标的函数，使用流量混合独立的随机数生成器以便复现
+func (e *TestEngine) mixPicker(weights []int64) func() int {
+	var totalWeight int64
+	for _, weight := range weights {
+		totalWeight += weight
+	}
+	return func() int {
+		n := e.mixRng.Int63n(totalWeight)
+		for i, weight := range weights {
+			if n < weight {
+				return i
+			}
+			n -= weight
+		}
+		return len(weights) - 1
+	}
+}
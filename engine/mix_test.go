@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lemonlinger/llm-test/config"
+)
+
+// 相同种子下流量混合的选择序列应当相同，不受延迟采样消耗引擎随机数的影响
+func TestMixPickerIndependentOfLatencySampling(t *testing.T) {
+	weights := []int64{3, 1, 2}
+	cfg := config.TestConfig{RandomSeed: 42}
+	quiet := NewTestEngine(cfg, nil, nil, nil)
+	busy := NewTestEngine(cfg, nil, nil, nil)
+	pickQuiet := quiet.mixPicker(weights)
+	pickBusy := busy.mixPicker(weights)
+
+	for i := 0; i < 1000; i++ {
+		// 模拟延迟记录器的水塘采样
+		for j := 0; j < i%7; j++ {
+			busy.randInt63n(1000)
+		}
+		if a, b := pickQuiet(), pickBusy(); a != b {
+			t.Fatalf("第 %d 次选择不同: %d != %d", i, a, b)
+		}
+	}
+}
+
+const mixFatalTestConfig = `
+test:
+  concurrency_levels: [1, 2]
+  total_requests: 20
+  # 固定种子，两个模型在每个并发度下都会分到请求
+  random_seed: 1
+  skip_preflight: %t
+  continue_on_model_error: %t
+  traffic_mix:
+    - model: good
+      weight: 1
+    - model: unauthorized
+      weight: 1
+models:
+  - name: good
+    type: mock
+    params: {latency_ms: 1, output_tokens: 3}
+  - name: unauthorized
+    type: mock
+    params: {latency_ms: 1, error_rate: 1, error_status: 401}
+prompt:
+  user_message: hi
+`
+
+func loadMixFatalTestConfig(t *testing.T, skipPreflight, continueOnModelError bool) *config.Config {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := fmt.Sprintf(mixFatalTestConfig, skipPreflight, continueOnModelError)
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(file)
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	return cfg
+}
+
+// 开启 continue_on_model_error 时，预检失败或只返回致命错误的模型从流量混合中去掉，其他模型继续测试
+func TestTrafficMixContinueOnModelError(t *testing.T) {
+	for _, skipPreflight := range []bool{false, true} {
+		results, err := Run(context.Background(), loadMixFatalTestConfig(t, skipPreflight, true))
+		if err != nil {
+			t.Fatalf("skip_preflight=%t: 开启 continue_on_model_error 时不应返回错误: %v", skipPreflight, err)
+		}
+
+		failed, ok := results[ResultKey("unauthorized", "", 0)]
+		if !ok || !strings.Contains(failed.ModelError, "401") {
+			t.Errorf("skip_preflight=%t: 认证失败的模型应当记录测试出错，实际结果: %+v", skipPreflight, failed)
+		}
+		for _, concurrency := range []int{1, 2} {
+			if _, ok := results[ResultKey("unauthorized", "", concurrency)]; ok {
+				t.Errorf("skip_preflight=%t: 测试出错的模型不应保留并发度 %d 的结果", skipPreflight, concurrency)
+			}
+			good, ok := results[ResultKey("good", "", concurrency)]
+			if !ok || good.SuccessRequests == 0 {
+				t.Errorf("skip_preflight=%t: 其他模型应当在并发度 %d 下正常测试，实际结果: %+v", skipPreflight, concurrency, good)
+			}
+		}
+	}
+}
+
+// 未开启 continue_on_model_error 时，流量混合中任何一个模型出错都终止测试
+func TestTrafficMixModelErrorStopsTest(t *testing.T) {
+	for _, skipPreflight := range []bool{false, true} {
+		_, err := Run(context.Background(), loadMixFatalTestConfig(t, skipPreflight, false))
+		if err == nil || !strings.Contains(err.Error(), "unauthorized") {
+			t.Errorf("skip_preflight=%t: 认证失败应当终止测试并返回模型的错误，实际为 %v", skipPreflight, err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lemonlinger/llm-test/model"
+)
+
+// 预检请求使用的用户消息，尽量短以减少开销
+const preflightMessage = "ping"
+
+// preflight 在正式测试前向模型发送一个简短的请求，尽早发现地址、密钥等配置错误
+// 使用独立的超时时间，不受 RequestTimeout 影响；流式设置与第一个场景的测试相同
+func (e *TestEngine) preflight(ctx context.Context, mdl model.LLMModel) error {
+	useStream := e.prompts[0].Stream
+	if modelStream := mdl.GetStreamSetting(); modelStream != nil {
+		useStream = *modelStream
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, e.config.PreflightTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := mdl.GenerateResponse(reqCtx, "", preflightMessage, useStream); err != nil {
+		return fmt.Errorf("预检请求失败: %w", err)
+	}
+	fmt.Printf("  预检通过: 延迟=%s\n", time.Since(start).Round(time.Millisecond))
+	return nil
+}
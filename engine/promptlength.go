@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/model"
+)
+
+// 填充文本使用的常见三字母单词，加上前导空格每个单词4个字节，
+// model.EstimateTokens 按每4个字节一个token估算，常见的BPE分词器也大多把它们分为一个token，估算值与服务端统计接近
+var fillerWords = strings.Fields("the and for are but not you all any can had her was one our out day get has him " +
+	"his how man new now old see two way who boy did its let put say she too use big end far few got run set top")
+
+// promptPadder 按 prompt_length 配置的分布为每个请求生成填充后的用户消息
+type promptPadder struct {
+	length config.PromptLengthConfig
+	// 系统消息和用户消息估算的token数，填充的token数为目标值减去它
+	baseTokens int
+	// lognormal 分布对应的正态分布参数
+	mu, sigma float64
+}
+
+// newPromptPadder 创建场景的提示词填充器，未配置 prompt_length 时返回nil
+func newPromptPadder(prompt config.PromptConfig) *promptPadder {
+	if prompt.PromptLength == nil {
+		return nil
+	}
+	p := &promptPadder{
+		length:     *prompt.PromptLength,
+		baseTokens: model.EstimateTokens(prompt.SystemMessage + prompt.UserMessage),
+	}
+	if p.length.Distribution == config.PromptLengthLognormal {
+		// 由期望m和标准差s求对数正态分布的参数：sigma² = ln(1 + s²/m²)，mu = ln(m) - sigma²/2
+		mean, stddev := float64(p.length.MeanTokens), float64(p.length.StddevTokens)
+		variance := math.Log(1 + stddev*stddev/(mean*mean))
+		p.sigma = math.Sqrt(variance)
+		p.mu = math.Log(mean) - variance/2
+	}
+	return p
+}
+
+// sample 按配置的分布抽取一个目标输入Token数，截断到 [min_tokens, max_tokens] 范围内，至少为1
+func (p *promptPadder) sample(rng *rand.Rand) int {
+	var tokens float64
+	switch p.length.Distribution {
+	case config.PromptLengthUniform:
+		tokens = float64(p.length.MinTokens + rng.Intn(p.length.MaxTokens-p.length.MinTokens+1))
+	case config.PromptLengthNormal:
+		tokens = float64(p.length.MeanTokens) + rng.NormFloat64()*float64(p.length.StddevTokens)
+	default:
+		tokens = math.Exp(p.mu + rng.NormFloat64()*p.sigma)
+	}
+	target := max(int(math.Round(tokens)), p.length.MinTokens, 1)
+	if p.length.MaxTokens > 0 {
+		target = min(target, p.length.MaxTokens)
+	}
+	return target
+}
+
+// pad 在用户消息前填充随机单词，使估算的输入Token数达到 target
+// 每个请求的填充内容都不同，避免服务端的前缀缓存让长提示词的延迟偏低
+func (p *promptPadder) pad(userMessage string, target int, rng *rand.Rand) string {
+	words := target - p.baseTokens
+	if words <= 0 {
+		return userMessage
+	}
+	var sb strings.Builder
+	sb.Grow(words*4 + len(userMessage) + 2)
+	for i := 0; i < words; i++ {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(fillerWords[rng.Intn(len(fillerWords))])
+	}
+	sb.WriteString("\n\n")
+	sb.WriteString(userMessage)
+	return sb.String()
+}
+
+// padPrompt 为一个请求抽取目标输入Token数并返回填充后的场景
+// 只在持有锁时从引擎的随机数生成器取得目标值和填充内容的种子，生成填充内容不阻塞其他工作协程
+func (e *TestEngine) padPrompt(padder *promptPadder, prompt config.PromptConfig) (config.PromptConfig, int) {
+	e.lengthMutex.Lock()
+	target := padder.sample(e.lengthRng)
+	seed := e.lengthRng.Int63()
+	e.lengthMutex.Unlock()
+
+	prompt.UserMessage = padder.pad(prompt.UserMessage, target, rand.New(rand.NewSource(seed)))
+	return prompt, target
+}
+
+// inputLengthRecorder 按输入Token数区间并发安全地记录成功请求的延迟
+type inputLengthRecorder struct {
+	// 区间上界（从小到大），最后一个区间为超过所有上界的请求
+	bounds    []int
+	latencies []*latencyRecorder
+
+	mu          sync.Mutex
+	inputTokens []int64
+	ttftTotal   []time.Duration
+	ttftCount   []int
+}
+
+// newInputLengthRecorder 创建按输入Token数区间统计延迟的记录器
+func newInputLengthRecorder(bounds []int, streaming bool, randInt63n func(n int64) int64) *inputLengthRecorder {
+	r := &inputLengthRecorder{
+		bounds:      bounds,
+		latencies:   make([]*latencyRecorder, len(bounds)+1),
+		inputTokens: make([]int64, len(bounds)+1),
+		ttftTotal:   make([]time.Duration, len(bounds)+1),
+		ttftCount:   make([]int, len(bounds)+1),
+	}
+	for i := range r.latencies {
+		r.latencies[i] = newLatencyRecorder(streaming, nil, randInt63n)
+	}
+	return r
+}
+
+// add 记录一个成功请求，ttft 为0表示非流式请求
+func (r *inputLengthRecorder) add(inputTokens int, latency, ttft time.Duration) {
+	i := sort.SearchInts(r.bounds, inputTokens)
+	r.latencies[i].add(latency)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inputTokens[i] += int64(inputTokens)
+	if ttft > 0 {
+		r.ttftTotal[i] += ttft
+		r.ttftCount[i]++
+	}
+}
+
+// stats 返回有请求的区间的统计，需要在所有请求完成后调用
+func (r *inputLengthRecorder) stats() []InputLengthStats {
+	var stats []InputLengthStats
+	for i, latencies := range r.latencies {
+		if latencies.count == 0 {
+			continue
+		}
+		s := InputLengthStats{
+			Requests:       int(latencies.count),
+			AvgInputTokens: float64(r.inputTokens[i]) / float64(latencies.count),
+			AvgLatency:     time.Duration(latencies.mean),
+		}
+		if i > 0 {
+			s.MinTokens = r.bounds[i-1]
+		}
+		if i < len(r.bounds) {
+			s.MaxTokens = r.bounds[i]
+		}
+		percentiles := latencies.percentiles([]int{50, 95})
+		s.P50Latency, s.P95Latency = percentiles[50], percentiles[95]
+		if r.ttftCount[i] > 0 {
+			s.AvgTimeToFirstToken = r.ttftTotal[i] / time.Duration(r.ttftCount[i])
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
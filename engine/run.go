@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
+	"github.com/lemonlinger/llm-test/model"
+)
+
+// Run 按配置运行一次完整的性能测试并返回结果，结果的键见 TestResult.Key。
+// Run 不解析命令行参数，也不写入任何文件，需要报告时可以将结果交给 report.Reporter 生成。
+// cfg 应当由 config.LoadConfig 或 config.LoadConfigs 加载，以获得默认值和校验；
+// 未设置随机种子时使用当前时间，并按并发度计算HTTP连接池配置，这两项会写回cfg。
+// ctx 被取消时停止测试并返回已完成部分的结果，与 TestEngine.Run 相同。
+//
+// 最简单的用法：
+//
+//	cfg, err := config.LoadConfig("config.yaml")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	results, err := engine.Run(context.Background(), cfg)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	for key, result := range results {
+//		fmt.Printf("%s: RPS=%.2f, 平均延迟=%s\n", key, result.RequestsPerSec, result.AvgLatency)
+//	}
+//
+// 需要调整日志级别或在每个并发度完成时收到结果，使用 NewTestEngineFromConfig
+func Run(ctx context.Context, cfg *config.Config) (map[string]*TestResult, error) {
+	testEngine, err := NewTestEngineFromConfig(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return testEngine.Run(ctx)
+}
+
+// NewTestEngineFromConfig 根据完整配置初始化模型并创建测试引擎，logger 为nil时只输出错误。
+// 与 Run 一样会补全cfg中的随机种子和HTTP连接池配置，可以在调用 TestEngine.Run 之前
+// 通过 SetResultCallback 或 SetResultChannel 接收每个并发度的结果：
+//
+//	testEngine, err := engine.NewTestEngineFromConfig(cfg, logging.New(logging.LevelInfo))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	testEngine.SetResultCallback(func(result *engine.TestResult) {
+//		fmt.Printf("%s 并发度 %d 完成\n", result.ModelName, result.ConcurrencyLevel)
+//	})
+//	results, err := testEngine.Run(ctx)
+func NewTestEngineFromConfig(cfg *config.Config, logger *logging.Logger) (*TestEngine, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("测试配置不能为空")
+	}
+	if len(cfg.Prompts) == 0 {
+		return nil, fmt.Errorf("至少需要配置一个提示词场景")
+	}
+
+	// 未指定随机种子时使用当前时间，写回配置以便调用方输出和复现
+	if cfg.Test.RandomSeed == 0 {
+		cfg.Test.RandomSeed = time.Now().UnixNano()
+	}
+	// 根据最终的并发度计算HTTP连接池配置
+	cfg.ApplyHTTPDefaults()
+	// 每个模型使用由测试种子派生的独立种子，与引擎内部的随机数生成器互不影响
+	for i := range cfg.Models {
+		cfg.Models[i].RandomSeed = cfg.Test.RandomSeed + 100 + int64(i)
+	}
+
+	models, err := model.InitializeModels(cfg.Models, cfg.Proxies, logger)
+	if err != nil {
+		return nil, fmt.Errorf("初始化模型失败: %w", err)
+	}
+	if err := checkImageSupport(models, cfg.Prompts); err != nil {
+		return nil, err
+	}
+	schemas, err := checkResponseSchemas(cfg.Prompts)
+	if err != nil {
+		return nil, err
+	}
+	testEngine := NewTestEngine(cfg.Test, models, cfg.Prompts, cfg.Proxies)
+	testEngine.schemas = schemas
+	testEngine.SetLogger(logger)
+	return testEngine, nil
+}
+
+// checkImageSupport 检查带图片的场景中的所有模型是否支持图片输入，避免测试开始后所有请求都失败
+func checkImageSupport(models []model.LLMModel, prompts []config.PromptConfig) error {
+	for _, prompt := range prompts {
+		if len(prompt.Images) == 0 {
+			continue
+		}
+		for _, mdl := range models {
+			if supporter, ok := mdl.(model.ImageSupporter); !ok || !supporter.SupportsImages() {
+				return fmt.Errorf("模型 %s 不支持图片输入，不能测试配置了 images 的提示词场景", mdl.GetName())
+			}
+		}
+	}
+	return nil
+}
+
+// Models 返回测试引擎要测试的模型
+func (e *TestEngine) Models() []model.LLMModel {
+	return e.models
+}
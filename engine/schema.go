@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// 每个测试结果最多保存的不同的结构化输出校验错误示例数
+const maxSchemaErrors = 5
+
+// checkResponseSchemas 编译所有场景的 response_schema，避免测试结束后才发现Schema有误
+// 返回的Schema以 response_schema 的原文为键，相同的Schema只编译一次，测试时直接使用
+func checkResponseSchemas(prompts []config.PromptConfig) (map[string]*jsonschema.Schema, error) {
+	schemas := make(map[string]*jsonschema.Schema)
+	for i, prompt := range prompts {
+		if prompt.ResponseSchema == "" || schemas[prompt.ResponseSchema] != nil {
+			continue
+		}
+		schema, err := prompt.CompileResponseSchema()
+		if err != nil {
+			return nil, fmt.Errorf("提示词场景 #%d 的 %w", i+1, err)
+		}
+		schemas[prompt.ResponseSchema] = schema
+	}
+	return schemas, nil
+}
+
+// responseSchema 返回场景编译后的Schema，没有配置 response_schema 时返回nil
+// 通过 NewTestEngine 直接创建的引擎没有预先编译，第一次使用时编译，编译失败的Schema不校验
+func (e *TestEngine) responseSchema(prompt config.PromptConfig) *jsonschema.Schema {
+	if prompt.ResponseSchema == "" {
+		return nil
+	}
+	if schema, ok := e.schemas[prompt.ResponseSchema]; ok {
+		return schema
+	}
+	schema, err := prompt.CompileResponseSchema()
+	if err != nil {
+		fmt.Printf("  场景 %s 的 %v，跳过Schema校验\n", prompt.Name, err)
+	}
+	if e.schemas == nil {
+		e.schemas = make(map[string]*jsonschema.Schema)
+	}
+	e.schemas[prompt.ResponseSchema] = schema
+	return schema
+}
+
+// validateSchemaContent 校验模型响应的内容是否满足Schema，内容被Markdown代码块包裹时先去掉代码块标记
+// 不满足时返回第一个不满足的约束，例如 "$.age: expected integer, but got string"
+func validateSchemaContent(schema *jsonschema.Schema, content string) error {
+	// 按 json.Number 解析，保证大整数和 multipleOf 等关键字的精度
+	decoder := json.NewDecoder(strings.NewReader(stripCodeFence(content)))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("响应不是合法的JSON: %w", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("响应不是合法的JSON: JSON值之后还有其他内容")
+	}
+
+	err := schema.Validate(value)
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		leaf := validationErr
+		for len(leaf.Causes) > 0 {
+			leaf = leaf.Causes[0]
+		}
+		return fmt.Errorf("%s: %s", instancePath(leaf.InstanceLocation), leaf.Message)
+	}
+	return err
+}
+
+// instancePath 将JSON Pointer形式的位置转换为便于阅读的路径，例如 /items/0/name 转换为 $.items[0].name
+func instancePath(pointer string) string {
+	path := "$"
+	if pointer == "" {
+		return path
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		if _, err := strconv.Atoi(token); err == nil {
+			path += "[" + token + "]"
+		} else {
+			path += "." + token
+		}
+	}
+	return path
+}
+
+// stripCodeFence 去掉包裹JSON的 ```json ... ``` 代码块标记
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") || !strings.HasSuffix(content, "```") || len(content) < 6 {
+		return content
+	}
+	content = strings.TrimSuffix(strings.TrimPrefix(content, "```"), "```")
+	// 去掉代码块的语言标记，例如 json
+	if newline := strings.IndexByte(content, '\n'); newline >= 0 && !strings.ContainsAny(content[:newline], "{[") {
+		content = content[newline+1:]
+	}
+	return strings.TrimSpace(content)
+}
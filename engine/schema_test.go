@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lemonlinger/llm-test/config"
+)
+
+const personSchema = `{
+  "$defs": {"name": {"type": "string", "minLength": 1}},
+  "type": "object",
+  "properties": {
+    "name": {"$ref": "#/$defs/name"},
+    "age": {"type": "integer", "minimum": 0},
+    "email": {"type": "string", "format": "email"},
+    "tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true}
+  },
+  "patternProperties": {"^x-": {"type": "string"}},
+  "dependentRequired": {"email": ["name"]},
+  "required": ["age"],
+  "additionalProperties": false
+}`
+
+func TestValidateSchemaContent(t *testing.T) {
+	schemas, err := checkResponseSchemas([]config.PromptConfig{{ResponseSchema: personSchema}})
+	if err != nil {
+		t.Fatalf("编译Schema失败: %v", err)
+	}
+	schema := schemas[personSchema]
+
+	valid := []string{
+		`{"name": "张三", "age": 30}`,
+		`{"name": "张三", "age": 30, "email": "zhangsan@example.com", "tags": ["a", "b"], "x-source": "test"}`,
+		"```json\n{\"age\": 0}\n```",
+	}
+	for _, content := range valid {
+		if err := validateSchemaContent(schema, content); err != nil {
+			t.Errorf("%s 应当满足Schema，实际错误: %v", content, err)
+		}
+	}
+
+	invalid := []struct {
+		content string
+		path    string
+	}{
+		{`not json`, "不是合法的JSON"},
+		{`{"age": 30} trailing`, "不是合法的JSON"},
+		{`{"name": "张三"}`, "$"},                                // required
+		{`{"age": "30"}`, "$.age"},                             // type
+		{`{"age": 1.5}`, "$.age"},                              // integer
+		{`{"age": -1}`, "$.age"},                               // minimum
+		{`{"age": 1, "name": ""}`, "$.name"},                   // $ref
+		{`{"age": 1, "name": "a", "email": "bad"}`, "$.email"}, // format
+		{`{"age": 1, "tags": ["a", "a"]}`, "$.tags"},           // uniqueItems
+		{`{"age": 1, "tags": ["a", 2]}`, "$.tags[1]"},          // items
+		{`{"age": 1, "x-source": 1}`, "$.x-source"},            // patternProperties
+		{`{"age": 1, "email": "a@example.com"}`, "$"},          // dependentRequired
+		{`{"age": 1, "other": true}`, "$"},                     // additionalProperties
+	}
+	for _, tc := range invalid {
+		err := validateSchemaContent(schema, tc.content)
+		if err == nil {
+			t.Errorf("%s 不应满足Schema", tc.content)
+			continue
+		}
+		if strings.HasPrefix(tc.path, "$") && !strings.HasPrefix(err.Error(), tc.path+": ") ||
+			!strings.HasPrefix(tc.path, "$") && !strings.Contains(err.Error(), tc.path) {
+			t.Errorf("%s 的错误 %q 没有指出位置 %s", tc.content, err, tc.path)
+		}
+	}
+}
+
+func TestCheckResponseSchemasRejectsInvalidSchema(t *testing.T) {
+	for _, raw := range []string{`{"type": `, `{"type": "text"}`, `{"$ref": "#/$defs/missing"}`, `{"pattern": "("}`} {
+		if _, err := checkResponseSchemas([]config.PromptConfig{{ResponseSchema: raw}}); err == nil {
+			t.Errorf("Schema %s 应当编译失败", raw)
+		}
+	}
+}
+
+func TestInstancePath(t *testing.T) {
+	for pointer, want := range map[string]string{
+		"":              "$",
+		"/age":          "$.age",
+		"/items/0/name": "$.items[0].name",
+		"/a~1b/c~0d":    "$.a/b.c~d",
+	} {
+		if got := instancePath(pointer); got != want {
+			t.Errorf("instancePath(%q) = %q，期望 %q", pointer, got, want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/lemonlinger/llm-test/config"
+)
+
+// 相同种子的两次运行应当生成相同的合成提示词序列，不同种子的序列不同
+func TestPadPromptReproducibleWithSeed(t *testing.T) {
+	prompt := config.PromptConfig{
+		UserMessage: "hi",
+		PromptLength: &config.PromptLengthConfig{
+			Distribution: config.PromptLengthLognormal,
+			MeanTokens:   200,
+			StddevTokens: 80,
+			MinTokens:    1,
+		},
+	}
+	padder := newPromptPadder(prompt)
+
+	sequence := func(seed int64) []string {
+		e := NewTestEngine(config.TestConfig{RandomSeed: seed}, nil, nil, nil)
+		var messages []string
+		for i := 0; i < 20; i++ {
+			padded, _ := e.padPrompt(padder, prompt)
+			messages = append(messages, padded.UserMessage)
+		}
+		return messages
+	}
+
+	first, second, other := sequence(7), sequence(7), sequence(8)
+	same := true
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("相同种子的第 %d 个提示词不同", i)
+		}
+		same = same && first[i] == other[i]
+	}
+	if same {
+		t.Fatal("不同种子生成了相同的提示词序列")
+	}
+}
@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
+	"github.com/lemonlinger/llm-test/model"
+)
+
+// hangingModel 不响应context取消、一直不返回的模型，模拟卡住的客户端
+type hangingModel struct {
+	model.BaseModel
+	release chan struct{}
+}
+
+func (m *hangingModel) GenerateResponse(ctx context.Context, systemMessage, userMessage string, stream bool) (*model.LLMResponse, error) {
+	<-m.release
+	return nil, context.Canceled
+}
+
+// 所有请求都卡住时并发度应当在超时和宽限期之后结束，卡住的请求计为超时失败
+func TestHangingModelLevelEnds(t *testing.T) {
+	previous := stuckGracePeriod
+	stuckGracePeriod = 100 * time.Millisecond
+	defer func() { stuckGracePeriod = previous }()
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+test:
+  concurrency: 2
+  total_requests: 10
+  request_timeout: 50ms
+  skip_preflight: true
+models:
+  - name: hanging
+    type: mock
+prompt:
+  user_message: hi
+`
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(file)
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+
+	hanging := &hangingModel{
+		BaseModel: model.NewBaseModel(cfg.Models[0], logging.New(logging.LevelQuiet)),
+		release:   make(chan struct{}),
+	}
+	defer close(hanging.release)
+	testEngine := NewTestEngine(cfg.Test, []model.LLMModel{hanging}, cfg.Prompts, nil)
+
+	done := make(chan struct{})
+	var results map[string]*TestResult
+	go func() {
+		defer close(done)
+		results, err = testEngine.Run(context.Background())
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("所有请求卡住时并发度没有结束")
+	}
+	if err != nil {
+		t.Fatalf("测试失败: %v", err)
+	}
+
+	result := results[ResultKey("hanging", "", 2)]
+	if result == nil {
+		t.Fatalf("缺少并发度2的结果: %v", results)
+	}
+	if result.FailedRequests != 2 || result.SuccessRequests != 0 {
+		t.Errorf("成功 %d、失败 %d，期望卡住的2个请求计为失败", result.SuccessRequests, result.FailedRequests)
+	}
+	if n := result.ErrorsByCategory[ErrorCategoryTimeout]; n != 2 {
+		t.Errorf("超时错误为 %d 个，期望 2 个，错误分类: %v", n, result.ErrorsByCategory)
+	}
+	if result.TotalRequests != 2 {
+		t.Errorf("请求数为 %d，未派发的请求不应计入，期望 2", result.TotalRequests)
+	}
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/model"
+)
+
+// runAutoSweep 按1、2、4、8...递增并发度进行测试，直到吞吐量饱和
+// 停止条件：RPS相对上一级的增幅低于阈值、P99延迟超过上限或达到最大并发度。
+// 停止前最后一个有效的并发度被标记为拐点
+func (e *TestEngine) runAutoSweep(ctx context.Context, mdl model.LLMModel, prompt config.PromptConfig, results map[string]*TestResult) error {
+	sweep := e.config.AutoSweep
+
+	var knee *TestResult
+	for concurrency := 1; ; concurrency *= 2 {
+		if concurrency > sweep.MaxConcurrency {
+			concurrency = sweep.MaxConcurrency
+		}
+
+		if ctx.Err() != nil {
+			fmt.Println("  测试已中断，停止自动并发扫描")
+			break
+		}
+
+		result, err := e.runLevel(ctx, mdl, prompt, concurrency, "", results)
+		if err != nil {
+			return err
+		}
+
+		// P99延迟超过上限，上一级并发度即为拐点
+		// 优先使用已计算的P99，流式百分位模式下 AllLatencies 只是抽样
+		if sweep.LatencyCeiling > 0 && len(result.AllLatencies) > 0 {
+			p99, ok := result.LatencyPercentiles[99]
+			if !ok {
+				p99 = calculatePercentile(result.AllLatencies, 99)
+			}
+			if p99 > sweep.LatencyCeiling {
+				fmt.Printf("  并发度 %d 的P99延迟 %s 超过上限 %s，停止扫描\n", concurrency, p99, sweep.LatencyCeiling)
+				break
+			}
+		}
+
+		// RPS增幅低于阈值，继续增加并发度已无收益
+		if knee != nil && knee.RequestsPerSec > 0 {
+			gain := (result.RequestsPerSec - knee.RequestsPerSec) / knee.RequestsPerSec
+			if gain < sweep.RPSGainThreshold {
+				fmt.Printf("  并发度 %d 的RPS增幅 %.1f%% 低于阈值 %.1f%%，停止扫描\n",
+					concurrency, gain*100, sweep.RPSGainThreshold*100)
+				break
+			}
+		}
+
+		knee = result
+		if concurrency >= sweep.MaxConcurrency {
+			break
+		}
+	}
+
+	if knee == nil {
+		fmt.Println("  自动并发扫描未找到满足条件的并发度")
+		return nil
+	}
+
+	knee.IsKnee = true
+	fmt.Printf("  自动并发扫描选出的拐点并发度: %d (RPS=%.2f)\n", knee.ConcurrencyLevel, knee.RequestsPerSec)
+	return nil
+}
@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"math"
+	"sort"
+)
+
+// t-digest的压缩参数，越大越精确，质心数量大约为该值的一半
+const tDigestCompression = 200
+
+// centroid t-digest中的质心
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// tDigest 流式分位数估计（合并式t-digest），不需要保存所有样本即可估算百分位
+// 尾部（例如P99）的质心更小，因此尾部分位数的误差远小于中位数附近。不是并发安全的
+type tDigest struct {
+	compression float64
+	centroids   []centroid // 按均值排序的已合并质心
+	buffer      []centroid // 待合并的新样本
+	count       float64
+	min, max    float64
+}
+
+// newTDigest 创建t-digest
+func newTDigest() *tDigest {
+	return &tDigest{
+		compression: tDigestCompression,
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}
+}
+
+// add 添加一个样本
+func (d *tDigest) add(x float64) {
+	d.addWeighted(x, 1)
+}
+
+func (d *tDigest) addWeighted(x, weight float64) {
+	d.buffer = append(d.buffer, centroid{mean: x, weight: weight})
+	d.count += weight
+	d.min = math.Min(d.min, x)
+	d.max = math.Max(d.max, x)
+	if len(d.buffer) >= int(10*d.compression) {
+		d.compress()
+	}
+}
+
+// merge 把另一个t-digest的数据合并进来
+func (d *tDigest) merge(other *tDigest) {
+	other.compress()
+	for _, c := range other.centroids {
+		d.addWeighted(c.mean, c.weight)
+	}
+	d.min = math.Min(d.min, other.min)
+	d.max = math.Max(d.max, other.max)
+}
+
+// compress 将缓冲区中的样本合并到质心中
+// 使用k1尺度函数 k(q) = δ/(2π)·asin(2q-1) 限制每个质心覆盖的分位数范围
+func (d *tDigest) compress() {
+	if len(d.buffer) == 0 {
+		return
+	}
+
+	all := append(d.centroids, d.buffer...)
+	d.buffer = d.buffer[:0]
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+
+	merged := make([]centroid, 0, len(d.centroids)+1)
+	merged = append(merged, all[0])
+	weightSoFar := 0.0
+	qLimit := d.qLimit(0)
+	for _, c := range all[1:] {
+		cur := &merged[len(merged)-1]
+		if (weightSoFar+cur.weight+c.weight)/d.count <= qLimit {
+			cur.weight += c.weight
+			cur.mean += (c.mean - cur.mean) * c.weight / cur.weight
+			continue
+		}
+		weightSoFar += cur.weight
+		qLimit = d.qLimit(weightSoFar / d.count)
+		merged = append(merged, c)
+	}
+	d.centroids = merged
+}
+
+// qLimit 返回从分位数q开始的质心最多能覆盖到的分位数
+func (d *tDigest) qLimit(q float64) float64 {
+	k := d.compression / (2 * math.Pi) * math.Asin(2*q-1)
+	// k的最大值为δ/4，超过后sin的周期性会让上限回落，直接返回1
+	if k+1 >= d.compression/4 {
+		return 1
+	}
+	return (math.Sin((k+1)*2*math.Pi/d.compression) + 1) / 2
+}
+
+// quantile 估算分位数，q的取值范围为[0, 1]，没有样本时返回0
+func (d *tDigest) quantile(q float64) float64 {
+	d.compress()
+	if len(d.centroids) == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return d.min
+	}
+	if q >= 1 {
+		return d.max
+	}
+
+	// 第i小的样本位于秩i（从0开始），与精确计算时的 (n-1)*p 索引保持一致
+	// 质心覆盖的样本视为均匀分布在其中心两侧，在相邻质心中心之间线性插值
+	target := q * (d.count - 1)
+	first := d.centroids[0]
+	if center := (first.weight - 1) / 2; target < center {
+		return d.min + (first.mean-d.min)*target/center
+	}
+
+	cumulative := 0.0
+	for i := 0; i+1 < len(d.centroids); i++ {
+		cur, next := d.centroids[i], d.centroids[i+1]
+		center := cumulative + (cur.weight-1)/2
+		nextCenter := cumulative + cur.weight + (next.weight-1)/2
+		if target < nextCenter {
+			return cur.mean + (next.mean-cur.mean)*(target-center)/(nextCenter-center)
+		}
+		cumulative += cur.weight
+	}
+
+	last := d.centroids[len(d.centroids)-1]
+	center := d.count - (last.weight+1)/2
+	if target <= center {
+		return last.mean
+	}
+	return last.mean + (d.max-last.mean)*(target-center)/(d.count-1-center)
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+// exactQuantile 与 latencyRecorder 精确模式相同的取法：排序后取下标 (n-1)*q
+func exactQuantile(sorted []float64, q float64) float64 {
+	return sorted[int(float64(len(sorted)-1)*q)]
+}
+
+// t-digest 估算的百分位应当与精确值足够接近
+// 每个分布使用固定种子的独立随机源，结果不受子测试执行顺序影响
+func TestTDigestQuantileAccuracy(t *testing.T) {
+	distributions := []struct {
+		name   string
+		sample func(rng *rand.Rand) float64
+	}{
+		// 延迟通常近似对数正态分布，长尾明显
+		{"lognormal", func(rng *rand.Rand) float64 { return math.Exp(6 + 0.8*rng.NormFloat64()) }},
+		{"uniform", func(rng *rand.Rand) float64 { return 100 + 900*rng.Float64() }},
+	}
+	// 各百分位允许的相对误差，长尾分布的 P99.9 只有约100个样本落在其后，误差在几个百分点
+	tolerances := []struct {
+		q      float64
+		relErr float64
+	}{
+		{0.5, 0.01},
+		{0.9, 0.01},
+		{0.99, 0.02},
+		{0.999, 0.06},
+	}
+
+	for _, dist := range distributions {
+		t.Run(dist.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			d := newTDigest()
+			values := make([]float64, 100000)
+			for i := range values {
+				values[i] = dist.sample(rng)
+				d.add(values[i])
+			}
+			sort.Float64s(values)
+
+			for _, tol := range tolerances {
+				exact := exactQuantile(values, tol.q)
+				got := d.quantile(tol.q)
+				if relErr := math.Abs(got-exact) / exact; relErr > tol.relErr {
+					t.Errorf("P%g 为 %.2f，精确值 %.2f，相对误差 %.2f%% 超过 %g%%", tol.q*100, got, exact, relErr*100, tol.relErr*100)
+				}
+			}
+		})
+	}
+}
+
+// 流式百分位模式下 latencyRecorder 的 P99 与精确模式的结果接近
+func TestLatencyRecorderStreamingP99(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	randInt63n := func(n int64) int64 { return rng.Int63n(n) }
+	streaming := newLatencyRecorder(true, nil, randInt63n)
+	exact := newLatencyRecorder(false, nil, randInt63n)
+	for i := 0; i < 5000; i++ {
+		latency := time.Duration(math.Exp(4+0.5*rng.NormFloat64()) * float64(time.Millisecond))
+		streaming.add(latency)
+		exact.add(latency)
+	}
+
+	got := streaming.percentiles([]int{99})[99]
+	want := exact.percentiles([]int{99})[99]
+	if diff := math.Abs(float64(got-want)) / float64(want); diff > 0.02 {
+		t.Fatalf("流式模式的 P99 为 %s，精确值 %s，相对误差 %.2f%% 超过 2%%", got, want, diff*100)
+	}
+}
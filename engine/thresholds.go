@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lemonlinger/llm-test/config"
+)
+
+// ThresholdViolation 未达到性能门槛的测试结果及原因
+type ThresholdViolation struct {
+	Result  *TestResult
+	Reasons []string
+}
+
+// String 返回违反门槛的说明，例如 "gpt-4o 并发度 10: P99 12.3s 超过上限 10s"
+func (v ThresholdViolation) String() string {
+	name := v.Result.ModelName
+	if v.Result.Scenario != "" {
+		name += "/" + v.Result.Scenario
+	}
+	if v.Result.StreamMode != "" {
+		name += "/" + v.Result.StreamMode
+	}
+	if v.Result.ModelError != "" {
+		return fmt.Sprintf("%s: %s", name, strings.Join(v.Reasons, "; "))
+	}
+	return fmt.Sprintf("%s 并发度 %d: %s", name, v.Result.ConcurrencyLevel, strings.Join(v.Reasons, "; "))
+}
+
+// CheckThresholds 按配置的性能门槛检查每个测试结果，返回未达到门槛的结果，按结果的键排序
+// 没有配置门槛的模型不检查；配置了门槛但测试出错的模型视为未达到门槛
+func CheckThresholds(results map[string]*TestResult, cfg *config.Config) []ThresholdViolation {
+	keys := make([]string, 0, len(results))
+	for key := range results {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var violations []ThresholdViolation
+	for _, key := range keys {
+		result := results[key]
+		thresholds := cfg.ThresholdsFor(result.ModelName)
+		if thresholds == nil {
+			continue
+		}
+		if reasons := checkResult(result, thresholds); len(reasons) > 0 {
+			violations = append(violations, ThresholdViolation{Result: result, Reasons: reasons})
+		}
+	}
+	return violations
+}
+
+// checkResult 返回一个测试结果违反的所有门槛
+func checkResult(result *TestResult, thresholds *config.ThresholdsConfig) []string {
+	if result.ModelError != "" {
+		return []string{"测试出错: " + result.ModelError}
+	}
+
+	var reasons []string
+	if thresholds.MaxP99 > 0 {
+		if p99, ok := result.LatencyPercentiles[99]; !ok {
+			reasons = append(reasons, "没有成功请求，无法计算P99")
+		} else if p99 > thresholds.MaxP99 {
+			reasons = append(reasons, fmt.Sprintf("P99 %s 超过上限 %s", p99, thresholds.MaxP99))
+		}
+	}
+	if thresholds.MinSuccessRate > 0 {
+		successRate := 0.0
+		if result.TotalRequests > 0 {
+			successRate = float64(result.SuccessRequests) / float64(result.TotalRequests)
+		}
+		if successRate < thresholds.MinSuccessRate {
+			reasons = append(reasons, fmt.Sprintf("成功率 %.2f%% 低于下限 %.2f%%", successRate*100, thresholds.MinSuccessRate*100))
+		}
+	}
+	if thresholds.MinRPS > 0 && result.RequestsPerSec < thresholds.MinRPS {
+		reasons = append(reasons, fmt.Sprintf("RPS %.2f 低于下限 %.2f", result.RequestsPerSec, thresholds.MinRPS))
+	}
+	return reasons
+}
@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/model"
+)
+
+// ValidationResult 校验模式下一个请求的结果
+type ValidationResult struct {
+	ModelName string
+	// 请求使用的API密钥在报告中显示的名称，模型只配置了一个密钥时为空
+	APIKey string
+	// 请求使用的代理，模型没有配置代理池时为空
+	Proxy    string
+	Latency  time.Duration
+	Response *model.LLMResponse
+	Err      error
+}
+
+// ValidateModel 向模型发送校验请求，请求的构造与正式测试相同：流式设置、请求超时、场景中的工具、
+// 对话历史和图片，以及API密钥和代理的轮换。配置了多个密钥或代理池时按轮换顺序依次发送请求，
+// 直到每个密钥和代理都至少使用过一次，返回每个请求的结果
+func (e *TestEngine) ValidateModel(ctx context.Context, mdl model.LLMModel, prompt config.PromptConfig) []ValidationResult {
+	run := e.newLevelRun(mdl, prompt, &TestResult{
+		ModelName:        mdl.GetName(),
+		Scenario:         prompt.Name,
+		ConcurrencyLevel: 1,
+		ErrorsByCategory: make(map[string]int),
+	})
+
+	requests := max(1, len(run.keyRequests), len(run.proxyRequests))
+	results := make([]ValidationResult, 0, requests)
+	for i := 0; i < requests && ctx.Err() == nil; i++ {
+		result := ValidationResult{ModelName: mdl.GetName()}
+		// 请求依次发送，第i个请求使用轮换中的第i个密钥和代理
+		if n := len(run.keyRequests); n > 0 {
+			result.APIKey = mdl.(model.APIKeyRotator).APIKeyLabel(i % n)
+		}
+		if n := len(run.proxyRequests); n > 0 {
+			result.Proxy = mdl.(model.ProxyRotator).ProxyNames()[i%n]
+		}
+		result.Response, result.Latency, result.Err = run.attempt(ctx, prompt)
+		results = append(results, result)
+	}
+	return results
+}
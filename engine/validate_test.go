@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
+	"github.com/lemonlinger/llm-test/model"
+)
+
+// rotatingModel 配置了两个API密钥和三个代理的模型，记录每个请求使用的密钥和代理，第二个密钥无效
+type rotatingModel struct {
+	model.BaseModel
+	keys, proxies []int
+	tools         []bool
+}
+
+func (m *rotatingModel) APIKeyCount() int         { return 2 }
+func (m *rotatingModel) APIKeyLabel(i int) string { return fmt.Sprintf("#%d", i+1) }
+func (m *rotatingModel) ProxyNames() []string     { return []string{"p1", "p2", "p3"} }
+
+func (m *rotatingModel) GenerateResponse(ctx context.Context, systemMessage, userMessage string, stream bool) (*model.LLMResponse, error) {
+	key, _ := ctx.Value(model.APIKeyIndexContextKey).(int)
+	proxy, _ := ctx.Value(model.ProxyIndexContextKey).(int)
+	_, hasTools := ctx.Value(model.ToolsContextKey).(json.RawMessage)
+	m.keys = append(m.keys, key)
+	m.proxies = append(m.proxies, proxy)
+	m.tools = append(m.tools, hasTools)
+	if key == 1 {
+		return nil, &model.APIError{StatusCode: 401, Model: m.GetName()}
+	}
+	return &model.LLMResponse{Content: "ok", InputTokens: 1, OutputTokens: 1}, nil
+}
+
+// 校验请求与正式测试一样轮换密钥和代理，每个密钥和代理都至少使用一次
+func TestValidateModelRotatesKeysAndProxies(t *testing.T) {
+	mdl := &rotatingModel{BaseModel: model.NewBaseModel(config.ModelConfig{Name: "gateway"}, logging.New(logging.LevelQuiet))}
+	prompt := config.PromptConfig{UserMessage: "hi", Tools: `[{"type":"function","function":{"name":"f"}}]`}
+	testEngine := NewTestEngine(config.TestConfig{RequestTimeout: time.Second}, []model.LLMModel{mdl}, []config.PromptConfig{prompt}, nil)
+
+	results := testEngine.ValidateModel(context.Background(), mdl, prompt)
+	if len(results) != 3 {
+		t.Fatalf("应当发送3个请求（代理数），实际为 %d", len(results))
+	}
+	wantKeys, wantProxies := []int{0, 1, 0}, []int{0, 1, 2}
+	for i, result := range results {
+		if mdl.keys[i] != wantKeys[i] || mdl.proxies[i] != wantProxies[i] || !mdl.tools[i] {
+			t.Errorf("第 %d 个请求使用密钥 %d、代理 %d、工具 %v，期望密钥 %d、代理 %d 并带上工具",
+				i, mdl.keys[i], mdl.proxies[i], mdl.tools[i], wantKeys[i], wantProxies[i])
+		}
+		if want := fmt.Sprintf("#%d", wantKeys[i]+1); result.APIKey != want {
+			t.Errorf("第 %d 个结果的密钥为 %s，期望 %s", i, result.APIKey, want)
+		}
+		if want := fmt.Sprintf("p%d", wantProxies[i]+1); result.Proxy != want {
+			t.Errorf("第 %d 个结果的代理为 %s，期望 %s", i, result.Proxy, want)
+		}
+		var apiErr *model.APIError
+		if failed := errors.As(result.Err, &apiErr); failed != (wantKeys[i] == 1) {
+			t.Errorf("第 %d 个结果的错误为 %v", i, result.Err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Level 日志级别
+type Level int
+
+// 日志级别定义，级别越高输出越详细
+const (
+	LevelQuiet Level = iota // 只输出错误
+	LevelInfo               // 额外输出警告和一般信息
+	LevelDebug              // 额外输出每个请求的调试信息，例如延迟、代理、流式速率
+)
+
+// ParseLevel 解析日志级别名称：quiet、info、debug
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "quiet":
+		return LevelQuiet, nil
+	case "info":
+		return LevelInfo, nil
+	case "debug":
+		return LevelDebug, nil
+	default:
+		return LevelQuiet, fmt.Errorf("不支持的日志级别: %s，可选值为 quiet、info、debug", name)
+	}
+}
+
+// String 返回日志级别名称
+func (l Level) String() string {
+	switch l {
+	case LevelInfo:
+		return "info"
+	case LevelDebug:
+		return "debug"
+	default:
+		return "quiet"
+	}
+}
+
+// Logger 按级别过滤的日志输出，底层使用标准库 log
+// nil 的 Logger 等同于 quiet 级别
+type Logger struct {
+	level Level
+}
+
+// New 创建指定级别的Logger
+func New(level Level) *Logger {
+	return &Logger{level: level}
+}
+
+// Level 返回当前日志级别
+func (l *Logger) Level() Level {
+	if l == nil {
+		return LevelQuiet
+	}
+	return l.level
+}
+
+// Errorf 输出错误日志，任何级别下都会输出
+func (l *Logger) Errorf(format string, args ...any) {
+	log.Printf(format, args...)
+}
+
+// Infof 输出一般信息，info及以上级别输出
+func (l *Logger) Infof(format string, args ...any) {
+	if l.Level() >= LevelInfo {
+		log.Printf(format, args...)
+	}
+}
+
+// Debugf 输出调试信息，仅debug级别输出
+func (l *Logger) Debugf(format string, args ...any) {
+	if l.Level() >= LevelDebug {
+		log.Printf(format, args...)
+	}
+}
@@ -1,29 +1,88 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/lemonlinger/llm-test/config"
 	"github.com/lemonlinger/llm-test/engine"
+	"github.com/lemonlinger/llm-test/logging"
 	"github.com/lemonlinger/llm-test/model"
 	"github.com/lemonlinger/llm-test/report"
+	"github.com/lemonlinger/llm-test/tui"
 )
 
+// 工具版本，构建时通过 -ldflags "-X main.version=v1.2.3" 设置
+var version = "dev"
+
 func main() {
 	// 解析命令行参数
-	configFile := flag.String("config", "config.yaml", "配置文件路径")
+	var configFiles configFileList
+	flag.Var(&configFiles, "config", "配置文件路径，可以指定多次按顺序合并，\"-\"表示从标准输入读取 (默认 \"config.yaml\")")
 	concurrency := flag.Int("concurrency", 0, "并发数 (覆盖配置文件)")
 	duration := flag.Duration("duration", 0, "测试持续时间 (覆盖配置文件)")
-	outputFormat := flag.String("output", "text", "输出格式: text, json, csv")
+	totalRequests := flag.Int("requests", 0, "每个并发度发送的固定请求数，与 -duration 互斥 (覆盖配置文件)")
+	maxTokens := flag.Int("max-tokens", 0, "所有模型的最大生成token数 (覆盖配置文件中的 params.max_tokens)")
+	temperature := flag.Float64("temperature", -1, "所有模型的temperature，小于0时不覆盖 (覆盖配置文件中的 params.temperature)")
+	outputFormat := flag.String("output", "text", "输出格式: text, json, csv, csv-raw (每个请求一行), timeline (按时间窗口统计), prometheus，多个格式用逗号分隔，标准输出显示第一个格式")
+	outputFile := flag.String("output-file", "", "报告文件路径，指定后原样使用，不再生成带时间戳的文件名")
+	outputDir := flag.String("output-dir", ".", "报告文件目录，未指定 -output-file 时带时间戳的报告保存在该目录下")
+	stdoutOnly := flag.Bool("output-stdout-only", false, "只在标准输出显示报告（所有格式依次输出），不保存报告和对比报告文件，与 -output-file-only 互斥")
+	fileOnly := flag.Bool("output-file-only", false, "只保存报告文件，不在标准输出显示报告和对比报告，与 -output-stdout-only 互斥")
+	baselineFile := flag.String("baseline", "", "基线JSON报告路径，指定后输出与基线的对比报告")
+	mergeFile := flag.String("merge", "", "之前保存的JSON报告路径，指定后将本次结果合并到该报告的结果中再生成报告，相同模型、场景和并发度的结果使用本次的结果")
+	jsonlFile := flag.String("jsonl", "", "JSON Lines结果文件路径，每完成一个并发度就追加写入一行结果，便于长时间测试时实时查看")
+	chartsDir := flag.String("charts", "", "图表输出目录，指定后为每个模型生成延迟分布和RPS的PNG图表")
+	chartFont := flag.String("chart-font", "", "图表使用的TTF或OTF字体文件，模型名称包含中日韩文字时需要指定包含对应字形的字体")
+	seed := flag.Int64("seed", 0, "随机种子，相同的种子产生相同的提示词序列 (覆盖配置文件，默认使用当前时间)")
+	logLevel := flag.String("log-level", "quiet", "日志级别: quiet (只输出错误), info, debug (输出每个请求的延迟、代理等调试信息)")
+	continueOnModelError := flag.Bool("continue-on-model-error", false, "某个模型测试出错时继续测试其他模型，并在报告中列出出错的模型 (覆盖配置文件)")
+	modelNames := flag.String("models", "", "只测试指定的模型，多个模型用逗号分隔，为空时测试所有模型")
+	validate := flag.Bool("validate", false, "只校验配置和连通性：每个模型发送一个请求后退出")
+	tuiMode := flag.Bool("tui", false, "以终端实时面板显示每个模型的RPS、成功率、延迟百分位和进行中的请求数，标准输出不是终端时使用进度提示")
+	listProviders := flag.Bool("list-providers", false, "列出配置中 type 可以使用的模型类型后退出")
+	checkConfig := flag.Bool("check-config", false, "只检查配置文件：一次列出所有未知的配置项、类型错误和不合法的配置后退出，不发送请求")
 
 	flag.Parse()
 
+	if *listProviders {
+		printProviders(os.Stdout)
+		return
+	}
+
+	formats, err := parseOutputFormats(*outputFormat)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if *stdoutOnly && *fileOnly {
+		log.Fatalf("-output-stdout-only 和 -output-file-only 不能同时指定：前者不保存报告文件，后者不在标准输出显示报告")
+	}
+	if *stdoutOnly && *outputFile != "" {
+		log.Fatalf("指定 -output-stdout-only 时不保存报告文件，不能同时指定 -output-file")
+	}
+
 	// 加载配置
-	cfg, err := config.LoadConfig(*configFile)
+	if len(configFiles) == 0 {
+		configFiles = configFileList{"config.yaml"}
+	}
+	if *checkConfig {
+		if !checkConfigFiles(os.Stdout, configFiles) {
+			os.Exit(1)
+		}
+		return
+	}
+	cfg, err := config.LoadConfigs(configFiles)
 	if err != nil {
 		log.Fatalf("加载配置失败: %v", err)
 	}
@@ -32,55 +91,393 @@ func main() {
 	if *concurrency > 0 {
 		cfg.Test.Concurrency = *concurrency
 	}
+	if *duration > 0 && *totalRequests > 0 {
+		log.Fatalf("-duration 和 -requests 不能同时指定")
+	}
 	if *duration > 0 {
 		cfg.Test.Duration = *duration
+		cfg.Test.DurationPerLevel = nil
+		cfg.Test.TotalRequests = 0
+	}
+	if *totalRequests > 0 {
+		cfg.Test.TotalRequests = *totalRequests
+		cfg.Test.Duration = 0
+		cfg.Test.DurationPerLevel = nil
+	}
+
+	if *modelNames != "" {
+		if err := cfg.FilterModels(strings.Split(*modelNames, ",")); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
+	// 生成参数在初始化模型之前覆盖，模型客户端读取覆盖后的值
+	if *maxTokens > 0 {
+		cfg.OverrideParam("max_tokens", *maxTokens)
+	}
+	if *temperature >= 0 {
+		cfg.OverrideParam("temperature", *temperature)
+	}
+
+	// 逐请求的CSV报告和时间序列报告需要引擎保存每个请求的明细
+	if slices.Contains(formats, "csv-raw") || slices.Contains(formats, "timeline") {
+		cfg.Test.RecordRequests = true
+	}
+	if *continueOnModelError {
+		cfg.Test.ContinueOnModelError = true
+	}
+	if *seed != 0 {
+		cfg.Test.RandomSeed = *seed
+	}
+	// 实时面板代替进度提示；输出被重定向时无法重绘屏幕，退回进度提示
+	useTUI := *tuiMode && tui.IsTerminal(os.Stdout)
+	if *tuiMode {
+		cfg.Test.ShowProgress = !useTUI
+	}
+
+	// 加载基线结果，提前加载以便在测试前发现错误的文件
+	var baseline map[string]*engine.TestResult
+	if *baselineFile != "" {
+		baseline, err = report.LoadResults(*baselineFile)
+		if err != nil {
+			log.Fatalf("加载基线报告失败: %v", err)
+		}
+	}
+	var previousResults map[string]*engine.TestResult
+	if *mergeFile != "" {
+		previousResults, err = report.LoadResults(*mergeFile)
+		if err != nil {
+			log.Fatalf("加载要合并的报告失败: %v", err)
+		}
+	}
+
+	level, err := logging.ParseLevel(*logLevel)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
 
-	// 初始化模型
-	models, err := model.InitializeModels(cfg.Models, cfg.Proxies)
+	// 初始化模型并创建测试引擎，未指定随机种子时使用当前时间，并在测试开始时输出，便于复现
+	testEngine, err := engine.NewTestEngineFromConfig(cfg, logging.New(level))
 	if err != nil {
-		log.Fatalf("初始化模型失败: %v", err)
+		log.Fatalf("%v", err)
+	}
+	models := testEngine.Models()
+	printEndpoints(os.Stdout, models)
+
+	// 第一个提示词场景用于校验模式和报告文件命名
+	promptConfig := cfg.Prompts[0]
+
+	// 校验模式：每个模型只发送一个请求，不运行完整测试
+	if *validate {
+		if !validateModels(testEngine, models, promptConfig) {
+			os.Exit(1)
+		}
+		return
 	}
 
-	// 选择合适的提示词配置
-	// 否则使用常规配置
-	promptConfig := cfg.Prompt
 	fmt.Println("开始LLM API性能测试")
-	fmt.Printf("测试配置: 并发数=%d, 持续时间=%s, 流式测试=%v\n",
-		cfg.Test.Concurrency,
-		cfg.Test.Duration.String(),
-		promptConfig.Stream)
+	if cfg.Test.TotalRequests > 0 {
+		fmt.Printf("测试配置: 并发数=%d, 请求数=%d, 流式测试=%v\n",
+			cfg.Test.Concurrency,
+			cfg.Test.TotalRequests,
+			promptConfig.Stream)
+	} else {
+		fmt.Printf("测试配置: 并发数=%d, 持续时间=%s, 流式测试=%v\n",
+			cfg.Test.Concurrency,
+			cfg.Test.Duration.String(),
+			promptConfig.Stream)
+	}
+	fmt.Printf("随机种子: %d\n", cfg.Test.RandomSeed)
 	fmt.Printf("测试模型: %v\n", getModelNames(models))
+	if len(cfg.Prompts) > 1 {
+		fmt.Printf("测试场景: %v\n", getScenarioNames(cfg.Prompts))
+	}
+
+	// 创建可取消的根上下文，所有请求的超时都从它派生
+	rootCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	// 创建并启动测试引擎
-	testEngine := engine.NewTestEngine(cfg.Test, models, promptConfig, cfg.Proxies)
-	results, err := testEngine.Run()
+	// 收到中断信号时取消测试：停止派发新请求，取消进行中的请求后生成部分报告
+	ctx, stop := signal.NotifyContext(rootCtx, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		// 恢复默认的信号处理，再次按下Ctrl-C时直接退出
+		stop()
+		if rootCtx.Err() != nil {
+			// 根上下文被取消（程序正常结束），不是收到了中断信号
+			return
+		}
+		fmt.Println("\n收到中断信号，正在停止进行中的请求并生成部分报告（再次按Ctrl-C强制退出）...")
+	}()
+
+	// 创建报告生成器，图表、对比报告等与格式无关的输出使用第一个格式的生成器
+	latencyFormat, err := report.ParseLatencyFormat(cfg.Report.LatencyUnit, cfg.Report.Precision())
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	reporter := report.NewReporter(formats[0])
+	reporter.SetLatencyFormat(latencyFormat)
+	reporter.SetTimelineInterval(cfg.Report.TimelineInterval)
+	// 字体有误时同样在测试开始前退出
+	if *chartFont != "" {
+		if err := reporter.SetChartFont(*chartFont); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
+	// 模板有误时在测试开始前退出，避免长时间的测试结束后才发现无法通知
+	var notifier *report.WebhookNotifier
+	if cfg.Webhook != nil {
+		notifier, err = report.NewWebhookNotifier(*cfg.Webhook)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		notifier.SetLatencyFormat(latencyFormat)
+	}
+
+	// 测试进行中按批推送逐请求指标
+	var pusher *report.MetricsPusher
+	if cfg.MetricsPush != nil {
+		pusher = report.NewMetricsPusher(*cfg.MetricsPush)
+		testEngine.SetRequestCallback(pusher.Push)
+	}
+
+	var dashboard *tui.Dashboard
+	if useTUI {
+		dashboard = tui.New(os.Stdout, cfg.Test.LatencyPercentiles)
+		testEngine.SetLiveStatsCallback(dashboard.Update)
+	}
+
+	// 每完成一个并发度，输出该并发度的简要结果，使用实时面板时显示在面板中
+	testEngine.SetResultCallback(func(result *engine.TestResult) {
+		if dashboard != nil {
+			dashboard.AddResult(result)
+			return
+		}
+		fmt.Printf("  %s 完成: 成功 %d/%d, RPS %.2f, 平均延迟 %s\n",
+			result.ModelName, result.SuccessRequests, result.TotalRequests, result.RequestsPerSec, result.AvgLatency.Round(time.Millisecond))
+	})
+
+	// 边测试边写入JSON Lines结果
+	var streamDone chan error
+	if *jsonlFile != "" {
+		f, err := os.Create(*jsonlFile)
+		if err != nil {
+			log.Fatalf("创建JSON Lines结果文件失败: %v", err)
+		}
+		defer f.Close()
+
+		resultCh := make(chan *engine.TestResult, 16)
+		testEngine.SetResultChannel(resultCh)
+		streamDone = make(chan error, 1)
+		go func() {
+			streamDone <- reporter.StreamResults(f, resultCh)
+		}()
+	}
+
+	startTime := time.Now()
+	if dashboard != nil {
+		dashboard.Start()
+	}
+	results, err := testEngine.Run(ctx)
+	if dashboard != nil {
+		dashboard.Stop()
+	}
 	if err != nil {
 		log.Fatalf("测试执行失败: %v", err)
 	}
 
+	if pusher != nil {
+		stats := pusher.Close()
+		if stats.Dropped > 0 {
+			fmt.Printf("已推送 %d 个请求的指标，丢弃 %d 个（%d 批推送失败）\n", stats.Sent, stats.Dropped, stats.FailedBatches)
+		} else {
+			fmt.Printf("已推送 %d 个请求的指标\n", stats.Sent)
+		}
+	}
+
+	if streamDone != nil {
+		if err := <-streamDone; err != nil {
+			log.Printf("%v", err)
+		} else {
+			fmt.Printf("JSON Lines结果已保存至: %s\n", *jsonlFile)
+		}
+	}
+
+	// 合并之前保存的结果，报告、图表和对比报告都使用合并后的结果
+	if previousResults != nil {
+		replaced := 0
+		for key := range results {
+			if _, ok := previousResults[key]; ok {
+				replaced++
+			}
+		}
+		results = engine.MergeResults(previousResults, results)
+		fmt.Printf("已合并 %s 中的 %d 个结果，其中 %d 个被本次结果替换\n", *mergeFile, len(previousResults), replaced)
+	}
+
 	// 生成报告
-	reporter := report.NewReporter(*outputFormat)
-	reportContent, err := reporter.GenerateReport(results)
-	if err != nil {
-		log.Fatalf("生成报告失败: %v", err)
+	hostname, _ := os.Hostname()
+	metadata := &report.RunMetadata{
+		StartTime:   startTime,
+		EndTime:     time.Now(),
+		Config:      cfg,
+		ToolVersion: version,
+		Host:        hostname,
+	}
+	if !*fileOnly {
+		if ctx.Err() != nil {
+			fmt.Println("\n测试结果（测试被中断，仅包含已完成部分）:")
+		} else {
+			fmt.Println("\n测试结果:")
+		}
 	}
 
-	// 输出报告
-	fmt.Println("\n测试结果:")
-	fmt.Println(reportContent)
+	// 每个格式生成一个报告文件，标准输出只显示第一个格式的报告；
+	// -output-stdout-only 时不保存文件，依次显示所有格式的报告，-output-file-only 时不显示报告
+	// 指定 -output-file 时原样使用，否则在 -output-dir 下生成带时间戳的文件名，所有格式使用相同的时间戳
+	reportTime := time.Now()
+	var reportFile string
+	for i, format := range formats {
+		formatReporter := report.NewReporter(format)
+		formatReporter.SetLatencyFormat(latencyFormat)
+		formatReporter.SetTimelineInterval(cfg.Report.TimelineInterval)
+		reportContent, err := formatReporter.GenerateReport(results, metadata)
+		if err != nil {
+			log.Fatalf("生成%s报告失败: %v", format, err)
+		}
+		if *stdoutOnly {
+			fmt.Println(reportContent)
+			continue
+		}
+		if i == 0 && !*fileOnly {
+			fmt.Println(reportContent)
+		}
 
-	// 保存报告到文件
-	reportFile := fmt.Sprintf("llm_test_report_%s_%s.%s",
-		time.Now().Format("20060102_150405"),
-		map[bool]string{true: "stream", false: "standard"}[promptConfig.Stream],
-		*outputFormat)
-	err = os.WriteFile(reportFile, []byte(reportContent), 0644)
-	if err != nil {
-		log.Printf("保存报告失败: %v", err)
-	} else {
-		fmt.Printf("报告已保存至: %s\n", reportFile)
+		file := reportFilePath(*outputFile, *outputDir, reportTime, promptConfig.Stream, formatReporter.FileExtension(), len(formats) > 1)
+		if i == 0 {
+			reportFile = file
+		}
+		if dir := filepath.Dir(file); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				log.Fatalf("创建报告目录失败: %v", err)
+			}
+		}
+		if err := os.WriteFile(file, []byte(reportContent), 0644); err != nil {
+			log.Printf("保存报告失败: %v", err)
+		} else {
+			fmt.Printf("报告已保存至: %s\n", file)
+		}
+	}
+
+	// 生成图表
+	if *chartsDir != "" {
+		if err := reporter.GenerateCharts(results, *chartsDir); err != nil {
+			log.Printf("生成图表失败: %v", err)
+		} else {
+			fmt.Printf("图表已保存至: %s\n", *chartsDir)
+		}
+	}
+
+	// 生成与基线的对比报告
+	if baseline != nil {
+		diffContent, err := reporter.GenerateDiffReport(baseline, results)
+		if err != nil {
+			log.Fatalf("生成对比报告失败: %v", err)
+		}
+
+		if !*fileOnly {
+			fmt.Println("\n与基线对比:")
+			fmt.Println(diffContent)
+		}
+
+		if !*stdoutOnly {
+			diffFile := filepath.Join(filepath.Dir(reportFile), fmt.Sprintf("llm_test_diff_%s.md", time.Now().Format("20060102_150405")))
+			if err := os.WriteFile(diffFile, []byte(diffContent), 0644); err != nil {
+				log.Printf("保存对比报告失败: %v", err)
+			} else {
+				fmt.Printf("对比报告已保存至: %s\n", diffFile)
+			}
+		}
+	}
+
+	violations := engine.CheckThresholds(results, cfg)
+
+	// 发送完成通知，测试被中断时同样发送；通知失败不影响退出码
+	if notifier != nil {
+		if err := notifier.Notify(context.Background(), results, metadata, violations); err != nil {
+			log.Printf("发送完成通知失败: %v", err)
+		} else {
+			fmt.Println("已发送完成通知")
+		}
+	}
+
+	// 检查性能门槛，报告保存之后再退出，便于CI中查看失败的原因
+	if len(violations) > 0 {
+		fmt.Println("\n性能门槛检查未通过:")
+		for _, violation := range violations {
+			fmt.Printf("  %s\n", violation)
+		}
+		os.Exit(1)
+	} else if hasThresholds(cfg) {
+		fmt.Println("\n性能门槛检查通过")
+	}
+}
+
+// hasThresholds 返回是否有任何测试的模型配置了性能门槛
+func hasThresholds(cfg *config.Config) bool {
+	for _, mdl := range cfg.Models {
+		if !mdl.Skip && cfg.ThresholdsFor(mdl.Name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// parseOutputFormats 解析逗号分隔的 -output 参数，去掉重复的格式，格式不支持时返回错误
+func parseOutputFormats(value string) ([]string, error) {
+	var formats []string
+	for _, format := range strings.Split(value, ",") {
+		format = strings.TrimSpace(format)
+		if format == "" || slices.Contains(formats, format) {
+			continue
+		}
+		if !slices.Contains(report.SupportedFormats, format) {
+			return nil, fmt.Errorf("不支持的输出格式: %s，支持的格式: %s", format, strings.Join(report.SupportedFormats, ", "))
+		}
+		formats = append(formats, format)
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("-output 至少需要指定一个输出格式")
+	}
+	return formats, nil
+}
+
+// reportFilePath 返回报告文件的路径：指定了 outputFile 时原样使用，输出多个格式时把其扩展名替换为各格式的扩展名；
+// 否则在 outputDir 下生成 llm_test_report_<时间戳>_<stream|standard>.<扩展名>
+func reportFilePath(outputFile, outputDir string, timestamp time.Time, stream bool, extension string, multiple bool) string {
+	if outputFile != "" {
+		if !multiple {
+			return outputFile
+		}
+		return strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + "." + extension
 	}
+	return filepath.Join(outputDir, fmt.Sprintf("llm_test_report_%s_%s.%s",
+		timestamp.Format("20060102_150405"),
+		map[bool]string{true: "stream", false: "standard"}[stream],
+		extension))
+}
+
+// configFileList 支持多次指定的 -config 参数
+type configFileList []string
+
+func (l *configFileList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *configFileList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
 func getModelNames(models []model.LLMModel) []string {
@@ -90,3 +487,89 @@ func getModelNames(models []model.LLMModel) []string {
 	}
 	return names
 }
+
+// checkConfigFiles 加载配置并列出发现的所有问题，每个问题一行，配置没有问题时返回true
+func checkConfigFiles(out io.Writer, configFiles []string) bool {
+	cfg, err := config.LoadConfigs(configFiles)
+	if err == nil {
+		fmt.Fprintf(out, "配置检查通过: %d 个模型, %d 个提示词场景\n", len(cfg.Models), len(cfg.Prompts))
+		return true
+	}
+	var configErrs config.ConfigErrors
+	if !errors.As(err, &configErrs) {
+		fmt.Fprintf(out, "配置检查失败: %v\n", err)
+		return false
+	}
+	fmt.Fprintf(out, "配置检查发现 %d 个问题:\n", len(configErrs))
+	for _, configErr := range configErrs {
+		fmt.Fprintf(out, "  %s\n", configErr)
+	}
+	return false
+}
+
+// printEndpoints 输出每个模型实际请求的URL，便于在测试开始前发现 base_url 配置错误
+func printEndpoints(out io.Writer, models []model.LLMModel) {
+	for _, mdl := range models {
+		if provider, ok := mdl.(model.EndpointProvider); ok {
+			fmt.Fprintf(out, "模型 %s 的请求地址: %s\n", mdl.GetName(), provider.Endpoint())
+		}
+	}
+}
+
+func getScenarioNames(prompts []config.PromptConfig) []string {
+	names := make([]string, len(prompts))
+	for i, p := range prompts {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// printProviders 输出所有可用的模型类型、是否需要API密钥和说明
+func printProviders(out io.Writer) {
+	providers := model.Providers()
+	width := 0
+	for _, provider := range providers {
+		width = max(width, len(provider.Name))
+	}
+	fmt.Fprintln(out, "支持的模型类型（配置中的 type）:")
+	for _, provider := range providers {
+		requiresKey := "无需密钥"
+		if provider.RequiresAPIKey {
+			requiresKey = "需要密钥"
+		}
+		fmt.Fprintf(out, "  %-*s  %s  %s\n", width, provider.Name, requiresKey, provider.Description)
+	}
+}
+
+// validateModels 使用测试引擎向每个模型发送校验请求并打印结果，全部成功时返回true
+// 配置了多个API密钥或代理池的模型会对每个密钥和代理分别发送请求
+func validateModels(testEngine *engine.TestEngine, models []model.LLMModel, prompt config.PromptConfig) bool {
+	fmt.Println("开始校验模型配置和连通性")
+
+	allOK := true
+	for _, mdl := range models {
+		for _, result := range testEngine.ValidateModel(context.Background(), mdl, prompt) {
+			name := result.ModelName
+			var via []string
+			if result.APIKey != "" {
+				via = append(via, "密钥 "+result.APIKey)
+			}
+			if result.Proxy != "" {
+				via = append(via, "代理 "+result.Proxy)
+			}
+			if len(via) > 0 {
+				name += " (" + strings.Join(via, ", ") + ")"
+			}
+
+			if result.Err != nil {
+				allOK = false
+				fmt.Printf("  [失败] %s: 延迟=%s, 错误=%v\n", name, result.Latency, result.Err)
+				continue
+			}
+			fmt.Printf("  [成功] %s: 延迟=%s, 输入Token=%d, 输出Token=%d\n",
+				name, result.Latency, result.Response.InputTokens, result.Response.OutputTokens)
+		}
+	}
+
+	return allOK
+}
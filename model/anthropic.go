@@ -1,28 +1,156 @@
 package model
 
 import (
+	"bytes"
 	"context"
-	"log"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 
 	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
 )
 
-// AnthropicModel Anthropic模型实现
+// Anthropic API的默认地址和版本
+const (
+	defaultAnthropicBaseURL = "https://api.anthropic.com"
+	defaultAnthropicVersion = "2023-06-01"
+)
+
+// AnthropicModel Anthropic模型实现，使用 /v1/messages 接口
 type AnthropicModel struct {
 	BaseModel
 	defaultClient *http.Client
 	proxyClients  map[string]*http.Client // 代理名称到对应HTTP客户端的映射
 }
 
+// 提示词缓存断点的位置，通过 params.cache_control 设置
+const (
+	anthropicCacheSystem   = "system"   // 在系统提示词上设置缓存断点
+	anthropicCacheMessages = "messages" // 在最后一条消息上设置缓存断点，缓存整个提示词
+	anthropicCacheAll      = "all"      // 同时在系统提示词和最后一条消息上设置缓存断点
+)
+
+// AnthropicRequest 定义Anthropic /v1/messages 请求结构
+// System 为字符串，设置缓存断点时为内容块列表
+type AnthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	System      interface{}        `json:"system,omitempty"`
+	Messages    []AnthropicMessage `json:"messages"`
+	Stream      bool               `json:"stream,omitempty"`
+	Temperature *float64           `json:"temperature,omitempty"`
+}
+
+// AnthropicMessage 定义Anthropic消息结构
+// Content 为字符串，设置缓存断点或者带图片时为内容块列表
+type AnthropicMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+// AnthropicContentBlock 定义文本或图片内容块
+type AnthropicContentBlock struct {
+	Type         string                 `json:"type"`
+	Text         string                 `json:"text,omitempty"`
+	Source       *AnthropicImageSource  `json:"source,omitempty"`
+	CacheControl *AnthropicCacheControl `json:"cache_control,omitempty"`
+}
+
+// AnthropicImageSource 定义图片内容块的来源，base64 类型带图片数据，url 类型带图片地址
+type AnthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// AnthropicCacheControl 定义提示词缓存断点，断点之前（包括该内容块）的提示词会被缓存
+type AnthropicCacheControl struct {
+	Type string `json:"type"`
+}
+
+// AnthropicUsage 定义Anthropic的token用量
+// 使用提示词缓存时，InputTokens 不包括写入和读取缓存的token数
+type AnthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// AnthropicResponse 定义Anthropic非流式响应结构
+type AnthropicResponse struct {
+	ID      string `json:"id"`
+	Content []struct {
+		Type     string `json:"type"`
+		Text     string `json:"text"`
+		Thinking string `json:"thinking"`
+	} `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      AnthropicUsage `json:"usage"`
+}
+
+// AnthropicStreamEvent 定义Anthropic流式响应中的单个事件
+// message_start 携带输入token数，content_block_delta 携带增量文本，
+// message_delta 携带结束原因和累计的输出token数，error 表示流中途出错
+// 开启扩展思考（extended thinking）时，content_block_delta 中的 thinking_delta 携带增量的思考内容
+type AnthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage AnthropicUsage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		Thinking   string `json:"thinking"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *AnthropicUsage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
 // NewAnthropicModel 创建新的Anthropic模型
-func NewAnthropicModel(cfg config.ModelConfig, proxies []config.ProxyConfig) (*AnthropicModel, error) {
-	// 创建默认客户端
+func NewAnthropicModel(cfg config.ModelConfig, proxies []config.ProxyConfig, logger *logging.Logger) (*AnthropicModel, error) {
+	modelName, err := cfg.StringParam("model", "")
+	if err != nil {
+		return nil, err
+	}
+	if modelName == "" {
+		return nil, fmt.Errorf("Anthropic模型必须在 params.model 中指定模型名称")
+	}
+
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultAnthropicBaseURL
+	}
+	if _, err := cfg.StringListParam("anthropic_beta"); err != nil {
+		return nil, err
+	}
+	cacheControl, err := cfg.StringParam("cache_control", "")
+	if err != nil {
+		return nil, err
+	}
+	switch cacheControl {
+	case "", anthropicCacheSystem, anthropicCacheMessages, anthropicCacheAll:
+	default:
+		return nil, fmt.Errorf("模型 %s 的 cache_control 参数无效: %q，可选值为 system、messages、all", cfg.Name, cacheControl)
+	}
+
+	// 根据TLS配置加载CA证书和客户端证书，默认客户端和代理客户端共用
+	tlsConfig, err := newTLSConfig(cfg.HTTP.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建默认客户端，不设置客户端超时，由调用方通过context控制请求超时
 	defaultClient := &http.Client{
-		Timeout: 60 * time.Second,
+		Transport: newHTTPTransport(cfg.HTTP, tlsConfig, nil),
 	}
 
 	// 创建代理客户端映射
@@ -30,89 +158,360 @@ func NewAnthropicModel(cfg config.ModelConfig, proxies []config.ProxyConfig) (*A
 
 	// 为每个代理创建对应的HTTP客户端
 	for _, proxy := range proxies {
-		// 解析代理URL
-		parsedURL, err := url.Parse(proxy.URL)
+		// 解析代理URL，包含认证信息时由Transport设置 Proxy-Authorization
+		parsedURL, err := proxy.ProxyURL()
 		if err != nil {
-			log.Printf("解析代理URL失败 (%s): %v", proxy.Name, err)
+			logger.Infof("%v，跳过该代理", err)
 			continue
 		}
 
 		// 创建带有代理的Transport
-		transport := &http.Transport{
-			Proxy: http.ProxyURL(parsedURL),
-		}
+		transport := newHTTPTransport(cfg.HTTP, proxyTLSConfig(tlsConfig, proxy), parsedURL)
 
 		// 创建客户端并存储
 		proxyClients[proxy.Name] = &http.Client{
 			Transport: transport,
-			Timeout:   60 * time.Second,
 		}
 	}
 
 	return &AnthropicModel{
 		BaseModel: BaseModel{
 			config: cfg,
+			logger: logger,
 		},
 		defaultClient: defaultClient,
 		proxyClients:  proxyClients,
 	}, nil
 }
 
-// GenerateResponse 生成响应
+// GenerateResponse 生成响应，调用Anthropic的 /v1/messages 接口
 func (m *AnthropicModel) GenerateResponse(ctx context.Context, systemMessage, userMessage string, stream bool) (*LLMResponse, error) {
 	// 选择合适的HTTP客户端
-	var client *http.Client
+	client := m.defaultClient
 
-	// 如果模型配置了代理，并且代理客户端存在，则使用代理客户端
-	if m.config.ProxyName != "" {
-		if proxyClient, ok := m.proxyClients[m.config.ProxyName]; ok {
+	// 如果模型配置了代理，并且代理客户端存在，则使用代理客户端；配置了代理池时按请求轮流选择
+	proxyName := m.proxyName(ctx)
+	if proxyName != "" {
+		if proxyClient, ok := m.proxyClients[proxyName]; ok {
 			client = proxyClient
-			log.Printf("使用代理: %s", m.config.ProxyName)
+			m.logger.Debugf("使用代理: %s", proxyName)
+		} else {
+			m.logger.Infof("未找到配置的代理: %s，使用默认客户端", proxyName)
+		}
+	}
+
+	modelName, err := m.config.StringParam("model", "")
+	if err != nil {
+		return nil, err
+	}
+	// Anthropic要求必须指定 max_tokens
+	maxTokens, err := m.config.IntParam("max_tokens", 1024)
+	if err != nil {
+		return nil, err
+	}
+	version, err := m.config.StringParam("anthropic_version", defaultAnthropicVersion)
+	if err != nil {
+		return nil, err
+	}
+	betas, err := m.config.StringListParam("anthropic_beta")
+	if err != nil {
+		return nil, err
+	}
+	cacheControl, err := m.config.StringParam("cache_control", "")
+	if err != nil {
+		return nil, err
+	}
+
+	// 系统消息通过 system 字段单独发送，其余消息按原角色放入 messages
+	conversation := promptMessages(ctx, systemMessage, userMessage)
+	system, dialog := splitSystemMessages(conversation)
+	reqBody := AnthropicRequest{
+		Model:     modelName,
+		MaxTokens: maxTokens,
+		System:    system,
+		Messages:  make([]AnthropicMessage, 0, len(dialog)),
+		Stream:    stream,
+	}
+	for _, message := range dialog {
+		reqBody.Messages = append(reqBody.Messages, AnthropicMessage{Role: message.Role, Content: message.Content})
+	}
+	// 场景配置了图片时，最后一条用户消息改为图片和文本内容块
+	if images := promptImages(ctx); len(images) > 0 {
+		if i := lastUserMessage(dialog); i >= 0 {
+			reqBody.Messages[i].Content = anthropicImageContent(images, dialog[i].Content)
+		}
+	}
+	// 设置缓存断点时，对应的内容改为带 cache_control 的内容块
+	if system != "" && (cacheControl == anthropicCacheSystem || cacheControl == anthropicCacheAll) {
+		reqBody.System = anthropicCachedContent(system)
+	}
+	if len(dialog) > 0 && (cacheControl == anthropicCacheMessages || cacheControl == anthropicCacheAll) {
+		last := len(dialog) - 1
+		if blocks, ok := reqBody.Messages[last].Content.([]AnthropicContentBlock); ok {
+			blocks[len(blocks)-1].CacheControl = &AnthropicCacheControl{Type: "ephemeral"}
 		} else {
-			log.Printf("未找到配置的代理: %s，使用默认客户端", m.config.ProxyName)
-			client = m.defaultClient
+			reqBody.Messages[last].Content = anthropicCachedContent(dialog[last].Content)
+		}
+	}
+	if m.config.HasParam("temperature") {
+		temperature, err := m.config.FloatParam("temperature", 0)
+		if err != nil {
+			return nil, err
 		}
-	} else {
-		client = m.defaultClient
+		reqBody.Temperature = &temperature
 	}
 
-	// 这里应该实现真正的API调用，使用选择的client
-	// 目前只是记录一下使用的客户端，避免编译器警告
-	_ = client
+	// 序列化请求体
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求体失败: %w", err)
+	}
 
-	// 作为示例，我们只是模拟一个延迟并返回一个固定的响应
+	// 创建HTTP请求
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
+		m.Endpoint(),
+		bytes.NewBuffer(jsonData),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+
+	// 设置请求头
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	req.Header.Set("x-api-key", m.apiKey(ctx))
+	req.Header.Set("anthropic-version", version)
+	if len(betas) > 0 {
+		req.Header.Set("anthropic-beta", strings.Join(betas, ","))
+	}
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
 
-	// 计算输入token
-	inputTokens, err := m.CountTokens(systemMessage + userMessage)
+	// 发送请求
+	req, trace := traceRequest(req)
+	startTime := time.Now()
+	resp, err := client.Do(req)
 	if err != nil {
+		return nil, fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 网关返回压缩的响应时先解压，错误响应同样需要解压后才能读取错误信息
+	if err := decodeResponseBody(resp); err != nil {
 		return nil, err
 	}
 
-	// 模拟API延迟
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case <-time.After(time.Duration(250+inputTokens/8) * time.Millisecond):
-		// 模拟响应
-		response := "这是来自Anthropic模型的示例响应。"
+	// 检查状态码
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			Model:      m.config.Name,
+			RateLimit:  parseRateLimit(resp.Header),
+		}
+	}
+
+	result := &LLMResponse{RateLimit: parseRateLimit(resp.Header)}
+	trace.apply(result)
 
-		// 计算输出token
-		outputTokens, err := m.CountTokens(response)
+	// 非流式响应处理
+	if !stream {
+		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("读取响应体失败: %w", err)
 		}
+		result.ResponseBytes = int64(len(body))
 
-		return &LLMResponse{
-			Content:      response,
-			InputTokens:  inputTokens,
-			OutputTokens: outputTokens,
-		}, nil
+		m.logger.Debugf("Anthropic API请求延迟(非流式): %s", time.Since(startTime))
+
+		var anthropicResp AnthropicResponse
+		if err := json.Unmarshal(body, &anthropicResp); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrParseResponse, err)
+		}
+
+		var content, thinking strings.Builder
+		for _, block := range anthropicResp.Content {
+			switch block.Type {
+			case "text":
+				content.WriteString(block.Text)
+			case "thinking":
+				thinking.WriteString(block.Thinking)
+			}
+		}
+		result.Content = content.String()
+		m.estimateReasoningTokens(result, thinking.String())
+		result.InputTokens = anthropicResp.Usage.InputTokens
+		result.OutputTokens = anthropicResp.Usage.OutputTokens
+		result.CacheCreationInputTokens = anthropicResp.Usage.CacheCreationInputTokens
+		result.CacheReadInputTokens = anthropicResp.Usage.CacheReadInputTokens
+		result.FinishReason = anthropicFinishReason(anthropicResp.StopReason)
+		if result.InputTokens == 0 && result.OutputTokens == 0 {
+			m.estimateTokens(result, messagesText(conversation))
+		}
+		return result, nil
 	}
+
+	// 流式响应处理，Anthropic使用SSE格式，每个事件的 data 行是一个JSON对象
+	var fullContent, thinking strings.Builder
+	var firstTokenReceived bool
+	var timer tokenTimer
+	var usageReported bool
+
+	counter := &countingReader{r: resp.Body}
+	reader := newSSEReader(counter)
+	for {
+		sse, err := reader.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("读取流式响应失败: %w", err)
+		}
+
+		data := strings.TrimSpace(sse.Data)
+		var event AnthropicStreamEvent
+		if jsonErr := json.Unmarshal([]byte(data), &event); jsonErr != nil {
+			m.logger.Infof("解析流响应块失败: %v, 数据: %s", jsonErr, data)
+		} else {
+			switch event.Type {
+			case "message_start":
+				if event.Message.Usage.InputTokens > 0 {
+					usageReported = true
+					result.InputTokens = event.Message.Usage.InputTokens
+				}
+				result.CacheCreationInputTokens = event.Message.Usage.CacheCreationInputTokens
+				result.CacheReadInputTokens = event.Message.Usage.CacheReadInputTokens
+			case "content_block_delta":
+				if event.Delta.Type == "thinking_delta" {
+					thinking.WriteString(event.Delta.Thinking)
+					timer.observe()
+				}
+				if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+					// 记录首个token接收时间
+					if !firstTokenReceived {
+						firstTokenReceived = true
+						result.TimeToFirstToken = time.Since(startTime)
+					}
+					fullContent.WriteString(event.Delta.Text)
+					timer.observe()
+				}
+			case "message_delta":
+				result.FinishReason = anthropicFinishReason(event.Delta.StopReason)
+				// message_delta 中的输出token数是累计值
+				if event.Usage != nil {
+					usageReported = true
+					result.OutputTokens = event.Usage.OutputTokens
+					// 部分版本在 message_delta 中也返回缓存Token数，以最后返回的为准
+					if event.Usage.CacheCreationInputTokens > 0 || event.Usage.CacheReadInputTokens > 0 {
+						result.CacheCreationInputTokens = event.Usage.CacheCreationInputTokens
+						result.CacheReadInputTokens = event.Usage.CacheReadInputTokens
+					}
+				}
+			case "error":
+				// 流中途返回的错误，例如服务过载
+				if event.Error != nil {
+					return nil, fmt.Errorf("Anthropic流式响应错误(%s): %s", event.Error.Type, event.Error.Message)
+				}
+				return nil, fmt.Errorf("Anthropic流式响应错误: %s", data)
+			}
+		}
+	}
+
+	m.logger.Debugf("Anthropic API流式请求延迟(包含所有流式数据): %s", time.Since(startTime))
+
+	result.Content = fullContent.String()
+	result.ResponseBytes = counter.n
+	m.estimateReasoningTokens(result, thinking.String())
+
+	// 服务端没有返回usage时，使用 CountTokens 估算token数
+	if !usageReported {
+		m.estimateTokens(result, messagesText(conversation))
+	}
+
+	if firstTokenReceived {
+		result.TokensPerSecond = timer.tokensPerSecond(result.OutputTokens)
+		m.logger.Debugf("流式响应速率: %.2f tokens/sec", result.TokensPerSecond)
+	}
+
+	return result, nil
+}
+
+// anthropicCachedContent 将文本转换为带缓存断点的内容块列表
+func anthropicCachedContent(text string) []AnthropicContentBlock {
+	return []AnthropicContentBlock{{
+		Type:         "text",
+		Text:         text,
+		CacheControl: &AnthropicCacheControl{Type: "ephemeral"},
+	}}
+}
+
+// anthropicImageContent 返回图片和文本组成的内容块列表，图片放在文本之前
+// data URL 作为 base64 图片发送，其他地址作为 url 图片发送
+func anthropicImageContent(images []config.ImageConfig, text string) []AnthropicContentBlock {
+	blocks := make([]AnthropicContentBlock, 0, len(images)+1)
+	for _, image := range images {
+		source := &AnthropicImageSource{Type: "url", URL: image.Source()}
+		if mediaType, data, ok := config.ParseDataURL(image.Source()); ok {
+			source = &AnthropicImageSource{Type: "base64", MediaType: mediaType, Data: data}
+		}
+		blocks = append(blocks, AnthropicContentBlock{Type: "image", Source: source})
+	}
+	return append(blocks, AnthropicContentBlock{Type: "text", Text: text})
+}
+
+// SupportsImages Anthropic消息接口支持图片输入
+func (m *AnthropicModel) SupportsImages() bool {
+	return true
+}
+
+// estimateTokens 服务端未返回usage时，根据提示词和响应内容估算token数
+func (m *AnthropicModel) estimateTokens(result *LLMResponse, prompt string) {
+	inputTokens, _ := m.CountTokens(prompt)
+	outputTokens, _ := m.CountTokens(result.Content)
+	result.InputTokens = inputTokens
+	result.OutputTokens = outputTokens + result.ReasoningTokens
+	result.TokensEstimated = true
+}
+
+// estimateReasoningTokens 根据思考内容估算推理token数
+// Anthropic的usage不单独返回思考token数（已包含在输出token中），只能估算
+func (m *AnthropicModel) estimateReasoningTokens(result *LLMResponse, thinking string) {
+	if thinking == "" {
+		return
+	}
+	result.ReasoningTokens, _ = m.CountTokens(thinking)
+	result.TokensEstimated = true
 }
 
 // CountTokens 计算文本的token数量
+// 只在服务端没有返回usage时作为估算使用
 func (m *AnthropicModel) CountTokens(text string) (int, error) {
 	// 简单估算，实际应使用Claude的tokenizer
 	words := strings.Fields(text)
 	return len(words) + len(text)/4, nil
 }
+
+// anthropicFinishReason 将Anthropic的结束原因转换为通用格式
+func anthropicFinishReason(reason string) string {
+	switch reason {
+	case "":
+		return ""
+	case "end_turn", "stop_sequence":
+		return FinishReasonStop
+	case "max_tokens":
+		return FinishReasonLength
+	case "tool_use":
+		return FinishReasonToolCalls
+	default:
+		return reason
+	}
+}
+
+// Endpoint 返回Anthropic消息接口的URL
+func (m *AnthropicModel) Endpoint() string {
+	return m.chatURL("/v1/messages")
+}
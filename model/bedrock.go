@@ -0,0 +1,517 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
+)
+
+// Bedrock上模型的请求/响应格式
+const (
+	bedrockSchemaAnthropic = "anthropic"
+	bedrockSchemaTitan     = "titan"
+)
+
+// BedrockModel AWS Bedrock模型实现
+type BedrockModel struct {
+	BaseModel
+	defaultClient *http.Client
+	proxyClients  map[string]*http.Client // 代理名称到对应HTTP客户端的映射
+	region        string
+	modelID       string
+	schema        string
+	credentials   awsCredentials
+}
+
+// bedrockAnthropicRequest Anthropic-on-Bedrock 请求结构
+type bedrockAnthropicRequest struct {
+	AnthropicVersion string                    `json:"anthropic_version"`
+	MaxTokens        int                       `json:"max_tokens"`
+	System           string                    `json:"system,omitempty"`
+	Messages         []bedrockAnthropicMessage `json:"messages"`
+	Temperature      *float64                  `json:"temperature,omitempty"`
+}
+
+// bedrockAnthropicMessage Anthropic-on-Bedrock 消息结构
+type bedrockAnthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// bedrockAnthropicResponse Anthropic-on-Bedrock 响应结构
+type bedrockAnthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// bedrockTitanRequest Titan 请求结构
+type bedrockTitanRequest struct {
+	InputText            string `json:"inputText"`
+	TextGenerationConfig struct {
+		MaxTokenCount int      `json:"maxTokenCount"`
+		Temperature   *float64 `json:"temperature,omitempty"`
+	} `json:"textGenerationConfig"`
+}
+
+// bedrockTitanResponse Titan 响应结构
+type bedrockTitanResponse struct {
+	InputTextTokenCount int `json:"inputTextTokenCount"`
+	Results             []struct {
+		TokenCount int    `json:"tokenCount"`
+		OutputText string `json:"outputText"`
+	} `json:"results"`
+}
+
+// bedrockStreamChunk 流式响应中的数据块，同时兼容Anthropic和Titan两种格式
+type bedrockStreamChunk struct {
+	// Anthropic格式
+	Type    string `json:"type"`
+	Message struct {
+		Usage struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+
+	// Titan格式
+	OutputText                string `json:"outputText"`
+	InputTextTokenCount       int    `json:"inputTextTokenCount"`
+	TotalOutputTextTokenCount int    `json:"totalOutputTextTokenCount"`
+
+	// Bedrock在最后一个数据块中附带的调用统计
+	InvocationMetrics *struct {
+		InputTokenCount  int `json:"inputTokenCount"`
+		OutputTokenCount int `json:"outputTokenCount"`
+	} `json:"amazon-bedrock-invocationMetrics"`
+}
+
+// NewBedrockModel 创建新的Bedrock模型
+// 区域和模型ID从 params.region、params.model_id 读取，凭证优先使用 api_key/secret，
+// 未配置时读取 AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY 和 AWS_SESSION_TOKEN 环境变量
+func NewBedrockModel(cfg config.ModelConfig, proxies []config.ProxyConfig, logger *logging.Logger) (*BedrockModel, error) {
+	region, err := cfg.StringParam("region", "")
+	if err != nil {
+		return nil, err
+	}
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("Bedrock模型必须在 params.region 中指定区域")
+	}
+
+	modelID, err := cfg.StringParam("model_id", "")
+	if err != nil {
+		return nil, err
+	}
+	if modelID == "" {
+		if modelID, err = cfg.StringParam("model", ""); err != nil {
+			return nil, err
+		}
+	}
+	if modelID == "" {
+		return nil, fmt.Errorf("Bedrock模型必须在 params.model_id 中指定模型ID")
+	}
+
+	// 根据模型ID判断请求格式，兼容跨区域推理配置文件（例如 us.anthropic.xxx）
+	var schema string
+	switch {
+	case strings.Contains(modelID, "anthropic."):
+		schema = bedrockSchemaAnthropic
+	case strings.Contains(modelID, "amazon.titan"):
+		schema = bedrockSchemaTitan
+	default:
+		return nil, fmt.Errorf("不支持的Bedrock模型: %s，目前支持Anthropic和Titan模型", modelID)
+	}
+
+	credentials := awsCredentials{
+		AccessKeyID:     cfg.APIKey,
+		SecretAccessKey: cfg.Secret,
+	}
+	if credentials.AccessKeyID == "" {
+		credentials.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		credentials.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		credentials.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("Bedrock模型未配置AWS凭证: 请设置 api_key/secret 或 AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY 环境变量")
+	}
+
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region)
+	}
+
+	// 根据TLS配置加载CA证书和客户端证书，默认客户端和代理客户端共用
+	tlsConfig, err := newTLSConfig(cfg.HTTP.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建默认客户端，不设置客户端超时，由调用方通过context控制请求超时
+	defaultClient := &http.Client{
+		Transport: newHTTPTransport(cfg.HTTP, tlsConfig, nil),
+	}
+
+	// 创建代理客户端映射
+	proxyClients := make(map[string]*http.Client)
+
+	// 为每个代理创建对应的HTTP客户端
+	for _, proxy := range proxies {
+		// 解析代理URL，包含认证信息时由Transport设置 Proxy-Authorization
+		parsedURL, err := proxy.ProxyURL()
+		if err != nil {
+			logger.Infof("%v，跳过该代理", err)
+			continue
+		}
+
+		// 创建带有代理的Transport
+		transport := newHTTPTransport(cfg.HTTP, proxyTLSConfig(tlsConfig, proxy), parsedURL)
+
+		// 创建客户端并存储
+		proxyClients[proxy.Name] = &http.Client{
+			Transport: transport,
+		}
+	}
+
+	return &BedrockModel{
+		BaseModel: BaseModel{
+			config: cfg,
+			logger: logger,
+		},
+		defaultClient: defaultClient,
+		proxyClients:  proxyClients,
+		region:        region,
+		modelID:       modelID,
+		schema:        schema,
+		credentials:   credentials,
+	}, nil
+}
+
+// GenerateResponse 生成响应，调用Bedrock的 InvokeModel / InvokeModelWithResponseStream 接口
+func (m *BedrockModel) GenerateResponse(ctx context.Context, systemMessage, userMessage string, stream bool) (*LLMResponse, error) {
+	// 选择合适的HTTP客户端
+	client := m.defaultClient
+
+	// 如果模型配置了代理，并且代理客户端存在，则使用代理客户端；配置了代理池时按请求轮流选择
+	proxyName := m.proxyName(ctx)
+	if proxyName != "" {
+		if proxyClient, ok := m.proxyClients[proxyName]; ok {
+			client = proxyClient
+			m.logger.Debugf("使用代理: %s", proxyName)
+		} else {
+			m.logger.Infof("未找到配置的代理: %s，使用默认客户端", proxyName)
+		}
+	}
+
+	jsonData, err := m.buildRequestBody(promptMessages(ctx, systemMessage, userMessage))
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求体失败: %w", err)
+	}
+
+	action := "invoke"
+	if stream {
+		action = "invoke-with-response-stream"
+	}
+	endpoint, err := m.invokeURL(action)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+
+	// 设置请求头并签名
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	signRequestV4(req, jsonData, m.credentials, m.region, "bedrock", time.Now())
+
+	// 发送请求
+	req, trace := traceRequest(req)
+	startTime := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 网关返回压缩的响应时先解压，错误响应同样需要解压后才能读取错误信息
+	if err := decodeResponseBody(resp); err != nil {
+		return nil, err
+	}
+
+	// 检查状态码
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			Model:      m.config.Name,
+		}
+	}
+
+	if !stream {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("读取响应体失败: %w", err)
+		}
+
+		m.logger.Debugf("Bedrock API请求延迟(非流式): %s", time.Since(startTime))
+		result, err := m.parseResponse(body)
+		if err != nil {
+			return nil, err
+		}
+		result.ResponseBytes = int64(len(body))
+		trace.apply(result)
+		return result, nil
+	}
+
+	counter := &countingReader{r: resp.Body}
+	result, err := m.parseStream(counter, startTime)
+	if err != nil {
+		return nil, err
+	}
+	result.ResponseBytes = counter.n
+	trace.apply(result)
+	return result, nil
+}
+
+// buildRequestBody 根据模型格式构建请求体
+func (m *BedrockModel) buildRequestBody(messages []config.MessageConfig) ([]byte, error) {
+	maxTokens, err := m.config.IntParam("max_tokens", 1024)
+	if err != nil {
+		return nil, err
+	}
+
+	var temperature *float64
+	if m.config.HasParam("temperature") {
+		t, err := m.config.FloatParam("temperature", 0)
+		if err != nil {
+			return nil, err
+		}
+		temperature = &t
+	}
+
+	if m.schema == bedrockSchemaTitan {
+		// Titan没有对话结构，按顺序把所有消息拼接为输入文本
+		contents := make([]string, 0, len(messages))
+		for _, message := range messages {
+			contents = append(contents, message.Content)
+		}
+		inputText := strings.Join(contents, "\n\n")
+
+		var reqBody bedrockTitanRequest
+		reqBody.InputText = inputText
+		reqBody.TextGenerationConfig.MaxTokenCount = maxTokens
+		reqBody.TextGenerationConfig.Temperature = temperature
+		return json.Marshal(reqBody)
+	}
+
+	system, dialog := splitSystemMessages(messages)
+	reqBody := bedrockAnthropicRequest{
+		AnthropicVersion: "bedrock-2023-05-31",
+		MaxTokens:        maxTokens,
+		System:           system,
+		Messages:         make([]bedrockAnthropicMessage, 0, len(dialog)),
+		Temperature:      temperature,
+	}
+	for _, message := range dialog {
+		reqBody.Messages = append(reqBody.Messages, bedrockAnthropicMessage{Role: message.Role, Content: message.Content})
+	}
+	return json.Marshal(reqBody)
+}
+
+// parseResponse 解析非流式响应
+func (m *BedrockModel) parseResponse(body []byte) (*LLMResponse, error) {
+	result := &LLMResponse{}
+
+	if m.schema == bedrockSchemaTitan {
+		var titanResp bedrockTitanResponse
+		if err := json.Unmarshal(body, &titanResp); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrParseResponse, err)
+		}
+
+		result.InputTokens = titanResp.InputTextTokenCount
+		for _, r := range titanResp.Results {
+			result.Content += r.OutputText
+			result.OutputTokens += r.TokenCount
+		}
+		return result, nil
+	}
+
+	var anthropicResp bedrockAnthropicResponse
+	if err := json.Unmarshal(body, &anthropicResp); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrParseResponse, err)
+	}
+
+	for _, c := range anthropicResp.Content {
+		if c.Type == "text" {
+			result.Content += c.Text
+		}
+	}
+	result.InputTokens = anthropicResp.Usage.InputTokens
+	result.OutputTokens = anthropicResp.Usage.OutputTokens
+	return result, nil
+}
+
+// parseStream 解析AWS事件流格式的流式响应
+func (m *BedrockModel) parseStream(body io.Reader, startTime time.Time) (*LLMResponse, error) {
+	result := &LLMResponse{}
+
+	var fullContent strings.Builder
+	var firstTokenReceived bool
+	var timer tokenTimer
+
+	reader := newEventStreamReader(body)
+	for {
+		msg, err := reader.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("读取流式响应失败: %w", err)
+		}
+
+		// 流中途返回的异常，例如限流，与非流式请求的错误一样按状态码分类和重试
+		if msg.Headers[":message-type"] == "exception" {
+			return nil, m.streamException(msg)
+		}
+		if msg.Headers[":event-type"] != "chunk" {
+			continue
+		}
+
+		// 数据块的内容是base64编码的JSON
+		var event struct {
+			Bytes []byte `json:"bytes"`
+		}
+		if err := json.Unmarshal(msg.Payload, &event); err != nil {
+			m.logger.Infof("解析流响应事件失败: %v, 数据: %s", err, string(msg.Payload))
+			continue
+		}
+
+		var chunk bedrockStreamChunk
+		if err := json.Unmarshal(event.Bytes, &chunk); err != nil {
+			m.logger.Infof("解析流响应块失败: %v, 数据: %s", err, string(event.Bytes))
+			continue
+		}
+
+		text := chunk.Delta.Text
+		if m.schema == bedrockSchemaTitan {
+			text = chunk.OutputText
+		}
+		if text != "" {
+			// 记录首个token接收时间
+			if !firstTokenReceived {
+				firstTokenReceived = true
+				result.TimeToFirstToken = time.Since(startTime)
+			}
+			timer.observe()
+			fullContent.WriteString(text)
+		}
+
+		// 累计token数
+		if chunk.Message.Usage.InputTokens > 0 {
+			result.InputTokens = chunk.Message.Usage.InputTokens
+		}
+		if chunk.Usage.OutputTokens > 0 {
+			result.OutputTokens = chunk.Usage.OutputTokens
+		}
+		if chunk.InputTextTokenCount > 0 {
+			result.InputTokens = chunk.InputTextTokenCount
+		}
+		if chunk.TotalOutputTextTokenCount > 0 {
+			result.OutputTokens = chunk.TotalOutputTextTokenCount
+		}
+		if chunk.InvocationMetrics != nil {
+			result.InputTokens = chunk.InvocationMetrics.InputTokenCount
+			result.OutputTokens = chunk.InvocationMetrics.OutputTokenCount
+		}
+	}
+
+	m.logger.Debugf("Bedrock API流式请求延迟(包含所有流式数据): %s", time.Since(startTime))
+
+	result.Content = fullContent.String()
+	if firstTokenReceived {
+		result.TokensPerSecond = timer.tokensPerSecond(result.OutputTokens)
+		m.logger.Debugf("流式响应速率: %.2f tokens/sec", result.TokensPerSecond)
+	}
+
+	return result, nil
+}
+
+// bedrockExceptionStatuses 流式响应中的异常类型对应的HTTP状态码，与非流式请求返回同类错误时的状态码相同
+var bedrockExceptionStatuses = map[string]int{
+	"validationException":         400,
+	"modelTimeoutException":       408,
+	"modelStreamErrorException":   424,
+	"throttlingException":         429,
+	"modelNotReadyException":      429,
+	"internalServerException":     500,
+	"serviceUnavailableException": 503,
+}
+
+// streamException 把流式响应中的异常消息转换为 APIError，未知的异常类型按500处理。
+// modelStreamErrorException 的负载中带有模型返回的原始状态码时使用原始状态码
+func (m *BedrockModel) streamException(msg *eventStreamMessage) error {
+	exceptionType := msg.Headers[":exception-type"]
+	status, ok := bedrockExceptionStatuses[exceptionType]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	var payload struct {
+		OriginalStatusCode int `json:"originalStatusCode"`
+	}
+	if json.Unmarshal(msg.Payload, &payload) == nil && payload.OriginalStatusCode >= 400 {
+		status = payload.OriginalStatusCode
+	}
+	return &APIError{
+		StatusCode: status,
+		Body:       fmt.Sprintf("%s: %s", exceptionType, msg.Payload),
+		Model:      m.config.Name,
+	}
+}
+
+// invokeURL 返回调用模型的URL，action 为 invoke 或 invoke-with-response-stream
+// 模型ID中可能包含":"，需要按AWS规则编码路径
+func (m *BedrockModel) invokeURL(action string) (string, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(m.config.BaseURL, "/"))
+	if err != nil {
+		return "", fmt.Errorf("解析基础URL失败: %w", err)
+	}
+	basePath := endpoint.EscapedPath()
+	endpoint.Path += "/model/" + m.modelID + "/" + action
+	endpoint.RawPath = basePath + "/model/" + awsURIEncode(m.modelID) + "/" + action
+	return endpoint.String(), nil
+}
+
+// Endpoint 返回非流式调用模型的URL，流式请求的路径以 invoke-with-response-stream 结尾
+func (m *BedrockModel) Endpoint() string {
+	endpoint, err := m.invokeURL("invoke")
+	if err != nil {
+		return m.config.BaseURL
+	}
+	return endpoint
+}
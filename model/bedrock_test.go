@@ -0,0 +1,150 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
+)
+
+func newTestBedrockModel(t *testing.T, modelID string) *BedrockModel {
+	t.Helper()
+	m, err := NewBedrockModel(config.ModelConfig{
+		Name: "m", Type: "bedrock", APIKey: "AKIDEXAMPLE", Secret: "secret",
+		Params: map[string]interface{}{"region": "us-east-1", "model_id": modelID},
+	}, nil, logging.New(logging.LevelQuiet))
+	if err != nil {
+		t.Fatalf("创建Bedrock模型失败: %v", err)
+	}
+	return m
+}
+
+// bedrockChunkStream 把数据块编码为 InvokeModelWithResponseStream 返回的事件流
+func bedrockChunkStream(t *testing.T, chunks ...string) *bytes.Buffer {
+	t.Helper()
+	var stream bytes.Buffer
+	for _, chunk := range chunks {
+		payload, err := json.Marshal(struct {
+			Bytes []byte `json:"bytes"`
+		}{[]byte(chunk)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream.Write(encodeEventStreamMessage(map[string]string{":message-type": "event", ":event-type": "chunk"}, payload))
+	}
+	return &stream
+}
+
+func TestBedrockParseResponse(t *testing.T) {
+	for _, tc := range []struct {
+		modelID      string
+		body         string
+		content      string
+		inputTokens  int
+		outputTokens int
+	}{
+		{
+			modelID:      "anthropic.claude-3-haiku-20240307-v1:0",
+			body:         `{"content":[{"type":"text","text":"hello"},{"type":"text","text":" world"}],"usage":{"input_tokens":12,"output_tokens":3}}`,
+			content:      "hello world",
+			inputTokens:  12,
+			outputTokens: 3,
+		},
+		{
+			modelID:      "amazon.titan-text-express-v1",
+			body:         `{"inputTextTokenCount":8,"results":[{"tokenCount":4,"outputText":"hi there"}]}`,
+			content:      "hi there",
+			inputTokens:  8,
+			outputTokens: 4,
+		},
+	} {
+		result, err := newTestBedrockModel(t, tc.modelID).parseResponse([]byte(tc.body))
+		if err != nil {
+			t.Fatalf("%s: 解析响应失败: %v", tc.modelID, err)
+		}
+		if result.Content != tc.content || result.InputTokens != tc.inputTokens || result.OutputTokens != tc.outputTokens {
+			t.Errorf("%s: 解析结果为 %q %d/%d，期望 %q %d/%d", tc.modelID,
+				result.Content, result.InputTokens, result.OutputTokens, tc.content, tc.inputTokens, tc.outputTokens)
+		}
+	}
+}
+
+func TestBedrockParseStream(t *testing.T) {
+	for _, tc := range []struct {
+		modelID      string
+		chunks       []string
+		content      string
+		inputTokens  int
+		outputTokens int
+	}{
+		{
+			modelID: "anthropic.claude-3-haiku-20240307-v1:0",
+			chunks: []string{
+				`{"type":"message_start","message":{"usage":{"input_tokens":12}}}`,
+				`{"type":"content_block_delta","delta":{"text":"hello"}}`,
+				`{"type":"content_block_delta","delta":{"text":" world"}}`,
+				`{"type":"message_delta","usage":{"output_tokens":3}}`,
+			},
+			content:      "hello world",
+			inputTokens:  12,
+			outputTokens: 3,
+		},
+		{
+			modelID: "amazon.titan-text-express-v1",
+			chunks: []string{
+				`{"outputText":"hi","index":0,"inputTextTokenCount":8}`,
+				`{"outputText":" there","index":0,"totalOutputTextTokenCount":4,
+				  "amazon-bedrock-invocationMetrics":{"inputTokenCount":8,"outputTokenCount":4}}`,
+			},
+			content:      "hi there",
+			inputTokens:  8,
+			outputTokens: 4,
+		},
+	} {
+		result, err := newTestBedrockModel(t, tc.modelID).parseStream(bedrockChunkStream(t, tc.chunks...), time.Now())
+		if err != nil {
+			t.Fatalf("%s: 解析流式响应失败: %v", tc.modelID, err)
+		}
+		if result.Content != tc.content || result.InputTokens != tc.inputTokens || result.OutputTokens != tc.outputTokens {
+			t.Errorf("%s: 解析结果为 %q %d/%d，期望 %q %d/%d", tc.modelID,
+				result.Content, result.InputTokens, result.OutputTokens, tc.content, tc.inputTokens, tc.outputTokens)
+		}
+		if result.TimeToFirstToken <= 0 {
+			t.Errorf("%s: 没有记录首Token时间", tc.modelID)
+		}
+	}
+}
+
+// 流中途返回的异常按异常类型转换为带状态码的 APIError，限流可以被重试
+func TestBedrockStreamException(t *testing.T) {
+	for _, tc := range []struct {
+		exceptionType string
+		payload       string
+		status        int
+	}{
+		{"throttlingException", `{"message":"Too many requests, please wait before trying again."}`, 429},
+		{"serviceUnavailableException", `{"message":"Service unavailable"}`, 503},
+		{"internalServerException", `{"message":"Internal error"}`, 500},
+		{"modelStreamErrorException", `{"message":"model error","originalStatusCode":529,"originalMessage":"overloaded"}`, 529},
+		{"unknownException", `{"message":"?"}`, 500},
+	} {
+		stream := bedrockChunkStream(t, `{"type":"content_block_delta","delta":{"text":"hello"}}`)
+		stream.Write(encodeEventStreamMessage(map[string]string{
+			":message-type":   "exception",
+			":exception-type": tc.exceptionType,
+		}, []byte(tc.payload)))
+
+		_, err := newTestBedrockModel(t, "anthropic.claude-3-haiku-20240307-v1:0").parseStream(stream, time.Now())
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("%s: 应当返回 APIError，实际为 %v", tc.exceptionType, err)
+		}
+		if apiErr.StatusCode != tc.status || apiErr.Model != "m" {
+			t.Errorf("%s: 状态码为 %d，模型为 %s，期望 %d", tc.exceptionType, apiErr.StatusCode, apiErr.Model, tc.status)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
+)
+
+// Cohere API的默认地址
+const defaultCohereBaseURL = "https://api.cohere.com"
+
+// CohereModel Cohere模型实现，使用 /v2/chat 接口
+type CohereModel struct {
+	BaseModel
+	defaultClient *http.Client
+	proxyClients  map[string]*http.Client // 代理名称到对应HTTP客户端的映射
+}
+
+// CohereRequest 定义Cohere /v2/chat 请求结构
+type CohereRequest struct {
+	Model       string          `json:"model"`
+	Messages    []CohereMessage `json:"messages"`
+	Stream      bool            `json:"stream"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+}
+
+// CohereMessage 定义Cohere消息结构
+type CohereMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// CohereUsage 定义Cohere的token用量
+type CohereUsage struct {
+	Tokens struct {
+		InputTokens  float64 `json:"input_tokens"`
+		OutputTokens float64 `json:"output_tokens"`
+	} `json:"tokens"`
+}
+
+// CohereResponse 定义Cohere非流式响应结构
+type CohereResponse struct {
+	ID           string `json:"id"`
+	FinishReason string `json:"finish_reason"`
+	Message      struct {
+		Role    string `json:"role"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"message"`
+	Usage CohereUsage `json:"usage"`
+}
+
+// CohereStreamEvent 定义Cohere流式响应中的单个事件
+// content-delta 事件携带增量文本，message-end 事件携带结束原因和token用量
+type CohereStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Message struct {
+			Content struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"message"`
+		FinishReason string       `json:"finish_reason"`
+		Usage        *CohereUsage `json:"usage"`
+	} `json:"delta"`
+}
+
+// NewCohereModel 创建新的Cohere模型
+func NewCohereModel(cfg config.ModelConfig, proxies []config.ProxyConfig, logger *logging.Logger) (*CohereModel, error) {
+	modelName, err := cfg.StringParam("model", "")
+	if err != nil {
+		return nil, err
+	}
+	if modelName == "" {
+		return nil, fmt.Errorf("Cohere模型必须在 params.model 中指定模型名称")
+	}
+
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultCohereBaseURL
+	}
+
+	// 根据TLS配置加载CA证书和客户端证书，默认客户端和代理客户端共用
+	tlsConfig, err := newTLSConfig(cfg.HTTP.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建默认客户端，不设置客户端超时，由调用方通过context控制请求超时
+	defaultClient := &http.Client{
+		Transport: newHTTPTransport(cfg.HTTP, tlsConfig, nil),
+	}
+
+	// 创建代理客户端映射
+	proxyClients := make(map[string]*http.Client)
+
+	// 为每个代理创建对应的HTTP客户端
+	for _, proxy := range proxies {
+		// 解析代理URL，包含认证信息时由Transport设置 Proxy-Authorization
+		parsedURL, err := proxy.ProxyURL()
+		if err != nil {
+			logger.Infof("%v，跳过该代理", err)
+			continue
+		}
+
+		// 创建带有代理的Transport
+		transport := newHTTPTransport(cfg.HTTP, proxyTLSConfig(tlsConfig, proxy), parsedURL)
+
+		// 创建客户端并存储
+		proxyClients[proxy.Name] = &http.Client{
+			Transport: transport,
+		}
+	}
+
+	return &CohereModel{
+		BaseModel: BaseModel{
+			config: cfg,
+			logger: logger,
+		},
+		defaultClient: defaultClient,
+		proxyClients:  proxyClients,
+	}, nil
+}
+
+// GenerateResponse 生成响应，调用Cohere的 /v2/chat 接口
+func (m *CohereModel) GenerateResponse(ctx context.Context, systemMessage, userMessage string, stream bool) (*LLMResponse, error) {
+	// 选择合适的HTTP客户端
+	client := m.defaultClient
+
+	// 如果模型配置了代理，并且代理客户端存在，则使用代理客户端；配置了代理池时按请求轮流选择
+	proxyName := m.proxyName(ctx)
+	if proxyName != "" {
+		if proxyClient, ok := m.proxyClients[proxyName]; ok {
+			client = proxyClient
+			m.logger.Debugf("使用代理: %s", proxyName)
+		} else {
+			m.logger.Infof("未找到配置的代理: %s，使用默认客户端", proxyName)
+		}
+	}
+
+	modelName, err := m.config.StringParam("model", "")
+	if err != nil {
+		return nil, err
+	}
+	maxTokens, err := m.config.IntParam("max_tokens", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// 构建消息，系统消息为空时不发送；场景配置了多轮对话时按原角色发送每条消息
+	conversation := promptMessages(ctx, systemMessage, userMessage)
+	messages := make([]CohereMessage, 0, len(conversation))
+	for _, message := range conversation {
+		messages = append(messages, CohereMessage{Role: message.Role, Content: message.Content})
+	}
+
+	reqBody := CohereRequest{
+		Model:     modelName,
+		Messages:  messages,
+		Stream:    stream,
+		MaxTokens: maxTokens,
+	}
+	if m.config.HasParam("temperature") {
+		temperature, err := m.config.FloatParam("temperature", 0)
+		if err != nil {
+			return nil, err
+		}
+		reqBody.Temperature = &temperature
+	}
+
+	// 序列化请求体
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求体失败: %w", err)
+	}
+
+	// 创建HTTP请求
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
+		m.Endpoint(),
+		bytes.NewBuffer(jsonData),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+
+	// 设置请求头
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.apiKey(ctx)))
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	// 发送请求
+	req, trace := traceRequest(req)
+	startTime := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 网关返回压缩的响应时先解压，错误响应同样需要解压后才能读取错误信息
+	if err := decodeResponseBody(resp); err != nil {
+		return nil, err
+	}
+
+	// 检查状态码
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			Model:      m.config.Name,
+		}
+	}
+
+	result := &LLMResponse{}
+	trace.apply(result)
+
+	// 非流式响应处理
+	if !stream {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("读取响应体失败: %w", err)
+		}
+		result.ResponseBytes = int64(len(body))
+
+		m.logger.Debugf("Cohere API请求延迟(非流式): %s", time.Since(startTime))
+
+		var cohereResp CohereResponse
+		if err := json.Unmarshal(body, &cohereResp); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrParseResponse, err)
+		}
+
+		var content strings.Builder
+		for _, part := range cohereResp.Message.Content {
+			if part.Type == "text" {
+				content.WriteString(part.Text)
+			}
+		}
+		result.Content = content.String()
+		result.InputTokens = int(cohereResp.Usage.Tokens.InputTokens)
+		result.OutputTokens = int(cohereResp.Usage.Tokens.OutputTokens)
+		result.FinishReason = cohereFinishReason(cohereResp.FinishReason)
+		return result, nil
+	}
+
+	// 流式响应处理，Cohere使用SSE格式，每个事件的 data 行是一个JSON对象
+	var fullContent strings.Builder
+	var firstTokenReceived bool
+	var timer tokenTimer
+	var usageReported bool
+
+	counter := &countingReader{r: resp.Body}
+	reader := newSSEReader(counter)
+	for {
+		sse, err := reader.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("读取流式响应失败: %w", err)
+		}
+
+		data := strings.TrimSpace(sse.Data)
+		var event CohereStreamEvent
+		if jsonErr := json.Unmarshal([]byte(data), &event); jsonErr != nil {
+			m.logger.Infof("解析流响应块失败: %v, 数据: %s", jsonErr, data)
+		} else {
+			switch event.Type {
+			case "content-delta":
+				text := event.Delta.Message.Content.Text
+				if text != "" {
+					// 记录首个token接收时间
+					if !firstTokenReceived {
+						firstTokenReceived = true
+						result.TimeToFirstToken = time.Since(startTime)
+					}
+					timer.observe()
+					fullContent.WriteString(text)
+				}
+			case "message-end":
+				result.FinishReason = cohereFinishReason(event.Delta.FinishReason)
+				if event.Delta.Usage != nil {
+					usageReported = true
+					result.InputTokens = int(event.Delta.Usage.Tokens.InputTokens)
+					result.OutputTokens = int(event.Delta.Usage.Tokens.OutputTokens)
+				}
+			}
+		}
+	}
+
+	m.logger.Debugf("Cohere API流式请求延迟(包含所有流式数据): %s", time.Since(startTime))
+
+	result.Content = fullContent.String()
+	result.ResponseBytes = counter.n
+
+	// 服务端没有返回usage时，根据提示词和累积的内容估算token数
+	if !usageReported {
+		result.InputTokens = EstimateTokens(messagesText(conversation))
+		result.OutputTokens = EstimateTokens(result.Content)
+		result.TokensEstimated = true
+	}
+
+	if firstTokenReceived {
+		result.TokensPerSecond = timer.tokensPerSecond(result.OutputTokens)
+		m.logger.Debugf("流式响应速率: %.2f tokens/sec", result.TokensPerSecond)
+	}
+
+	return result, nil
+}
+
+// cohereFinishReason 将Cohere的结束原因转换为通用格式
+func cohereFinishReason(reason string) string {
+	switch reason {
+	case "":
+		return ""
+	case "COMPLETE", "STOP_SEQUENCE":
+		return FinishReasonStop
+	case "MAX_TOKENS":
+		return FinishReasonLength
+	default:
+		return strings.ToLower(reason)
+	}
+}
+
+// Endpoint 返回Cohere对话接口的URL
+func (m *CohereModel) Endpoint() string {
+	return m.chatURL("/v2/chat")
+}
@@ -0,0 +1,161 @@
+package model
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
+)
+
+func compress(t *testing.T, encoding, text string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+	if _, err := io.WriteString(w, text); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// 按 Content-Encoding 解压响应体，deflate 同时支持zlib格式和不带头的原始数据
+func TestDecodeResponseBody(t *testing.T) {
+	const text = `{"ok":true}`
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		want     string
+	}{
+		{"gzip", "gzip", compress(t, "gzip", text), text},
+		{"x-gzip", "X-Gzip", compress(t, "gzip", text), text},
+		{"zlib deflate", "deflate", compress(t, "zlib", text), text},
+		{"raw deflate", "deflate", compress(t, "flate", text), text},
+		{"empty gzip", "gzip", nil, ""},
+		{"identity", "", []byte(text), text},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				Header:        http.Header{},
+				Body:          io.NopCloser(bytes.NewReader(tt.body)),
+				ContentLength: int64(len(tt.body)),
+			}
+			if tt.encoding != "" {
+				resp.Header.Set("Content-Encoding", tt.encoding)
+			}
+			if err := decodeResponseBody(resp); err != nil {
+				t.Fatalf("解压失败: %v", err)
+			}
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("读取解压后的响应体失败: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("解压结果为 %q，期望 %q", got, tt.want)
+			}
+			if tt.encoding != "" && (resp.Header.Get("Content-Encoding") != "" || resp.ContentLength != -1) {
+				t.Error("解压后应当移除 Content-Encoding 并将 ContentLength 置为未知")
+			}
+		})
+	}
+}
+
+// encodedServer 返回按 encoding（gzip 或 deflate）压缩响应的测试服务
+// http.Transport 只会自动解压它自己请求的gzip，deflate 只有客户端自己声明并解压时才能正确读取
+func encodedServer(t *testing.T, encoding string, status int, body string) *httptest.Server {
+	t.Helper()
+	format := map[string]string{"gzip": "gzip", "deflate": "zlib"}[encoding]
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+			t.Errorf("请求没有声明支持%s: %q", encoding, r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", encoding)
+		w.WriteHeader(status)
+		w.Write(compress(t, format, body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// 各模型客户端都应当解压gzip和deflate压缩的成功响应和错误响应
+func TestCompressedResponses(t *testing.T) {
+	logger := logging.New(logging.LevelQuiet)
+	clients := []struct {
+		name    string
+		success string
+		newLLM  func(baseURL string) (LLMModel, error)
+	}{
+		{
+			name:    "openai",
+			success: `{"choices":[{"message":{"content":"hello"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1}}`,
+			newLLM: func(baseURL string) (LLMModel, error) {
+				return NewOpenAIModel(config.ModelConfig{Name: "m", Type: "openai", BaseURL: baseURL, APIKey: "k", Params: map[string]interface{}{"model": "gpt-4o"}}, nil, logger)
+			},
+		},
+		{
+			name:    "bedrock",
+			success: `{"content":[{"type":"text","text":"hello"}],"usage":{"input_tokens":3,"output_tokens":1}}`,
+			newLLM: func(baseURL string) (LLMModel, error) {
+				return NewBedrockModel(config.ModelConfig{
+					Name: "m", Type: "bedrock", BaseURL: baseURL, APIKey: "AKIDEXAMPLE", Secret: "secret",
+					Params: map[string]interface{}{"region": "us-east-1", "model_id": "anthropic.claude-3-haiku"},
+				}, nil, logger)
+			},
+		},
+	}
+
+	for _, client := range clients {
+		for _, encoding := range []string{"gzip", "deflate"} {
+			t.Run(client.name+"/"+encoding, func(t *testing.T) {
+				testCompressedResponse(t, encoding, client.success, client.newLLM)
+			})
+		}
+	}
+}
+
+func testCompressedResponse(t *testing.T, encoding, success string, newLLM func(baseURL string) (LLMModel, error)) {
+	mdl, err := newLLM(encodedServer(t, encoding, http.StatusOK, success).URL)
+	if err != nil {
+		t.Fatalf("创建模型失败: %v", err)
+	}
+	resp, err := mdl.GenerateResponse(context.Background(), "", "hi", false)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if resp.Content != "hello" || resp.InputTokens != 3 || resp.OutputTokens != 1 {
+		t.Errorf("响应为 %q，输入 %d、输出 %d 个token，期望 hello、3、1", resp.Content, resp.InputTokens, resp.OutputTokens)
+	}
+
+	const errorBody = `{"message":"bad request"}`
+	mdl, err = newLLM(encodedServer(t, encoding, http.StatusBadRequest, errorBody).URL)
+	if err != nil {
+		t.Fatalf("创建模型失败: %v", err)
+	}
+	_, err = mdl.GenerateResponse(context.Background(), "", "hi", false)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Body != errorBody {
+		t.Errorf("错误响应应当解压后返回，实际为 %v", err)
+	}
+}
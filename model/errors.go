@@ -0,0 +1,41 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrParseResponse 模型返回了成功的状态码，但响应体无法解析，各模型客户端返回的解析错误都包装了它
+var ErrParseResponse = errors.New("解析响应失败")
+
+// APIError 表示模型API返回的非200响应
+type APIError struct {
+	// HTTP状态码
+	StatusCode int
+	// 响应体内容
+	Body string
+	// 模型名称
+	Model string
+	// 响应头中的限流信息，服务端未返回时为nil
+	RateLimit *RateLimit
+}
+
+// Error 实现error接口
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API请求失败: 模型=%s, 状态码=%d, 响应=%s", e.Model, e.StatusCode, e.Body)
+}
+
+// RetryAfter 返回服务端要求的重试等待时间，未返回时为0
+func (e *APIError) RetryAfter() time.Duration {
+	if e.RateLimit == nil {
+		return 0
+	}
+	return e.RateLimit.RetryAfter
+}
+
+// Retryable 判断该错误的状态码是否在配置的可重试状态码（test.retryable_statuses）中
+func (e *APIError) Retryable(statuses []int) bool {
+	return slices.Contains(statuses, e.StatusCode)
+}
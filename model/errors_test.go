@@ -0,0 +1,77 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
+)
+
+// 是否重试只由配置的可重试状态码决定
+func TestAPIErrorRetryable(t *testing.T) {
+	statuses := []int{429, 503}
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{429, true},
+		{503, true},
+		{500, false},
+		{401, false},
+	}
+	for _, tt := range tests {
+		err := &APIError{StatusCode: tt.status}
+		if got := err.Retryable(statuses); got != tt.want {
+			t.Errorf("状态码 %d 的 Retryable 为 %v，期望 %v", tt.status, got, tt.want)
+		}
+	}
+	if (&APIError{StatusCode: 503}).Retryable(nil) {
+		t.Error("没有配置可重试状态码时不应重试")
+	}
+}
+
+// 各模型客户端在成功响应无法解析时返回的错误都应当包装 ErrParseResponse
+func TestParseResponseErrorWrapsSentinel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("not json"))
+	}))
+	defer server.Close()
+
+	logger := logging.New(logging.LevelQuiet)
+	params := map[string]interface{}{"model": "test-model"}
+	clients := map[string]func() (LLMModel, error){
+		"openai": func() (LLMModel, error) {
+			return NewOpenAIModel(config.ModelConfig{Name: "m", Type: "openai", BaseURL: server.URL, APIKey: "k", Params: params}, nil, logger)
+		},
+		"anthropic": func() (LLMModel, error) {
+			return NewAnthropicModel(config.ModelConfig{Name: "m", Type: "anthropic", BaseURL: server.URL, APIKey: "k", Params: params}, nil, logger)
+		},
+		"cohere": func() (LLMModel, error) {
+			return NewCohereModel(config.ModelConfig{Name: "m", Type: "cohere", BaseURL: server.URL, APIKey: "k", Params: params}, nil, logger)
+		},
+		"ollama": func() (LLMModel, error) {
+			return NewOllamaModel(config.ModelConfig{Name: "m", Type: "ollama", BaseURL: server.URL, Params: params}, nil, logger)
+		},
+		"bedrock": func() (LLMModel, error) {
+			return NewBedrockModel(config.ModelConfig{
+				Name: "m", Type: "bedrock", BaseURL: server.URL, APIKey: "AKIDEXAMPLE", Secret: "secret",
+				Params: map[string]interface{}{"region": "us-east-1", "model_id": "anthropic.claude-3-haiku"},
+			}, nil, logger)
+		},
+	}
+	for name, newLLM := range clients {
+		llm, err := newLLM()
+		if err != nil {
+			t.Fatalf("%s: 创建模型失败: %v", name, err)
+		}
+		_, err = llm.GenerateResponse(context.Background(), "", "hi", false)
+		if !errors.Is(err, ErrParseResponse) {
+			t.Errorf("%s: 无法解析的响应返回 %v，应当包装 ErrParseResponse", name, err)
+		}
+	}
+}
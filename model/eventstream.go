@@ -0,0 +1,114 @@
+package model
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// eventStreamMessage AWS事件流（application/vnd.amazon.eventstream）中的一条消息
+type eventStreamMessage struct {
+	Headers map[string]string // 只保留字符串类型的头
+	Payload []byte
+}
+
+// eventStreamReader 解析AWS二进制事件流
+// 每条消息的格式：总长度(4) + 头长度(4) + 前导CRC(4) + 头 + 负载 + 消息CRC(4)
+type eventStreamReader struct {
+	r *bufio.Reader
+}
+
+func newEventStreamReader(r io.Reader) *eventStreamReader {
+	return &eventStreamReader{r: bufio.NewReader(r)}
+}
+
+// Next 读取下一条消息，流结束时返回 io.EOF
+func (r *eventStreamReader) Next() (*eventStreamMessage, error) {
+	prelude := make([]byte, 12)
+	if _, err := io.ReadFull(r.r, prelude); err != nil {
+		return nil, err
+	}
+
+	totalLen := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[0:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, fmt.Errorf("事件流前导CRC校验失败")
+	}
+	if totalLen < 16 || headersLen > totalLen-16 {
+		return nil, fmt.Errorf("事件流消息长度无效: total=%d, headers=%d", totalLen, headersLen)
+	}
+
+	rest := make([]byte, totalLen-12)
+	if _, err := io.ReadFull(r.r, rest); err != nil {
+		return nil, fmt.Errorf("读取事件流消息失败: %w", err)
+	}
+
+	messageCRC := binary.BigEndian.Uint32(rest[len(rest)-4:])
+	crc := crc32.Update(crc32.ChecksumIEEE(prelude), crc32.IEEETable, rest[:len(rest)-4])
+	if crc != messageCRC {
+		return nil, fmt.Errorf("事件流消息CRC校验失败")
+	}
+
+	headers, err := parseEventStreamHeaders(rest[:headersLen])
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventStreamMessage{
+		Headers: headers,
+		Payload: rest[headersLen : len(rest)-4],
+	}, nil
+}
+
+// parseEventStreamHeaders 解析消息头，非字符串类型的值会被跳过
+func parseEventStreamHeaders(data []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(data) > 0 {
+		nameLen := int(data[0])
+		if len(data) < 1+nameLen+1 {
+			return nil, fmt.Errorf("事件流消息头格式无效")
+		}
+		name := string(data[1 : 1+nameLen])
+		valueType := data[1+nameLen]
+		data = data[2+nameLen:]
+
+		var valueLen int
+		switch valueType {
+		case 0, 1: // bool true / false
+			valueLen = 0
+		case 2: // byte
+			valueLen = 1
+		case 3: // short
+			valueLen = 2
+		case 4: // int
+			valueLen = 4
+		case 5, 8: // long, timestamp
+			valueLen = 8
+		case 9: // uuid
+			valueLen = 16
+		case 6, 7: // bytes, string
+			if len(data) < 2 {
+				return nil, fmt.Errorf("事件流消息头格式无效")
+			}
+			strLen := int(binary.BigEndian.Uint16(data[0:2]))
+			if len(data) < 2+strLen {
+				return nil, fmt.Errorf("事件流消息头格式无效")
+			}
+			if valueType == 7 {
+				headers[name] = string(data[2 : 2+strLen])
+			}
+			data = data[2+strLen:]
+			continue
+		default:
+			return nil, fmt.Errorf("未知的事件流消息头类型: %d", valueType)
+		}
+
+		if len(data) < valueLen {
+			return nil, fmt.Errorf("事件流消息头格式无效")
+		}
+		data = data[valueLen:]
+	}
+	return headers, nil
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// encodeEventStreamMessage 按AWS事件流格式编码一条消息，头的值都是字符串类型
+func encodeEventStreamMessage(headers map[string]string, payload []byte) []byte {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var headerBytes bytes.Buffer
+	for _, name := range names {
+		headerBytes.WriteByte(byte(len(name)))
+		headerBytes.WriteString(name)
+		headerBytes.WriteByte(7)
+		binary.Write(&headerBytes, binary.BigEndian, uint16(len(headers[name])))
+		headerBytes.WriteString(headers[name])
+	}
+
+	totalLen := 12 + headerBytes.Len() + len(payload) + 4
+	message := make([]byte, 0, totalLen)
+	message = binary.BigEndian.AppendUint32(message, uint32(totalLen))
+	message = binary.BigEndian.AppendUint32(message, uint32(headerBytes.Len()))
+	message = binary.BigEndian.AppendUint32(message, crc32.ChecksumIEEE(message))
+	message = append(message, headerBytes.Bytes()...)
+	message = append(message, payload...)
+	return binary.BigEndian.AppendUint32(message, crc32.ChecksumIEEE(message))
+}
+
+func TestEventStreamReader(t *testing.T) {
+	headers := map[string]string{":message-type": "event", ":event-type": "chunk"}
+	var stream bytes.Buffer
+	stream.Write(encodeEventStreamMessage(headers, []byte(`{"bytes":"e30="}`)))
+	stream.Write(encodeEventStreamMessage(map[string]string{":message-type": "event"}, nil))
+
+	reader := newEventStreamReader(&stream)
+	msg, err := reader.Next()
+	if err != nil {
+		t.Fatalf("读取第一条消息失败: %v", err)
+	}
+	if msg.Headers[":event-type"] != "chunk" || msg.Headers[":message-type"] != "event" || string(msg.Payload) != `{"bytes":"e30="}` {
+		t.Errorf("第一条消息为 %+v", msg)
+	}
+	if msg, err = reader.Next(); err != nil || len(msg.Payload) != 0 {
+		t.Errorf("第二条消息为 %+v，错误 %v", msg, err)
+	}
+	if _, err := reader.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("流结束时应当返回 io.EOF，实际为 %v", err)
+	}
+}
+
+func TestEventStreamReaderCRC(t *testing.T) {
+	valid := encodeEventStreamMessage(map[string]string{":message-type": "event"}, []byte("payload"))
+	for _, tc := range []struct {
+		name    string
+		offset  int
+		wantErr string
+	}{
+		{"prelude", 9, "前导CRC"},
+		{"message", len(valid) - 6, "消息CRC"},
+	} {
+		corrupted := bytes.Clone(valid)
+		corrupted[tc.offset] ^= 0xff
+		_, err := newEventStreamReader(bytes.NewReader(corrupted)).Next()
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: 错误为 %v，期望包含 %s", tc.name, err, tc.wantErr)
+		}
+	}
+}
@@ -2,13 +2,12 @@ package model
 
 import (
 	"context"
-	"log"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 
 	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
 )
 
 // GeminiModel Gemini模型实现
@@ -19,10 +18,16 @@ type GeminiModel struct {
 }
 
 // NewGeminiModel 创建新的Gemini模型
-func NewGeminiModel(cfg config.ModelConfig, proxies []config.ProxyConfig) (*GeminiModel, error) {
-	// 创建默认客户端
+func NewGeminiModel(cfg config.ModelConfig, proxies []config.ProxyConfig, logger *logging.Logger) (*GeminiModel, error) {
+	// 根据TLS配置加载CA证书和客户端证书，默认客户端和代理客户端共用
+	tlsConfig, err := newTLSConfig(cfg.HTTP.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建默认客户端，不设置客户端超时，由调用方通过context控制请求超时
 	defaultClient := &http.Client{
-		Timeout: 60 * time.Second,
+		Transport: newHTTPTransport(cfg.HTTP, tlsConfig, nil),
 	}
 
 	// 创建代理客户端映射
@@ -30,28 +35,26 @@ func NewGeminiModel(cfg config.ModelConfig, proxies []config.ProxyConfig) (*Gemi
 
 	// 为每个代理创建对应的HTTP客户端
 	for _, proxy := range proxies {
-		// 解析代理URL
-		parsedURL, err := url.Parse(proxy.URL)
+		// 解析代理URL，包含认证信息时由Transport设置 Proxy-Authorization
+		parsedURL, err := proxy.ProxyURL()
 		if err != nil {
-			log.Printf("解析代理URL失败 (%s): %v", proxy.Name, err)
+			logger.Infof("%v，跳过该代理", err)
 			continue
 		}
 
 		// 创建带有代理的Transport
-		transport := &http.Transport{
-			Proxy: http.ProxyURL(parsedURL),
-		}
+		transport := newHTTPTransport(cfg.HTTP, proxyTLSConfig(tlsConfig, proxy), parsedURL)
 
 		// 创建客户端并存储
 		proxyClients[proxy.Name] = &http.Client{
 			Transport: transport,
-			Timeout:   60 * time.Second,
 		}
 	}
 
 	return &GeminiModel{
 		BaseModel: BaseModel{
 			config: cfg,
+			logger: logger,
 		},
 		defaultClient: defaultClient,
 		proxyClients:  proxyClients,
@@ -63,13 +66,14 @@ func (m *GeminiModel) GenerateResponse(ctx context.Context, systemMessage, userM
 	// 选择合适的HTTP客户端
 	var client *http.Client
 
-	// 如果模型配置了代理，并且代理客户端存在，则使用代理客户端
-	if m.config.ProxyName != "" {
-		if proxyClient, ok := m.proxyClients[m.config.ProxyName]; ok {
+	// 如果模型配置了代理，并且代理客户端存在，则使用代理客户端；配置了代理池时按请求轮流选择
+	proxyName := m.proxyName(ctx)
+	if proxyName != "" {
+		if proxyClient, ok := m.proxyClients[proxyName]; ok {
 			client = proxyClient
-			log.Printf("使用代理: %s", m.config.ProxyName)
+			m.logger.Debugf("使用代理: %s", proxyName)
 		} else {
-			log.Printf("未找到配置的代理: %s，使用默认客户端", m.config.ProxyName)
+			m.logger.Infof("未找到配置的代理: %s，使用默认客户端", proxyName)
 			client = m.defaultClient
 		}
 	} else {
@@ -83,7 +87,7 @@ func (m *GeminiModel) GenerateResponse(ctx context.Context, systemMessage, userM
 	// 作为示例，我们只是模拟一个延迟并返回一个固定的响应
 
 	// 计算输入token
-	inputTokens, err := m.CountTokens(systemMessage + userMessage)
+	inputTokens, err := m.CountTokens(messagesText(promptMessages(ctx, systemMessage, userMessage)))
 	if err != nil {
 		return nil, err
 	}
@@ -103,9 +107,10 @@ func (m *GeminiModel) GenerateResponse(ctx context.Context, systemMessage, userM
 		}
 
 		return &LLMResponse{
-			Content:      response,
-			InputTokens:  inputTokens,
-			OutputTokens: outputTokens,
+			Content:       response,
+			InputTokens:   inputTokens,
+			OutputTokens:  outputTokens,
+			ResponseBytes: int64(len(response)),
 		}, nil
 	}
 }
@@ -0,0 +1,196 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
+)
+
+// mockImageTokens 模拟模型中每张图片计入的输入Token数，与OpenAI低精度图片的Token数相同
+const mockImageTokens = 85
+
+// MockModel 模拟模型，不发送任何网络请求，按 params 中配置的延迟、Token数和错误率生成响应
+// 用于在CI中测试引擎和报告，或者在没有API密钥时熟悉工具的使用
+type MockModel struct {
+	BaseModel
+	latency       time.Duration // 非流式为整个请求的延迟，流式为首Token时间
+	jitter        time.Duration // 延迟在 [-jitter, jitter] 范围内随机波动
+	tokenInterval time.Duration // 流式响应中相邻两个Token的间隔
+	outputTokens  int
+	maxTokens     int // 大于0且小于 outputTokens 时只生成 maxTokens 个Token，结束原因为length
+	errorRate     float64
+	errorStatus   int
+
+	// 由模型配置的 RandomSeed 初始化，相同种子的延迟波动和错误序列相同，多个协程并发请求时需要持有 rngMutex
+	rngMutex sync.Mutex
+	rng      *rand.Rand
+}
+
+// NewMockModel 创建模拟模型，支持的参数：
+//   - latency_ms: 非流式请求的延迟，流式请求的首Token时间，默认100
+//   - latency_jitter_ms: 延迟的随机波动范围，默认0
+//   - token_interval_ms: 流式响应中每个Token的间隔，默认10
+//   - output_tokens: 每个响应的输出Token数，默认50
+//   - max_tokens: 与其他模型相同，小于 output_tokens 时响应被截断
+//   - error_rate: 请求失败的概率，取值范围0-1，默认0
+//   - error_status: 失败请求返回的HTTP状态码，默认500
+func NewMockModel(cfg config.ModelConfig, logger *logging.Logger) (*MockModel, error) {
+	latencyMs, err := cfg.FloatParam("latency_ms", 100)
+	if err != nil {
+		return nil, err
+	}
+	jitterMs, err := cfg.FloatParam("latency_jitter_ms", 0)
+	if err != nil {
+		return nil, err
+	}
+	intervalMs, err := cfg.FloatParam("token_interval_ms", 10)
+	if err != nil {
+		return nil, err
+	}
+	outputTokens, err := cfg.IntParam("output_tokens", 50)
+	if err != nil {
+		return nil, err
+	}
+	maxTokens, err := cfg.IntParam("max_tokens", 0)
+	if err != nil {
+		return nil, err
+	}
+	errorRate, err := cfg.FloatParam("error_rate", 0)
+	if err != nil {
+		return nil, err
+	}
+	errorStatus, err := cfg.IntParam("error_status", 500)
+	if err != nil {
+		return nil, err
+	}
+
+	if latencyMs < 0 || jitterMs < 0 || intervalMs < 0 {
+		return nil, fmt.Errorf("模型 %s 的 latency_ms、latency_jitter_ms 和 token_interval_ms 不能为负数", cfg.Name)
+	}
+	if outputTokens < 1 {
+		return nil, fmt.Errorf("模型 %s 的 output_tokens 必须大于0: %d", cfg.Name, outputTokens)
+	}
+	if errorRate < 0 || errorRate > 1 {
+		return nil, fmt.Errorf("模型 %s 的 error_rate 必须在0到1之间: %g", cfg.Name, errorRate)
+	}
+	if errorStatus < 400 || errorStatus > 599 {
+		return nil, fmt.Errorf("模型 %s 的 error_status 必须是4xx或5xx状态码: %d", cfg.Name, errorStatus)
+	}
+
+	// 不通过测试引擎创建时没有种子，使用当前时间
+	seed := cfg.RandomSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &MockModel{
+		BaseModel:     NewBaseModel(cfg, logger),
+		latency:       time.Duration(latencyMs * float64(time.Millisecond)),
+		jitter:        time.Duration(jitterMs * float64(time.Millisecond)),
+		tokenInterval: time.Duration(intervalMs * float64(time.Millisecond)),
+		outputTokens:  outputTokens,
+		maxTokens:     maxTokens,
+		errorRate:     errorRate,
+		errorStatus:   errorStatus,
+		rng:           rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+// GenerateResponse 等待配置的延迟后返回模拟的响应，流式请求在首Token之后按间隔逐个生成Token
+// ctx 被取消或超时时立即返回 ctx 的错误，与真实请求一样计为超时或取消
+func (m *MockModel) GenerateResponse(ctx context.Context, systemMessage, userMessage string, stream bool) (*LLMResponse, error) {
+	startTime := time.Now()
+
+	latency, fail := m.sample()
+	if err := sleepContext(ctx, latency); err != nil {
+		return nil, err
+	}
+
+	if fail {
+		return nil, &APIError{
+			StatusCode: m.errorStatus,
+			Body:       `{"error": {"message": "mock error"}}`,
+			Model:      m.config.Name,
+		}
+	}
+
+	outputTokens := m.outputTokens
+	finishReason := FinishReasonStop
+	if m.maxTokens > 0 && m.maxTokens < outputTokens {
+		outputTokens = m.maxTokens
+		finishReason = FinishReasonLength
+	}
+
+	result := &LLMResponse{
+		InputTokens:  EstimateTokens(messagesText(promptMessages(ctx, systemMessage, userMessage))) + len(promptImages(ctx))*mockImageTokens,
+		OutputTokens: outputTokens,
+		FinishReason: finishReason,
+	}
+
+	var content strings.Builder
+	content.WriteString("mock")
+	if stream {
+		// 首Token在延迟结束时返回，之后每隔 tokenInterval 生成一个Token
+		result.TimeToFirstToken = time.Since(startTime)
+		var timer tokenTimer
+		timer.observe()
+		for i := 1; i < outputTokens; i++ {
+			if err := sleepContext(ctx, m.tokenInterval); err != nil {
+				return nil, err
+			}
+			content.WriteString(" mock")
+			timer.observe()
+		}
+		result.TokensPerSecond = timer.tokensPerSecond(outputTokens)
+	} else {
+		content.WriteString(strings.Repeat(" mock", outputTokens-1))
+	}
+
+	result.Content = content.String()
+	result.ResponseBytes = int64(content.Len())
+	m.logger.Debugf("模拟模型请求延迟: %s", time.Since(startTime))
+	return result, nil
+}
+
+// sample 为一次请求抽取延迟和是否失败，每次请求按固定顺序消耗随机数
+func (m *MockModel) sample() (time.Duration, bool) {
+	m.rngMutex.Lock()
+	defer m.rngMutex.Unlock()
+	latency := m.latency
+	if m.jitter > 0 {
+		latency += time.Duration((m.rng.Float64()*2 - 1) * float64(m.jitter))
+	}
+	fail := m.errorRate > 0 && m.rng.Float64() < m.errorRate
+	return latency, fail
+}
+
+// SupportsImages 模拟模型接受图片输入，每张图片按固定Token数计入输入Token
+func (m *MockModel) SupportsImages() bool {
+	return true
+}
+
+// CountTokens 计算文本的token数量
+func (m *MockModel) CountTokens(text string) (int, error) {
+	return EstimateTokens(text), nil
+}
+
+// sleepContext 等待指定时间，ctx 先结束时返回 ctx 的错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
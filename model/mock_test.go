@@ -0,0 +1,56 @@
+package model
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
+)
+
+// 相同种子的模拟模型产生相同的延迟波动和错误序列，不同种子的序列不同
+func TestMockModelSeeded(t *testing.T) {
+	newModel := func(seed int64) *MockModel {
+		m, err := NewMockModel(config.ModelConfig{
+			Name:       "mock",
+			Type:       "mock",
+			RandomSeed: seed,
+			Params: map[string]interface{}{
+				"latency_ms":        0,
+				"latency_jitter_ms": 0.001,
+				"error_rate":        0.5,
+			},
+		}, logging.New(logging.LevelQuiet))
+		if err != nil {
+			t.Fatalf("创建模拟模型失败: %v", err)
+		}
+		return m
+	}
+	failures := func(m *MockModel) []bool {
+		var got []bool
+		for i := 0; i < 64; i++ {
+			_, err := m.GenerateResponse(context.Background(), "", "hi", false)
+			got = append(got, err != nil)
+		}
+		return got
+	}
+	samples := func(m *MockModel) []int64 {
+		var got []int64
+		for i := 0; i < 16; i++ {
+			latency, _ := m.sample()
+			got = append(got, int64(latency))
+		}
+		return got
+	}
+
+	if a, b := failures(newModel(42)), failures(newModel(42)); !slices.Equal(a, b) {
+		t.Errorf("相同种子的错误序列不同:\n%v\n%v", a, b)
+	}
+	if a, b := samples(newModel(42)), samples(newModel(42)); !slices.Equal(a, b) {
+		t.Errorf("相同种子的延迟序列不同:\n%v\n%v", a, b)
+	}
+	if a, b := failures(newModel(42)), failures(newModel(43)); slices.Equal(a, b) {
+		t.Errorf("不同种子的错误序列相同: %v", a)
+	}
+}
@@ -1,11 +1,27 @@
 package model
 
 import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
 )
 
 // contextKey 用于上下文传值
@@ -14,8 +30,47 @@ type contextKey string
 // 上下文键定义
 const (
 	ProxyURLContextKey contextKey = "proxy_url"
+	// 工具定义（json.RawMessage），支持工具调用的模型会随请求发送
+	ToolsContextKey contextKey = "tools"
+	// 多轮对话消息（[]config.MessageConfig），设置后代替系统消息和用户消息发送
+	MessagesContextKey contextKey = "messages"
+	// 本次请求使用的API密钥下标（int），模型配置了 api_keys 时有效，未设置时由模型轮流选择
+	APIKeyIndexContextKey contextKey = "api_key_index"
+	// 本次请求使用的代理在代理池中的下标（int），模型配置了 proxy_names 时有效，未设置时由模型轮流选择
+	ProxyIndexContextKey contextKey = "proxy_index"
+	// 随最后一条用户消息发送的图片（[]config.ImageConfig），只有支持图片输入的模型会使用
+	ImagesContextKey contextKey = "images"
 )
 
+// APIKeyRotator 配置了多个API密钥的模型，测试引擎按请求轮流分配密钥并分别统计每个密钥的成功和失败请求数
+type APIKeyRotator interface {
+	// 返回配置的API密钥个数
+	APIKeyCount() int
+	// 返回第i个密钥在报告中显示的名称，不包含完整的密钥
+	APIKeyLabel(i int) string
+}
+
+// ProxyRotator 配置了代理池的模型，测试引擎按请求轮流分配代理并分别统计每个代理的请求数
+type ProxyRotator interface {
+	// 返回代理池中的代理名称，未配置代理池时为nil
+	ProxyNames() []string
+}
+
+// EndpointProvider 请求固定接口地址的模型，启动时输出实际请求的URL，便于排查 base_url 配置错误导致的404
+type EndpointProvider interface {
+	Endpoint() string
+}
+
+// ConcurrencyLimiter 限制同时发送的请求数的模型，测试引擎发送给该模型的请求不超过返回的上限，返回0时不限制
+type ConcurrencyLimiter interface {
+	MaxConcurrency() int
+}
+
+// ImageSupporter 支持在用户消息中发送图片的模型，未实现该接口的模型不能测试带图片的场景
+type ImageSupporter interface {
+	SupportsImages() bool
+}
+
 // LLMResponse 定义模型响应结构
 type LLMResponse struct {
 	Content      string
@@ -23,9 +78,40 @@ type LLMResponse struct {
 	OutputTokens int
 	// 流式响应专用指标
 	TimeToFirstToken time.Duration // 首个token的响应时间
-	TokensPerSecond  float64       // 流式响应的token生成速率
+	TokensPerSecond  float64       // 流式响应的token生成速率：首Token之后的输出token数除以第一个到最后一个token的时间，无法计算时为0
+	// Token数是否为估算值（服务端未返回usage时根据内容估算）
+	TokensEstimated bool
+	// 生成结束的原因，例如 stop、length，服务端未返回时为空
+	FinishReason string
+	// 模型返回的工具调用数，返回文本时为0
+	ToolCalls int
+	// 推理模型的推理（思考）Token数，包含在 OutputTokens 中
+	// 服务端未在usage中返回时根据推理内容估算，此时 TokensEstimated 为true
+	ReasoningTokens int
+	// 响应头中的限流信息，服务端未返回时为nil
+	RateLimit *RateLimit
+	// 响应体（压缩的响应为解压后）的字节数，流式响应为累计接收的字节数，不依赖各服务商的Token统计
+	ResponseBytes int64
+	// 提示词缓存写入和读取的输入Token数，目前只有Anthropic返回，不包含在 InputTokens 中
+	CacheCreationInputTokens int
+	CacheReadInputTokens     int
+	// 通过 httptrace 记录的DNS解析、TCP连接、TLS握手耗时和从发送请求到收到响应第一个字节的时间
+	// 复用已有连接时前三项为0，模拟模型全部为0
+	DNSTime     time.Duration
+	ConnectTime time.Duration
+	TLSTime     time.Duration
+	TTFB        time.Duration
+	// 请求是否复用了连接池中已有的连接
+	ConnReused bool
 }
 
+// 常见的生成结束原因
+const (
+	FinishReasonStop      = "stop"       // 正常结束
+	FinishReasonLength    = "length"     // 达到最大token数被截断
+	FinishReasonToolCalls = "tool_calls" // 模型返回了工具调用
+)
+
 // LLMModel 定义大语言模型接口
 type LLMModel interface {
 	// 获取模型名称
@@ -36,14 +122,34 @@ type LLMModel interface {
 	GetConcurrencyLevels() []int
 	// 获取模型特定的流式输出设置
 	GetStreamSetting() *bool
+	// 获取模型特定的请求超时时间，未设置时为0
+	GetRequestTimeout() time.Duration
 	// 获取模型使用的代理名称
 	GetProxyName() string
+	// 获取每1000个输入、输出Token的价格
+	GetPricing() (inputPer1K, outputPer1K float64)
+	// 获取请求使用的response_format类型，例如 json_object，未使用时为空
+	GetResponseFormat() string
 }
 
 // 初始化所有配置的模型
-func InitializeModels(modelConfigs []config.ModelConfig, proxies []config.ProxyConfig) ([]LLMModel, error) {
+// logger 控制模型客户端的日志输出，每个请求的调试日志只在debug级别输出
+func InitializeModels(modelConfigs []config.ModelConfig, proxies []config.ProxyConfig, logger *logging.Logger) ([]LLMModel, error) {
 	models := make([]LLMModel, 0, len(modelConfigs))
 
+	// 关闭证书校验时无论日志级别都给出警告，避免在生产环境中误用
+	for _, cfg := range modelConfigs {
+		if !cfg.Skip && cfg.HTTP.TLS.InsecureSkipVerify {
+			logger.Errorf("警告: 已开启 tls.insecure_skip_verify，不校验服务端证书，请求可能被中间人截获，仅应在测试环境中使用")
+			break
+		}
+	}
+	for _, proxy := range proxies {
+		if proxy.InsecureSkipVerify {
+			logger.Errorf("警告: 代理 %s 已开启 insecure_skip_verify，通过该代理的请求不校验服务端证书，仅应在信任该代理时使用", proxy.Name)
+		}
+	}
+
 	for _, cfg := range modelConfigs {
 		if cfg.Skip {
 			continue
@@ -52,17 +158,21 @@ func InitializeModels(modelConfigs []config.ModelConfig, proxies []config.ProxyC
 		var model LLMModel
 		var err error
 
-		switch cfg.Type {
-		case "openai":
-			model, err = NewOpenAIModel(cfg, proxies)
-		case "anthropic":
-			model, err = NewAnthropicModel(cfg, proxies)
-		case "gemini":
-			model, err = NewGeminiModel(cfg, proxies)
-		default:
-			return nil, fmt.Errorf("不支持的模型类型: %s", cfg.Type)
+		// 优先使用通过 RegisterProvider 注册的模型类型，否则使用内置类型
+		if factory, ok := lookupProvider(cfg.Type); ok {
+			model, err = factory(cfg, proxies)
+			if err != nil {
+				return nil, fmt.Errorf("初始化模型 %s 失败: %w", cfg.Name, err)
+			}
+			models = append(models, model)
+			continue
 		}
 
+		provider, ok := lookupBuiltinProvider(cfg.Type)
+		if !ok {
+			return nil, fmt.Errorf("不支持的模型类型: %s", cfg.Type)
+		}
+		model, err = provider.newModel(cfg, proxies, logger)
 		if err != nil {
 			return nil, fmt.Errorf("初始化模型 %s 失败: %w", cfg.Name, err)
 		}
@@ -73,9 +183,236 @@ func InitializeModels(modelConfigs []config.ModelConfig, proxies []config.ProxyC
 	return models, nil
 }
 
+// EstimateTokens 在服务端未返回usage时粗略估算文本的token数量
+// 中日韩字符大约每个字符一个token，其他文本大约每4个字节一个token
+func EstimateTokens(text string) int {
+	cjk := 0
+	other := 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other += utf8.RuneLen(r)
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// promptMessages 返回本次请求要发送的对话：上下文中有多轮对话消息时原样使用，
+// 否则由系统消息（为空时省略）和用户消息组成
+func promptMessages(ctx context.Context, systemMessage, userMessage string) []config.MessageConfig {
+	if messages, ok := ctx.Value(MessagesContextKey).([]config.MessageConfig); ok && len(messages) > 0 {
+		return messages
+	}
+	var messages []config.MessageConfig
+	if systemMessage != "" {
+		messages = append(messages, config.MessageConfig{Role: "system", Content: systemMessage})
+	}
+	return append(messages, config.MessageConfig{Role: "user", Content: userMessage})
+}
+
+// promptImages 返回本次请求要随最后一条用户消息发送的图片
+func promptImages(ctx context.Context) []config.ImageConfig {
+	images, _ := ctx.Value(ImagesContextKey).([]config.ImageConfig)
+	return images
+}
+
+// lastUserMessage 返回对话中最后一条用户消息的下标，没有用户消息时返回-1
+func lastUserMessage(messages []config.MessageConfig) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitSystemMessages 将系统消息合并为一段文本，用于系统提示词单独传递的API（例如Anthropic）
+func splitSystemMessages(messages []config.MessageConfig) (string, []config.MessageConfig) {
+	var system []string
+	var rest []config.MessageConfig
+	for _, message := range messages {
+		if message.Role == "system" {
+			system = append(system, message.Content)
+		} else {
+			rest = append(rest, message)
+		}
+	}
+	return strings.Join(system, "\n\n"), rest
+}
+
+// messagesText 拼接对话中所有消息的内容，用于估算输入token数
+func messagesText(messages []config.MessageConfig) string {
+	var sb strings.Builder
+	for _, message := range messages {
+		sb.WriteString(message.Content)
+	}
+	return sb.String()
+}
+
+// countingReader 统计读取的字节数，用于记录流式响应的大小
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// acceptEncoding 请求头中声明支持的压缩格式，设置后 http.Transport 不再自动解压，由 decodeResponseBody 解压
+const acceptEncoding = "gzip, deflate"
+
+// decodedBody 解压后的响应体，关闭时关闭原始响应体
+type decodedBody struct {
+	io.Reader
+	body io.Closer
+}
+
+func (b decodedBody) Close() error {
+	return b.body.Close()
+}
+
+// decodeResponseBody 按 Content-Encoding 透明地解压响应体（gzip、deflate），流式响应同样适用
+// 没有压缩或压缩格式未知时保持原样
+func decodeResponseBody(resp *http.Response) error {
+	var reader io.Reader
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(resp.Body)
+		if err == io.EOF {
+			// 空响应体没有gzip头
+			reader = strings.NewReader("")
+		} else if err != nil {
+			return fmt.Errorf("解压gzip响应失败: %w", err)
+		} else {
+			reader = gz
+		}
+	case "deflate":
+		// HTTP的deflate应当是zlib格式，但部分服务返回不带zlib头的原始deflate数据，根据前两个字节判断
+		buffered := bufio.NewReader(resp.Body)
+		header, _ := buffered.Peek(2)
+		if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(buffered)
+			if err != nil {
+				return fmt.Errorf("解压deflate响应失败: %w", err)
+			}
+			reader = zr
+		} else {
+			reader = flate.NewReader(buffered)
+		}
+	default:
+		return nil
+	}
+
+	resp.Body = decodedBody{Reader: reader, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	return nil
+}
+
+// newTLSConfig 根据TLS配置创建客户端的 tls.Config，没有任何设置时返回nil（使用默认的证书校验）
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if !cfg.InsecureSkipVerify && cfg.CACertFile == "" && cfg.ClientCertFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CACertFile != "" {
+		pem, err := os.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %w", err)
+		}
+		// 在系统根证书的基础上追加，同时信任公共证书和内部CA签发的证书
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA证书文件 %s 中没有有效的PEM证书", cfg.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// proxyTLSConfig 返回通过代理发送请求时使用的TLS配置
+// 代理开启 insecure_skip_verify 时复制一份并关闭证书校验，不影响直连和其他代理共用的配置
+func proxyTLSConfig(base *tls.Config, proxy config.ProxyConfig) *tls.Config {
+	if !proxy.InsecureSkipVerify {
+		return base
+	}
+	if base == nil {
+		return &tls.Config{InsecureSkipVerify: true}
+	}
+	tlsConfig := base.Clone()
+	tlsConfig.InsecureSkipVerify = true
+	return tlsConfig
+}
+
+// newHTTPTransport 根据连接池配置创建Transport，proxyURL为nil时使用环境变量中的代理
+// tlsConfig 为nil时使用默认的TLS设置
+// 基于 http.DefaultTransport 复制，保留默认的拨号超时和HTTP/2支持
+func newHTTPTransport(httpConfig config.HTTPConfig, tlsConfig *tls.Config, proxyURL *url.URL) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if httpConfig.MaxIdleConns > 0 {
+		transport.MaxIdleConns = httpConfig.MaxIdleConns
+	}
+	if httpConfig.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = httpConfig.MaxIdleConnsPerHost
+	}
+	// 为-1时不限制，与 http.Transport 的0相同
+	if httpConfig.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = httpConfig.MaxConnsPerHost
+	}
+	// 指定本地地址时从该地址发起连接，其他拨号参数与 http.DefaultTransport 相同
+	// 地址在加载配置时已经校验，这里解析失败时使用默认的本地地址
+	if localAddr, err := httpConfig.LocalTCPAddr(); err == nil && localAddr != nil {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			LocalAddr: localAddr,
+		}).DialContext
+	}
+	return transport
+}
+
 // BaseModel 提供基本的模型实现
 type BaseModel struct {
 	config config.ModelConfig
+	logger *logging.Logger
+	// 上下文中没有指定密钥下标时，轮流选择 api_keys 的计数器
+	keyCounter uint64
+	// 上下文中没有指定代理下标时，轮流选择 proxy_names 的计数器
+	proxyCounter uint64
+}
+
+// NewBaseModel 创建BaseModel，通过 RegisterProvider 注册的模型类型可以嵌入它，
+// 只需实现 GenerateResponse 即可满足 LLMModel 接口。logger 为nil时不输出日志
+func NewBaseModel(cfg config.ModelConfig, logger *logging.Logger) BaseModel {
+	return BaseModel{
+		config: cfg,
+		logger: logger,
+	}
 }
 
 // GetName 返回模型名称
@@ -93,7 +430,100 @@ func (m *BaseModel) GetStreamSetting() *bool {
 	return m.config.Stream
 }
 
-// GetProxyName 返回模型使用的代理名称
+// GetRequestTimeout 返回模型特定的请求超时时间
+func (m *BaseModel) GetRequestTimeout() time.Duration {
+	return m.config.RequestTimeout
+}
+
+// MaxConcurrency 返回配置的同时发送给模型的最大请求数，实现 ConcurrencyLimiter
+func (m *BaseModel) MaxConcurrency() int {
+	return m.config.MaxConcurrency
+}
+
+// GetProxyName 返回模型使用的代理名称，配置了代理池时为以逗号分隔的所有代理名称
 func (m *BaseModel) GetProxyName() string {
+	if len(m.config.ProxyNames) > 0 {
+		return strings.Join(m.config.ProxyNames, ",")
+	}
 	return m.config.ProxyName
 }
+
+// ProxyNames 返回代理池中的代理名称，未配置代理池时为nil
+func (m *BaseModel) ProxyNames() []string {
+	return m.config.ProxyNames
+}
+
+// proxyName 返回本次请求使用的代理名称：配置了 proxy_names 时优先使用上下文中指定的下标，
+// 未指定时按请求轮流选择，并发调用是安全的；未配置代理时为空
+func (m *BaseModel) proxyName(ctx context.Context) string {
+	names := m.config.ProxyNames
+	if len(names) == 0 {
+		return m.config.ProxyName
+	}
+	if index, ok := ctx.Value(ProxyIndexContextKey).(int); ok && index >= 0 {
+		return names[index%len(names)]
+	}
+	index := atomic.AddUint64(&m.proxyCounter, 1) - 1
+	return names[index%uint64(len(names))]
+}
+
+// GetPricing 返回每1000个输入、输出Token的价格
+func (m *BaseModel) GetPricing() (float64, float64) {
+	return m.config.InputPricePer1K, m.config.OutputPricePer1K
+}
+
+// APIKeyCount 返回配置的API密钥个数，只配置了 api_key 时为1，都未配置时为0
+func (m *BaseModel) APIKeyCount() int {
+	if len(m.config.APIKeys) > 0 {
+		return len(m.config.APIKeys)
+	}
+	if m.config.APIKey != "" {
+		return 1
+	}
+	return 0
+}
+
+// APIKeyLabel 返回第i个API密钥在报告中显示的名称：序号和密钥的最后4个字符
+func (m *BaseModel) APIKeyLabel(i int) string {
+	key := m.config.APIKey
+	if len(m.config.APIKeys) > 0 {
+		key = m.config.APIKeys[i]
+	}
+	if len(key) <= 8 {
+		return fmt.Sprintf("#%d", i+1)
+	}
+	return fmt.Sprintf("#%d (...%s)", i+1, key[len(key)-4:])
+}
+
+// apiKey 返回本次请求使用的API密钥：配置了 api_keys 时优先使用上下文中指定的下标，
+// 未指定时按请求轮流选择，并发调用是安全的
+func (m *BaseModel) apiKey(ctx context.Context) string {
+	keys := m.config.APIKeys
+	if len(keys) == 0 {
+		return m.config.APIKey
+	}
+	if index, ok := ctx.Value(APIKeyIndexContextKey).(int); ok && index >= 0 {
+		return keys[index%len(keys)]
+	}
+	index := atomic.AddUint64(&m.keyCounter, 1) - 1
+	return keys[index%uint64(len(keys))]
+}
+
+// chatURL 返回对话接口的URL：base_url 加上配置的 chat_path，未配置时使用模型类型的默认路径，
+// chat_path 为"/"时直接请求 base_url
+func (m *BaseModel) chatURL(defaultPath string) string {
+	path := m.config.ChatPath
+	if path == "" {
+		path = defaultPath
+	}
+	baseURL := strings.TrimSuffix(m.config.BaseURL, "/")
+	if path == "/" {
+		return baseURL
+	}
+	return baseURL + path
+}
+
+// GetResponseFormat 默认不使用response_format，支持的模型自行实现
+func (m *BaseModel) GetResponseFormat() string {
+	return ""
+}
@@ -0,0 +1,287 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
+)
+
+// 本地Ollama服务的默认地址
+const defaultOllamaBaseURL = "http://localhost:11434"
+
+// OllamaModel Ollama本地模型实现
+type OllamaModel struct {
+	BaseModel
+	defaultClient *http.Client
+	proxyClients  map[string]*http.Client // 代理名称到对应HTTP客户端的映射
+}
+
+// OllamaRequest 定义Ollama /api/chat 请求结构
+type OllamaRequest struct {
+	Model    string                 `json:"model"`
+	Messages []OllamaMessage        `json:"messages"`
+	Stream   bool                   `json:"stream"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+// OllamaMessage 定义Ollama消息结构
+type OllamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// OllamaResponse 定义Ollama响应结构，流式响应的每一行也使用该结构
+type OllamaResponse struct {
+	Model           string        `json:"model"`
+	Message         OllamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason,omitempty"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+// NewOllamaModel 创建新的Ollama模型
+func NewOllamaModel(cfg config.ModelConfig, proxies []config.ProxyConfig, logger *logging.Logger) (*OllamaModel, error) {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultOllamaBaseURL
+	}
+
+	// 根据TLS配置加载CA证书和客户端证书，默认客户端和代理客户端共用
+	tlsConfig, err := newTLSConfig(cfg.HTTP.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建默认客户端，不设置客户端超时，由调用方通过context控制请求超时
+	defaultClient := &http.Client{
+		Transport: newHTTPTransport(cfg.HTTP, tlsConfig, nil),
+	}
+
+	// 创建代理客户端映射
+	proxyClients := make(map[string]*http.Client)
+
+	// 为每个代理创建对应的HTTP客户端
+	for _, proxy := range proxies {
+		// 解析代理URL，包含认证信息时由Transport设置 Proxy-Authorization
+		parsedURL, err := proxy.ProxyURL()
+		if err != nil {
+			logger.Infof("%v，跳过该代理", err)
+			continue
+		}
+
+		// 创建带有代理的Transport
+		transport := newHTTPTransport(cfg.HTTP, proxyTLSConfig(tlsConfig, proxy), parsedURL)
+
+		// 创建客户端并存储
+		proxyClients[proxy.Name] = &http.Client{
+			Transport: transport,
+		}
+	}
+
+	return &OllamaModel{
+		BaseModel: BaseModel{
+			config: cfg,
+			logger: logger,
+		},
+		defaultClient: defaultClient,
+		proxyClients:  proxyClients,
+	}, nil
+}
+
+// GenerateResponse 生成响应，调用Ollama的 /api/chat 接口
+func (m *OllamaModel) GenerateResponse(ctx context.Context, systemMessage, userMessage string, stream bool) (*LLMResponse, error) {
+	// 选择合适的HTTP客户端
+	client := m.defaultClient
+
+	// 如果模型配置了代理，并且代理客户端存在，则使用代理客户端；配置了代理池时按请求轮流选择
+	proxyName := m.proxyName(ctx)
+	if proxyName != "" {
+		if proxyClient, ok := m.proxyClients[proxyName]; ok {
+			client = proxyClient
+			m.logger.Debugf("使用代理: %s", proxyName)
+		} else {
+			m.logger.Infof("未找到配置的代理: %s，使用默认客户端", proxyName)
+		}
+	}
+
+	modelName, err := m.config.StringParam("model", "")
+	if err != nil {
+		return nil, err
+	}
+	if modelName == "" {
+		return nil, fmt.Errorf("模型 %s 未在 params.model 中指定模型名称", m.config.Name)
+	}
+
+	// 构建消息，系统消息为空时不发送；场景配置了多轮对话时按原角色发送每条消息
+	conversation := promptMessages(ctx, systemMessage, userMessage)
+	messages := make([]OllamaMessage, 0, len(conversation))
+	for _, message := range conversation {
+		messages = append(messages, OllamaMessage{Role: message.Role, Content: message.Content})
+	}
+
+	// 生成参数，Ollama使用 num_predict 表示最大输出token数
+	options := make(map[string]interface{})
+	if m.config.HasParam("temperature") {
+		temperature, err := m.config.FloatParam("temperature", 0)
+		if err != nil {
+			return nil, err
+		}
+		options["temperature"] = temperature
+	}
+	if m.config.HasParam("max_tokens") {
+		maxTokens, err := m.config.IntParam("max_tokens", 0)
+		if err != nil {
+			return nil, err
+		}
+		options["num_predict"] = maxTokens
+	}
+
+	reqBody := OllamaRequest{
+		Model:    modelName,
+		Messages: messages,
+		Stream:   stream,
+		Options:  options,
+	}
+
+	// 序列化请求体
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求体失败: %w", err)
+	}
+
+	// 创建HTTP请求
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
+		m.Endpoint(),
+		bytes.NewBuffer(jsonData),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+
+	// 设置请求头
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if apiKey := m.apiKey(ctx); apiKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+	}
+
+	// 发送请求
+	req, trace := traceRequest(req)
+	startTime := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 网关返回压缩的响应时先解压，错误响应同样需要解压后才能读取错误信息
+	if err := decodeResponseBody(resp); err != nil {
+		return nil, err
+	}
+
+	// 检查状态码
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			Model:      m.config.Name,
+		}
+	}
+
+	result := &LLMResponse{}
+	trace.apply(result)
+
+	// 非流式响应处理
+	if !stream {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("读取响应体失败: %w", err)
+		}
+		result.ResponseBytes = int64(len(body))
+
+		m.logger.Debugf("Ollama API请求延迟(非流式): %s", time.Since(startTime))
+
+		var ollamaResp OllamaResponse
+		if err := json.Unmarshal(body, &ollamaResp); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrParseResponse, err)
+		}
+
+		result.Content = ollamaResp.Message.Content
+		result.InputTokens = ollamaResp.PromptEvalCount
+		result.OutputTokens = ollamaResp.EvalCount
+		result.FinishReason = ollamaResp.DoneReason
+		return result, nil
+	}
+
+	// 流式响应处理，Ollama使用NDJSON格式，每行一个JSON对象
+	var fullContent strings.Builder
+	var firstTokenReceived bool
+	var timer tokenTimer
+
+	counter := &countingReader{r: resp.Body}
+	reader := bufio.NewReader(counter)
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+
+		if line != "" {
+			var chunk OllamaResponse
+			if jsonErr := json.Unmarshal([]byte(line), &chunk); jsonErr != nil {
+				m.logger.Infof("解析流响应块失败: %v, 数据: %s", jsonErr, line)
+			} else {
+				if chunk.Message.Content != "" {
+					// 记录首个token接收时间
+					if !firstTokenReceived {
+						firstTokenReceived = true
+						result.TimeToFirstToken = time.Since(startTime)
+					}
+					timer.observe()
+					fullContent.WriteString(chunk.Message.Content)
+				}
+
+				// 最后一行包含token统计
+				if chunk.Done {
+					result.InputTokens = chunk.PromptEvalCount
+					result.OutputTokens = chunk.EvalCount
+					result.FinishReason = chunk.DoneReason
+					break
+				}
+			}
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("读取流式响应失败: %w", err)
+		}
+	}
+
+	m.logger.Debugf("Ollama API流式请求延迟(包含所有流式数据): %s", time.Since(startTime))
+
+	result.Content = fullContent.String()
+	result.ResponseBytes = counter.n
+	if firstTokenReceived {
+		result.TokensPerSecond = timer.tokensPerSecond(result.OutputTokens)
+		m.logger.Debugf("流式响应速率: %.2f tokens/sec", result.TokensPerSecond)
+	}
+
+	return result, nil
+}
+
+// Endpoint 返回Ollama对话接口的URL
+func (m *OllamaModel) Endpoint() string {
+	return m.chatURL("/api/chat")
+}
@@ -1,19 +1,18 @@
 package model
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
 )
 
 // OpenAIModel OpenAI模型实现
@@ -21,16 +20,29 @@ type OpenAIModel struct {
 	BaseModel
 	defaultClient *http.Client
 	proxyClients  map[string]*http.Client // 代理名称到对应HTTP客户端的映射
+	// 模型没有对应的tiktoken编码时只提示一次按字符数估算
+	tokenizerFallback sync.Once
 }
 
 // OpenAIRequest 定义OpenAI API请求结构
 type OpenAIRequest struct {
-	Model       string                 `json:"model"`
-	Messages    []OpenAIMessage        `json:"messages"`
-	Temperature float64                `json:"temperature"`
-	MaxTokens   int                    `json:"max_tokens"`
-	Stream      bool                   `json:"stream,omitempty"`
-	Params      map[string]interface{} `json:"-"`
+	Model         string               `json:"model"`
+	Messages      []OpenAIMessage      `json:"messages"`
+	Temperature   float64              `json:"temperature"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	Stop          []string             `json:"stop,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *OpenAIStreamOptions `json:"stream_options,omitempty"`
+	Tools         json.RawMessage      `json:"tools,omitempty"`
+	// 结构化输出格式，例如 {"type": "json_object"} 或 {"type": "json_schema", ...}
+	ResponseFormat interface{}            `json:"response_format,omitempty"`
+	Params         map[string]interface{} `json:"-"`
+}
+
+// OpenAIStreamOptions 定义流式请求选项
+type OpenAIStreamOptions struct {
+	// 是否在流式响应的最后一个数据块中返回usage
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // OpenAIMessage 定义OpenAI消息结构
@@ -80,10 +92,17 @@ func (m OpenAIMessage) MarshalJSON() ([]byte, error) {
 	})
 }
 
-// OpenAIMessageContent 定义消息内容结构
+// OpenAIMessageContent 定义消息内容结构，Type 为 text 时使用 Text，为 image_url 时使用 ImageURL
 type OpenAIMessageContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *OpenAIImageURL `json:"image_url,omitempty"`
+}
+
+// OpenAIImageURL 定义图片内容，URL 可以是 http(s) 地址或者 base64 编码的 data URL
+type OpenAIImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
 }
 
 // OpenAIResponse 定义OpenAI API响应结构
@@ -94,17 +113,27 @@ type OpenAIResponse struct {
 	Model   string         `json:"model"`
 	Choices []OpenAIChoice `json:"choices"`
 	Usage   struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
+		PromptTokens            int                            `json:"prompt_tokens"`
+		CompletionTokens        int                            `json:"completion_tokens"`
+		TotalTokens             int                            `json:"total_tokens"`
+		CompletionTokensDetails *OpenAICompletionTokensDetails `json:"completion_tokens_details,omitempty"`
 	} `json:"usage"`
 }
 
+// OpenAICompletionTokensDetails 定义输出token的明细，推理模型在其中返回推理token数
+type OpenAICompletionTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
+}
+
 // OpenAIChoice 定义OpenAI响应选择结构
 type OpenAIChoice struct {
 	Index   int `json:"index"`
 	Message struct {
 		Content string `json:"content"`
+		// 推理内容，不同的服务使用不同的字段名（例如DeepSeek使用 reasoning_content）
+		Reasoning        string            `json:"reasoning,omitempty"`
+		ReasoningContent string            `json:"reasoning_content,omitempty"`
+		ToolCalls        []json.RawMessage `json:"tool_calls,omitempty"`
 	} `json:"message"`
 	FinishReason string `json:"finish_reason"`
 }
@@ -121,24 +150,41 @@ type OpenAIStreamResponse struct {
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
 	Usage *struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
+		PromptTokens            int                            `json:"prompt_tokens"`
+		CompletionTokens        int                            `json:"completion_tokens"`
+		TotalTokens             int                            `json:"total_tokens"`
+		CompletionTokensDetails *OpenAICompletionTokensDetails `json:"completion_tokens_details,omitempty"`
 	} `json:"usage"`
 }
 
 // Delta 表示部分响应内容
 type Delta struct {
-	Content   string `json:"content"`
-	Reasoning string `json:"reasoning,omitempty"`
-	Role      string `json:"role,omitempty"`
+	Content string `json:"content"`
+	// 推理内容，不同的服务使用不同的字段名（例如DeepSeek使用 reasoning_content）
+	Reasoning        string `json:"reasoning,omitempty"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	Role             string `json:"role,omitempty"`
+	// 流式工具调用，同一个调用的参数分多个数据块返回，通过 Index 区分不同的调用
+	ToolCalls []struct {
+		Index    int `json:"index"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls,omitempty"`
 }
 
 // NewOpenAIModel 创建新的OpenAI模型
-func NewOpenAIModel(cfg config.ModelConfig, proxies []config.ProxyConfig) (*OpenAIModel, error) {
-	// 创建默认客户端
+func NewOpenAIModel(cfg config.ModelConfig, proxies []config.ProxyConfig, logger *logging.Logger) (*OpenAIModel, error) {
+	// 根据TLS配置加载CA证书和客户端证书，默认客户端和代理客户端共用
+	tlsConfig, err := newTLSConfig(cfg.HTTP.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建默认客户端，不设置客户端超时，由调用方通过context控制请求超时
 	defaultClient := &http.Client{
-		Timeout: 600 * time.Second,
+		Transport: newHTTPTransport(cfg.HTTP, tlsConfig, nil),
 	}
 
 	// 创建代理客户端映射
@@ -146,28 +192,26 @@ func NewOpenAIModel(cfg config.ModelConfig, proxies []config.ProxyConfig) (*Open
 
 	// 为每个代理创建对应的HTTP客户端
 	for _, proxy := range proxies {
-		// 解析代理URL
-		parsedURL, err := url.Parse(proxy.URL)
+		// 解析代理URL，包含认证信息时由Transport设置 Proxy-Authorization
+		parsedURL, err := proxy.ProxyURL()
 		if err != nil {
-			log.Printf("解析代理URL失败 (%s): %v", proxy.Name, err)
+			logger.Infof("%v，跳过该代理", err)
 			continue
 		}
 
 		// 创建带有代理的Transport
-		transport := &http.Transport{
-			Proxy: http.ProxyURL(parsedURL),
-		}
+		transport := newHTTPTransport(cfg.HTTP, proxyTLSConfig(tlsConfig, proxy), parsedURL)
 
 		// 创建客户端并存储
 		proxyClients[proxy.Name] = &http.Client{
 			Transport: transport,
-			Timeout:   600 * time.Second,
 		}
 	}
 
 	return &OpenAIModel{
 		BaseModel: BaseModel{
 			config: cfg,
+			logger: logger,
 		},
 		defaultClient: defaultClient,
 		proxyClients:  proxyClients,
@@ -179,45 +223,81 @@ func (m *OpenAIModel) GenerateResponse(ctx context.Context, systemMessage, userM
 	// 选择合适的HTTP客户端
 	client := m.defaultClient
 
-	// 如果模型配置了代理，并且代理客户端存在，则使用代理客户端
-	if m.config.ProxyName != "" {
-		if proxyClient, ok := m.proxyClients[m.config.ProxyName]; ok {
+	// 如果模型配置了代理，并且代理客户端存在，则使用代理客户端；配置了代理池时按请求轮流选择
+	proxyName := m.proxyName(ctx)
+	if proxyName != "" {
+		if proxyClient, ok := m.proxyClients[proxyName]; ok {
 			client = proxyClient
-			log.Printf("使用代理: %s", m.config.ProxyName)
+			m.logger.Debugf("使用代理: %s", proxyName)
 		} else {
-			log.Printf("未找到配置的代理: %s，使用默认客户端", m.config.ProxyName)
+			m.logger.Infof("未找到配置的代理: %s，使用默认客户端", proxyName)
 		}
 	}
 
-	// 构建请求
+	// 读取模型参数，OpenAI兼容网关通常只需要配置 base_url 和这些参数
+	modelName, err := m.config.StringParam("model", "")
+	if err != nil {
+		return nil, err
+	}
+	if modelName == "" {
+		return nil, fmt.Errorf("模型 %s 未在 params.model 中指定模型名称", m.config.Name)
+	}
+	// temperature 未设置时使用OpenAI的默认值1.0
+	temperature, err := m.config.FloatParam("temperature", 1.0)
+	if err != nil {
+		return nil, err
+	}
+	// max_tokens 未设置时不发送，由服务端决定
+	maxTokens, err := m.config.IntParam("max_tokens", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// 停止序列，命中后服务端提前结束生成并返回 finish_reason 为 stop
+	stop, err := m.config.StringListParam("stop")
+	if err != nil {
+		return nil, err
+	}
+
+	// 构建请求，对话中的每条消息按原角色发送
+	messages := promptMessages(ctx, systemMessage, userMessage)
 	reqBody := OpenAIRequest{
-		Model: m.config.Params["model"].(string),
-		Messages: []OpenAIMessage{
-			{
-				Role: "system",
-				Text: systemMessage,
-				// Content: []OpenAIMessageContent{
-				// 	{
-				// 		Type: "text",
-				// 		Text: systemMessage,
-				// 	},
-				// },
-			},
-			{
-				Role: "user",
-				Text: userMessage,
-				// Content: []OpenAIMessageContent{
-				// 	{
-				// 		Type: "text",
-				// 		Text: userMessage,
-				// 	},
-				// },
-			},
-		},
-		Temperature: m.config.Params["temperature"].(float64),
-		MaxTokens:   int(m.config.Params["max_tokens"].(int)),
+		Model:       modelName,
+		Messages:    make([]OpenAIMessage, 0, len(messages)),
+		Temperature: temperature,
+		MaxTokens:   maxTokens,
+		Stop:        stop,
 		Stream:      stream,
 	}
+	for _, message := range messages {
+		reqBody.Messages = append(reqBody.Messages, OpenAIMessage{Role: message.Role, Text: message.Content})
+	}
+	// 场景配置了图片时，最后一条用户消息改为文本和图片组成的内容数组
+	if images := promptImages(ctx); len(images) > 0 {
+		if i := lastUserMessage(messages); i >= 0 {
+			reqBody.Messages[i] = OpenAIMessage{Role: "user", Content: openAIImageContent(messages[i].Content, images)}
+		}
+	}
+
+	// 配置了 params.response_format 时请求结构化输出
+	responseFormat, err := m.responseFormat()
+	if err != nil {
+		return nil, err
+	}
+	reqBody.ResponseFormat = responseFormat
+
+	// 场景配置了工具定义时随请求发送
+	if tools, ok := ctx.Value(ToolsContextKey).(json.RawMessage); ok && len(tools) > 0 {
+		reqBody.Tools = tools
+	}
+
+	// 流式请求默认要求服务端返回usage，部分端点不支持该字段时可以通过
+	// params.stream_include_usage: false 关闭
+	if stream {
+		if includeUsage, ok := m.config.Params["stream_include_usage"].(bool); !ok || includeUsage {
+			reqBody.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+		}
+	}
 
 	// 序列化请求体
 	jsonData, err := json.Marshal(reqBody)
@@ -225,11 +305,17 @@ func (m *OpenAIModel) GenerateResponse(ctx context.Context, systemMessage, userM
 		return nil, fmt.Errorf("序列化请求体失败: %w", err)
 	}
 
+	// 配置了 params.request_template 时，将标准字段合并到模板中，用于发送服务商特有的参数
+	jsonData, err = m.applyRequestTemplate(jsonData)
+	if err != nil {
+		return nil, err
+	}
+
 	// 创建HTTP请求
 	req, err := http.NewRequestWithContext(
 		ctx,
 		"POST",
-		m.config.BaseURL+"/chat/completions",
+		m.Endpoint(),
 		bytes.NewBuffer(jsonData),
 	)
 	if err != nil {
@@ -238,9 +324,17 @@ func (m *OpenAIModel) GenerateResponse(ctx context.Context, systemMessage, userM
 
 	// 设置请求头
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.config.APIKey))
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.apiKey(ctx)))
+	if m.config.Organization != "" {
+		req.Header.Set("OpenAI-Organization", m.config.Organization)
+	}
+	if m.config.Project != "" {
+		req.Header.Set("OpenAI-Project", m.config.Project)
+	}
 
 	// 发送请求
+	req, trace := traceRequest(req)
 	startTime := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
@@ -248,10 +342,20 @@ func (m *OpenAIModel) GenerateResponse(ctx context.Context, systemMessage, userM
 	}
 	defer resp.Body.Close()
 
+	// 网关返回压缩的响应时先解压，错误响应同样需要解压后才能读取错误信息
+	if err := decodeResponseBody(resp); err != nil {
+		return nil, err
+	}
+
 	// 检查状态码
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API请求失败: 状态码=%d, 响应=%s", resp.StatusCode, string(body))
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			Model:      m.config.Name,
+			RateLimit:  parseRateLimit(resp.Header),
+		}
 	}
 
 	// 初始化返回结果
@@ -259,7 +363,9 @@ func (m *OpenAIModel) GenerateResponse(ctx context.Context, systemMessage, userM
 		Content:      "",
 		InputTokens:  0,
 		OutputTokens: 0,
+		RateLimit:    parseRateLimit(resp.Header),
 	}
+	trace.apply(result)
 
 	// 非流式响应处理
 	if !stream {
@@ -269,35 +375,50 @@ func (m *OpenAIModel) GenerateResponse(ctx context.Context, systemMessage, userM
 		if err != nil {
 			return nil, fmt.Errorf("读取响应体失败: %w", err)
 		}
+		result.ResponseBytes = int64(len(body))
 
 		// 打印请求延迟（可选，用于调试）
-		log.Printf("OpenAI API请求延迟(非流式): %s", requestLatency)
+		m.logger.Debugf("OpenAI API请求延迟(非流式): %s", requestLatency)
 
 		// 解析响应
 		var openAIResp OpenAIResponse
 		if err := json.Unmarshal(body, &openAIResp); err != nil {
-			return nil, fmt.Errorf("解析响应失败: %w", err)
+			return nil, fmt.Errorf("%w: %w", ErrParseResponse, err)
 		}
 
 		// 构建返回结果
 		result.InputTokens = openAIResp.Usage.PromptTokens
 		result.OutputTokens = openAIResp.Usage.CompletionTokens
+		if details := openAIResp.Usage.CompletionTokensDetails; details != nil {
+			result.ReasoningTokens = details.ReasoningTokens
+		}
 
 		// 提取内容
 		if len(openAIResp.Choices) > 0 {
-			content := openAIResp.Choices[0].Message.Content
-			result.Content = content
+			message := openAIResp.Choices[0].Message
+			result.Content = message.Content
+			result.FinishReason = openAIResp.Choices[0].FinishReason
+			result.ToolCalls = len(message.ToolCalls)
+
+			// 服务端没有返回推理token数时，根据推理内容估算
+			if reasoning := message.Reasoning + message.ReasoningContent; result.ReasoningTokens == 0 && reasoning != "" {
+				result.ReasoningTokens = m.estimateTokens(modelName, reasoning)
+				result.TokensEstimated = true
+			}
 		}
 	} else {
 		// 流式响应处理
 		var fullContent string
-		var tokenCount int
+		var reasoningContent strings.Builder
+		var toolCallArguments strings.Builder
+		var usageReported bool
 		var firstTokenReceived bool
 		var firstTokenTime time.Duration
-		var tokenStartTime time.Time
+		var timer tokenTimer
 
-		// 创建一个新的reader
-		reader := bufio.NewReader(resp.Body)
+		// 按事件读取SSE流，一个JSON块可能被拆成多次读取或多个 data 行
+		counter := &countingReader{r: resp.Body}
+		reader := newSSEReader(counter)
 
 	LOOP:
 		for {
@@ -306,8 +427,7 @@ func (m *OpenAIModel) GenerateResponse(ctx context.Context, systemMessage, userM
 			case <-ctx.Done():
 				return nil, ctx.Err()
 			default:
-				// 读取一行数据，格式是 data: {...}
-				line, err := reader.ReadString('\n')
+				event, err := reader.Next()
 				if err != nil {
 					if err == io.EOF {
 						break LOOP
@@ -315,53 +435,56 @@ func (m *OpenAIModel) GenerateResponse(ctx context.Context, systemMessage, userM
 					return nil, fmt.Errorf("读取流式响应失败: %w", err)
 				}
 
-				// 去除前缀和空行
-				line = strings.TrimSpace(line)
-				if line == "" {
+				dataJSON := strings.TrimSpace(event.Data)
+				if dataJSON == "" {
 					continue
 				}
-				if line == "[DONE]" {
+
+				if dataJSON == "[DONE]" {
 					break LOOP
 				}
 
-				// 解析JSON，前缀通常是 "data: "
-				if strings.HasPrefix(line, "data: ") {
-					dataJSON := strings.TrimPrefix(line, "data: ")
-					if dataJSON == "" {
-						continue
-					}
+				var streamResp OpenAIStreamResponse
+				if err := json.Unmarshal([]byte(dataJSON), &streamResp); err != nil {
+					m.logger.Infof("解析流响应块失败: %v, 数据: %s", err, dataJSON)
+					continue
+				}
 
-					if dataJSON == "[DONE]" {
-						break LOOP
+				if streamResp.Usage != nil {
+					usageReported = true
+					result.InputTokens += streamResp.Usage.PromptTokens
+					result.OutputTokens += streamResp.Usage.CompletionTokens
+					if details := streamResp.Usage.CompletionTokensDetails; details != nil {
+						result.ReasoningTokens += details.ReasoningTokens
 					}
+				}
 
-					var streamResp OpenAIStreamResponse
-					if err := json.Unmarshal([]byte(dataJSON), &streamResp); err != nil {
-						log.Printf("解析流响应块失败: %v, 数据: %s", err, dataJSON)
-						continue
+				// 累加内容
+				if len(streamResp.Choices) > 0 {
+					// 记录首个token接收时间
+					if !firstTokenReceived {
+						firstTokenReceived = true
+						firstTokenTime = time.Since(startTime)
 					}
 
-					if streamResp.Usage != nil {
-						tokenCount += streamResp.Usage.TotalTokens
-						result.InputTokens += streamResp.Usage.PromptTokens
-						result.OutputTokens += streamResp.Usage.CompletionTokens
+					delta := streamResp.Choices[0].Delta
+					// 只有角色或结束原因的数据块不包含输出token，不参与速率的计时
+					if delta.Content != "" || delta.Reasoning != "" || delta.ReasoningContent != "" || len(delta.ToolCalls) > 0 {
+						timer.observe()
 					}
-
-					// 累加内容
-					if len(streamResp.Choices) > 0 {
-						// 记录首个token接收时间
-						if !firstTokenReceived {
-							firstTokenReceived = true
-							firstTokenTime = time.Since(startTime)
-							tokenStartTime = time.Now()
-						}
-
-						content := streamResp.Choices[0].Delta.Content
-						if content != "" {
-							fullContent += content
+					fullContent += delta.Content
+					reasoningContent.WriteString(delta.Reasoning)
+					reasoningContent.WriteString(delta.ReasoningContent)
+					for _, toolCall := range delta.ToolCalls {
+						if toolCall.Index+1 > result.ToolCalls {
+							result.ToolCalls = toolCall.Index + 1
 						}
+						toolCallArguments.WriteString(toolCall.Function.Name)
+						toolCallArguments.WriteString(toolCall.Function.Arguments)
+					}
+					if reason := streamResp.Choices[0].FinishReason; reason != "" {
+						result.FinishReason = reason
 					}
-
 				}
 			}
 
@@ -369,22 +492,168 @@ func (m *OpenAIModel) GenerateResponse(ctx context.Context, systemMessage, userM
 
 		// 流式响应结束，计算总延迟时间
 		// 打印请求延迟（可选，用于调试）
-		log.Printf("OpenAI API流式请求延迟(包含所有流式数据): %s", time.Since(startTime))
+		m.logger.Debugf("OpenAI API流式请求延迟(包含所有流式数据): %s", time.Since(startTime))
 
 		// 设置流式响应结果
 		result.Content = fullContent
+		result.ResponseBytes = counter.n
+
+		// 服务端没有返回usage时，根据提示词和累积的内容估算token数，优先使用tiktoken计算
+		if !usageReported {
+			result.InputTokens = m.estimateTokens(modelName, messagesText(messages))
+			result.OutputTokens = m.estimateTokens(modelName, fullContent) + m.estimateTokens(modelName, toolCallArguments.String())
+			result.TokensEstimated = true
+		}
+
+		// 服务端没有返回推理token数时，根据累积的推理内容估算，未返回usage时推理token也计入输出token
+		if result.ReasoningTokens == 0 && reasoningContent.Len() > 0 {
+			result.ReasoningTokens = m.estimateTokens(modelName, reasoningContent.String())
+			result.TokensEstimated = true
+			if !usageReported {
+				result.OutputTokens += result.ReasoningTokens
+			}
+		}
 
 		// 设置流式特定指标
 		if firstTokenReceived {
 			result.TimeToFirstToken = firstTokenTime
-			if tokenCount > 0 {
-				tokensPerSecond := float64(tokenCount) / time.Since(tokenStartTime).Seconds()
-				result.TokensPerSecond = tokensPerSecond
-				log.Printf("流式响应速率: %.2f tokens/sec", tokensPerSecond)
-			}
+			result.TokensPerSecond = timer.tokensPerSecond(result.OutputTokens)
+			m.logger.Debugf("流式响应速率: %.2f tokens/sec", result.TokensPerSecond)
 		}
 
 	}
 
 	return result, nil
 }
+
+// responseFormat 读取 params.response_format，未设置时返回nil
+// 可以写成对象，也可以简写为类型名称，例如 response_format: json_object
+func (m *OpenAIModel) responseFormat() (interface{}, error) {
+	value, ok := m.config.Params["response_format"]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	switch v := value.(type) {
+	case string:
+		return map[string]interface{}{"type": v}, nil
+	case map[string]interface{}:
+		if _, ok := v["type"].(string); !ok {
+			return nil, fmt.Errorf("模型 %s 的 response_format 参数必须包含字符串类型的 type 字段", m.config.Name)
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("模型 %s 的 response_format 参数必须是字符串或对象，实际为 %T", m.config.Name, value)
+	}
+}
+
+// applyRequestTemplate 将序列化后的请求体深度合并到 params.request_template 中
+// 模板可以是对象或JSON字符串，两者都是对象的字段递归合并，其他字段以请求体为准，
+// 因此模板只能补充 top_p、presence_penalty 等额外参数，不会覆盖消息、模型等标准字段
+func (m *OpenAIModel) applyRequestTemplate(body []byte) ([]byte, error) {
+	value, ok := m.config.Params["request_template"]
+	if !ok || value == nil {
+		return body, nil
+	}
+
+	var templateJSON []byte
+	switch v := value.(type) {
+	case string:
+		templateJSON = []byte(v)
+	case map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("模型 %s 的 request_template 参数无法序列化: %w", m.config.Name, err)
+		}
+		templateJSON = data
+	default:
+		return nil, fmt.Errorf("模型 %s 的 request_template 参数必须是对象或JSON字符串，实际为 %T", m.config.Name, value)
+	}
+
+	// 每次请求重新解析模板，合并时修改的是副本
+	template, err := decodeJSONObject(templateJSON)
+	if err != nil {
+		return nil, fmt.Errorf("模型 %s 的 request_template 参数不是有效的JSON对象: %w", m.config.Name, err)
+	}
+	request, err := decodeJSONObject(body)
+	if err != nil {
+		return nil, fmt.Errorf("解析请求体失败: %w", err)
+	}
+
+	merged, err := json.Marshal(mergeJSONValue(template, request))
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求体失败: %w", err)
+	}
+	return merged, nil
+}
+
+// decodeJSONObject 解析JSON对象，数字保持原样以免大整数丢失精度
+func decodeJSONObject(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, err
+	}
+	if object == nil {
+		return nil, fmt.Errorf("不是JSON对象")
+	}
+	return object, nil
+}
+
+// mergeJSONValue 递归合并两个JSON值，两者都是对象时按字段合并，否则使用src
+func mergeJSONValue(dst, src interface{}) interface{} {
+	dstMap, dstOK := dst.(map[string]interface{})
+	srcMap, srcOK := src.(map[string]interface{})
+	if !dstOK || !srcOK {
+		return src
+	}
+
+	for key, value := range srcMap {
+		dstMap[key] = mergeJSONValue(dstMap[key], value)
+	}
+	return dstMap
+}
+
+// GetResponseFormat 返回请求使用的response_format类型，未配置或配置无效时为空
+func (m *OpenAIModel) GetResponseFormat() string {
+	format, err := m.responseFormat()
+	if err != nil || format == nil {
+		return ""
+	}
+	return format.(map[string]interface{})["type"].(string)
+}
+
+// openAIImageContent 返回文本和图片组成的消息内容数组
+func openAIImageContent(text string, images []config.ImageConfig) []OpenAIMessageContent {
+	content := make([]OpenAIMessageContent, 0, len(images)+1)
+	content = append(content, OpenAIMessageContent{Type: "text", Text: text})
+	for _, image := range images {
+		content = append(content, OpenAIMessageContent{
+			Type:     "image_url",
+			ImageURL: &OpenAIImageURL{URL: image.Source(), Detail: image.Detail},
+		})
+	}
+	return content
+}
+
+// SupportsImages OpenAI兼容接口支持图片输入，不支持的模型由服务端返回错误
+func (m *OpenAIModel) SupportsImages() bool {
+	return true
+}
+
+// Endpoint 返回OpenAI兼容接口的URL
+func (m *OpenAIModel) Endpoint() string {
+	return m.chatURL("/chat/completions")
+}
+
+// estimateTokens 使用tiktoken计算token数，模型没有对应的编码时退回到 EstimateTokens 并提示一次
+func (m *OpenAIModel) estimateTokens(modelName, text string) int {
+	n, err := CountTokensForModel(modelName, text)
+	if err != nil {
+		m.tokenizerFallback.Do(func() {
+			m.logger.Infof("%v，按字符数估算Token数", err)
+		})
+		return EstimateTokens(text)
+	}
+	return n
+}
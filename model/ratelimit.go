@@ -0,0 +1,81 @@
+package model
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit 服务端在响应头中返回的限流信息
+type RateLimit struct {
+	// 当前窗口剩余的请求数和Token数，未返回时为-1
+	RemainingRequests int
+	RemainingTokens   int
+	// 服务端要求的重试等待时间（Retry-After），未返回时为0
+	RetryAfter time.Duration
+}
+
+// 不同服务商返回剩余请求数和Token数的响应头，按顺序取第一个存在的值
+var (
+	remainingRequestsHeaders = []string{
+		"x-ratelimit-remaining-requests",         // OpenAI及兼容服务
+		"anthropic-ratelimit-requests-remaining", // Anthropic
+	}
+	remainingTokensHeaders = []string{
+		"x-ratelimit-remaining-tokens",
+		"anthropic-ratelimit-tokens-remaining",
+	}
+)
+
+// parseRateLimit 从响应头中解析限流信息，没有任何限流相关的响应头时返回nil
+func parseRateLimit(header http.Header) *RateLimit {
+	rateLimit := &RateLimit{
+		RemainingRequests: headerInt(header, remainingRequestsHeaders),
+		RemainingTokens:   headerInt(header, remainingTokensHeaders),
+		RetryAfter:        parseRetryAfter(header),
+	}
+	if rateLimit.RemainingRequests < 0 && rateLimit.RemainingTokens < 0 && rateLimit.RetryAfter == 0 {
+		return nil
+	}
+	return rateLimit
+}
+
+// headerInt 返回第一个存在且为非负整数的响应头的值，都不存在时返回-1
+func headerInt(header http.Header, names []string) int {
+	for _, name := range names {
+		if value := strings.TrimSpace(header.Get(name)); value != "" {
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				return n
+			}
+		}
+	}
+	return -1
+}
+
+// parseRetryAfter 解析重试等待时间：优先使用毫秒精度的 retry-after-ms，
+// 其次是 Retry-After（秒数或HTTP日期），无法解析时返回0
+func parseRetryAfter(header http.Header) time.Duration {
+	if value := strings.TrimSpace(header.Get("retry-after-ms")); value != "" {
+		if ms, err := strconv.ParseFloat(value, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds > 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+		return 0
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
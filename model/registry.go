@@ -0,0 +1,141 @@
+package model
+
+import (
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
+)
+
+// ProviderFactory 根据模型配置和代理配置创建模型
+type ProviderFactory func(cfg config.ModelConfig, proxies []config.ProxyConfig) (LLMModel, error)
+
+var (
+	providersMutex sync.RWMutex
+	providers      = make(map[string]ProviderFactory)
+)
+
+// RegisterProvider 注册自定义的模型类型，配置中 type 为 name 的模型由 factory 创建
+// 注册的类型优先于内置类型，可以用来替换内置实现。通常在 init 函数中调用：
+//
+//	func init() {
+//		model.RegisterProvider("my-gateway", func(cfg config.ModelConfig, proxies []config.ProxyConfig) (model.LLMModel, error) {
+//			return NewMyGatewayModel(cfg)
+//		})
+//	}
+//
+// name 为空、factory 为nil或重复注册同一个名称时panic
+func RegisterProvider(name string, factory ProviderFactory) {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+
+	if name == "" {
+		panic("model: 注册的模型类型名称不能为空")
+	}
+	if factory == nil {
+		panic("model: 模型类型 " + name + " 的factory不能为nil")
+	}
+	if _, ok := providers[name]; ok {
+		panic("model: 重复注册模型类型 " + name)
+	}
+	providers[name] = factory
+}
+
+// RegisteredProviders 返回通过 RegisterProvider 注册的模型类型，按名称排序
+func RegisteredProviders() []string {
+	providersMutex.RLock()
+	defer providersMutex.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProviderInfo 描述一个可以在配置的 type 中使用的模型类型
+type ProviderInfo struct {
+	Name           string
+	Description    string
+	RequiresAPIKey bool // 未设置 api_key_required 时是否必须配置API密钥
+	Registered     bool // 是否通过 RegisterProvider 注册
+}
+
+// builtinProvider 内置的模型类型，与注册的类型不同，创建时使用 InitializeModels 传入的 logger
+type builtinProvider struct {
+	name        string
+	description string
+	newModel    func(cfg config.ModelConfig, proxies []config.ProxyConfig, logger *logging.Logger) (LLMModel, error)
+}
+
+// builtinProviders 内置的模型类型，InitializeModels 从这里查找构造函数，Providers 按这里的顺序列出
+var builtinProviders = []builtinProvider{
+	{"openai", "OpenAI Chat Completions 接口及兼容服务（vLLM、DeepSeek等）", func(cfg config.ModelConfig, proxies []config.ProxyConfig, logger *logging.Logger) (LLMModel, error) {
+		return NewOpenAIModel(cfg, proxies, logger)
+	}},
+	{"anthropic", "Anthropic Messages 接口", func(cfg config.ModelConfig, proxies []config.ProxyConfig, logger *logging.Logger) (LLMModel, error) {
+		return NewAnthropicModel(cfg, proxies, logger)
+	}},
+	{"gemini", "Google Gemini（目前为模拟实现，不发送真实请求）", func(cfg config.ModelConfig, proxies []config.ProxyConfig, logger *logging.Logger) (LLMModel, error) {
+		return NewGeminiModel(cfg, proxies, logger)
+	}},
+	{"ollama", "Ollama 本地模型 /api/chat 接口", func(cfg config.ModelConfig, proxies []config.ProxyConfig, logger *logging.Logger) (LLMModel, error) {
+		return NewOllamaModel(cfg, proxies, logger)
+	}},
+	{"bedrock", "AWS Bedrock InvokeModel 接口，未配置 api_key 和 secret 时使用 AWS_* 环境变量中的凭证", func(cfg config.ModelConfig, proxies []config.ProxyConfig, logger *logging.Logger) (LLMModel, error) {
+		return NewBedrockModel(cfg, proxies, logger)
+	}},
+	{"cohere", "Cohere /v2/chat 接口", func(cfg config.ModelConfig, proxies []config.ProxyConfig, logger *logging.Logger) (LLMModel, error) {
+		return NewCohereModel(cfg, proxies, logger)
+	}},
+	{"mock", "模拟模型，不发送网络请求，按参数生成延迟、Token和错误", func(cfg config.ModelConfig, _ []config.ProxyConfig, logger *logging.Logger) (LLMModel, error) {
+		return NewMockModel(cfg, logger)
+	}},
+}
+
+// lookupBuiltinProvider 查找内置的模型类型
+func lookupBuiltinProvider(name string) (builtinProvider, bool) {
+	for _, provider := range builtinProviders {
+		if provider.name == name {
+			return provider, true
+		}
+	}
+	return builtinProvider{}, false
+}
+
+// Providers 返回所有可用的模型类型：内置类型在前，之后是通过 RegisterProvider 注册的类型
+// 注册的类型替换同名的内置类型时，只列出注册的类型
+func Providers() []ProviderInfo {
+	registered := RegisteredProviders()
+	infos := make([]ProviderInfo, 0, len(builtinProviders)+len(registered))
+	for _, provider := range builtinProviders {
+		if !slices.Contains(registered, provider.name) {
+			infos = append(infos, ProviderInfo{
+				Name:           provider.name,
+				Description:    provider.description,
+				RequiresAPIKey: config.ModelConfig{Type: provider.name}.RequiresAPIKey(),
+			})
+		}
+	}
+	for _, name := range registered {
+		infos = append(infos, ProviderInfo{
+			Name:           name,
+			Description:    "通过 RegisterProvider 注册的自定义类型",
+			RequiresAPIKey: config.ModelConfig{Type: name}.RequiresAPIKey(),
+			Registered:     true,
+		})
+	}
+	return infos
+}
+
+// lookupProvider 查找注册的模型类型
+func lookupProvider(name string) (ProviderFactory, bool) {
+	providersMutex.RLock()
+	defer providersMutex.RUnlock()
+
+	factory, ok := providers[name]
+	return factory, ok
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/logging"
+)
+
+// Providers 列出的每个内置类型都能被 InitializeModels 识别，未知的类型报错
+func TestBuiltinProvidersInitialize(t *testing.T) {
+	logger := logging.New(logging.LevelQuiet)
+	providers := Providers()
+	if len(providers) < len(builtinProviders) {
+		t.Fatalf("Providers 只列出了 %d 个类型，内置类型有 %d 个", len(providers), len(builtinProviders))
+	}
+	for _, info := range providers {
+		if info.Registered {
+			continue
+		}
+		_, err := InitializeModels([]config.ModelConfig{{Name: "m", Type: info.Name}}, nil, logger)
+		// 缺少必填参数时的错误说明类型已被识别
+		if err != nil && strings.Contains(err.Error(), "不支持的模型类型") {
+			t.Errorf("内置类型 %s 不能被 InitializeModels 识别: %v", info.Name, err)
+		}
+	}
+
+	models, err := InitializeModels([]config.ModelConfig{{Name: "fake", Type: "mock"}}, nil, logger)
+	if err != nil || len(models) != 1 || models[0].GetName() != "fake" {
+		t.Fatalf("创建模拟模型失败: %v", err)
+	}
+	if _, err := InitializeModels([]config.ModelConfig{{Name: "m", Type: "azure-openai"}}, nil, logger); err == nil || !strings.Contains(err.Error(), "不支持的模型类型") {
+		t.Errorf("未知的模型类型应当报错，实际为 %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// sseEvent 服务端推送事件（text/event-stream）中的一个事件
+type sseEvent struct {
+	Event string // event 字段，未设置时为空
+	Data  string // 多个 data 行以换行符连接
+}
+
+// sseReader 按事件解析SSE流
+// 事件以空行结束，一个事件可以包含多个 data 行；以冒号开头的注释行（例如心跳）会被忽略，
+// 字段名后的冒号后面可以没有空格，行尾可以是 \n、\r\n
+type sseReader struct {
+	r *bufio.Reader
+}
+
+func newSSEReader(r io.Reader) *sseReader {
+	return &sseReader{r: bufio.NewReader(r)}
+}
+
+// Next 读取下一个包含数据的事件，流结束时返回 io.EOF
+// 流在事件中途结束时，已读取的部分作为最后一个事件返回
+func (r *sseReader) Next() (*sseEvent, error) {
+	var event sseEvent
+	var dataLines []string
+	for {
+		line, err := r.r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		eof := err == io.EOF
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			// 空行表示事件结束，没有数据的事件（例如只有 event 字段）直接跳过
+			if len(dataLines) > 0 {
+				event.Data = strings.Join(dataLines, "\n")
+				return &event, nil
+			}
+			if eof {
+				return nil, io.EOF
+			}
+			event = sseEvent{}
+			continue
+		}
+
+		if !strings.HasPrefix(line, ":") {
+			field, value, found := strings.Cut(line, ":")
+			if found {
+				value = strings.TrimPrefix(value, " ")
+			} else if line == "[DONE]" {
+				// 部分兼容服务在流结束时直接发送一行 [DONE]
+				field, value = "data", line
+			}
+			switch field {
+			case "data":
+				dataLines = append(dataLines, value)
+			case "event":
+				event.Event = value
+			}
+		}
+
+		if eof {
+			if len(dataLines) > 0 {
+				event.Data = strings.Join(dataLines, "\n")
+				return &event, nil
+			}
+			return nil, io.EOF
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// chunkReader 每次 Read 只返回一个预先切分好的块，模拟网络上分多次到达的数据
+type chunkReader struct {
+	chunks []string
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks[0] = r.chunks[0][n:]
+	if r.chunks[0] == "" {
+		r.chunks = r.chunks[1:]
+	}
+	return n, nil
+}
+
+func readAllEvents(t *testing.T, r io.Reader) []sseEvent {
+	t.Helper()
+	reader := newSSEReader(r)
+	var events []sseEvent
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("读取事件失败: %v", err)
+		}
+		events = append(events, *event)
+	}
+}
+
+// 事件在任意位置被切分成多次读取（包括 \r\n 之间和字段名中间）时，解析结果与一次读取相同
+func TestSSEReaderEventsSplitAcrossReads(t *testing.T) {
+	stream := ": keep-alive\r\n\r\n" +
+		"event: message_start\r\ndata: {\"a\":1}\r\n\r\n" +
+		"data:{\"b\":\r\ndata: 2}\r\n\r\n" +
+		"event: ping\r\n\r\n" +
+		"data: [DONE]\n\n" +
+		"data: tail"
+	want := []sseEvent{
+		{Event: "message_start", Data: `{"a":1}`},
+		{Data: "{\"b\":\n2}"},
+		{Data: "[DONE]"},
+		{Data: "tail"},
+	}
+
+	if got := readAllEvents(t, strings.NewReader(stream)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("一次读取的事件为 %+v，期望 %+v", got, want)
+	}
+	if got := readAllEvents(t, iotest.OneByteReader(strings.NewReader(stream))); !reflect.DeepEqual(got, want) {
+		t.Errorf("逐字节读取的事件为 %+v，期望 %+v", got, want)
+	}
+
+	// 在 \r 和 \n 之间、字段名中间以及事件的空行之前切分
+	chunks := &chunkReader{chunks: []string{
+		": keep-alive\r", "\n\r\nev", "ent: message_start\r\nda", "ta: {\"a\"", ":1}\r\n", "\r\n",
+		"data:{\"b\":\r\ndata: 2}\r\n\r", "\nevent: ping\r\n\r\ndata: [DO", "NE]\n\ndata: ta", "il",
+	}}
+	if got := readAllEvents(t, chunks); !reflect.DeepEqual(got, want) {
+		t.Errorf("分块读取的事件为 %+v，期望 %+v", got, want)
+	}
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+func init() {
+	// 使用编译进程序的词表，不在运行时从网络下载
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+// 按模型名称前缀选择编码，越具体的前缀越靠前
+var tiktokenModelPrefixes = []struct {
+	prefix   string
+	encoding string
+}{
+	{"gpt-4o", "o200k_base"},
+	{"gpt-4.1", "o200k_base"},
+	{"gpt-4.5", "o200k_base"},
+	{"gpt-5", "o200k_base"},
+	{"o1", "o200k_base"},
+	{"o3", "o200k_base"},
+	{"o4", "o200k_base"},
+	{"chatgpt-4o", "o200k_base"},
+	{"gpt-4", "cl100k_base"},
+	{"gpt-3.5", "cl100k_base"},
+	{"gpt-35", "cl100k_base"},
+	{"text-embedding-3", "cl100k_base"},
+	{"text-embedding-ada-002", "cl100k_base"},
+}
+
+// 已创建的编码器缓存，以模型名称为键，创建编码器需要解析整个词表，每个模型只创建一次
+// 同一编码的模型共用编码器，编码器可以并发使用
+var (
+	tiktokenMutex    sync.Mutex
+	tiktokenModels   = make(map[string]*tiktoken.Tiktoken)
+	tiktokenEncoders = make(map[string]*tiktoken.Tiktoken)
+)
+
+// CountTokensForModel 使用tiktoken的BPE词表计算OpenAI模型的文本token数，模型没有对应的编码时返回错误
+func CountTokensForModel(modelName, text string) (int, error) {
+	enc, err := encoderForModel(modelName)
+	if err != nil {
+		return 0, err
+	}
+	return len(enc.EncodeOrdinary(text)), nil
+}
+
+// encoderForModel 返回模型对应的编码器，首次使用时创建
+func encoderForModel(modelName string) (*tiktoken.Tiktoken, error) {
+	tiktokenMutex.Lock()
+	defer tiktokenMutex.Unlock()
+	if enc, ok := tiktokenModels[modelName]; ok {
+		return enc, nil
+	}
+
+	name := ""
+	lower := strings.ToLower(modelName)
+	for _, p := range tiktokenModelPrefixes {
+		if strings.HasPrefix(lower, p.prefix) {
+			name = p.encoding
+			break
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("模型 %s 没有对应的tiktoken编码", modelName)
+	}
+
+	enc, ok := tiktokenEncoders[name]
+	if !ok {
+		var err error
+		if enc, err = tiktoken.GetEncoding(name); err != nil {
+			return nil, fmt.Errorf("加载tiktoken编码 %s 失败: %w", name, err)
+		}
+		tiktokenEncoders[name] = enc
+	}
+	tiktokenModels[modelName] = enc
+	return enc, nil
+}
@@ -0,0 +1,41 @@
+package model
+
+import "testing"
+
+// 使用内置词表计算token数，结果与OpenAI的tiktoken一致
+func TestCountTokensForModel(t *testing.T) {
+	tests := []struct {
+		model string
+		text  string
+		want  int
+	}{
+		{"gpt-4", "hello world", 2},
+		{"gpt-4o-mini", "hello world", 2},
+		{"gpt-3.5-turbo", "tiktoken is great!", 6},
+		{"gpt-4o", "", 0},
+	}
+	for _, tt := range tests {
+		got, err := CountTokensForModel(tt.model, tt.text)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.model, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s 的 %q 为 %d 个token，期望 %d", tt.model, tt.text, got, tt.want)
+		}
+	}
+
+	enc, err := encoderForModel("gpt-4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens := enc.EncodeOrdinary("hello world"); len(tokens) != 2 || tokens[0] != 15339 || tokens[1] != 1917 {
+		t.Errorf("cl100k_base 编码 hello world 为 %v，期望 [15339 1917]", tokens)
+	}
+	if same, _ := encoderForModel("gpt-4-turbo"); same != enc {
+		t.Error("同一编码的模型应当共用编码器")
+	}
+
+	if _, err := CountTokensForModel("claude-3-haiku", "hello"); err == nil {
+		t.Error("没有对应编码的模型应当返回错误")
+	}
+}
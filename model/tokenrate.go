@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// tokenTimer 记录流式响应中第一个和最后一个包含输出内容的数据块的到达时间，用于计算生成速率
+// 只统计两者之间的时间，不包括首Token之前的排队和预填充时间，也不包括最后一个内容块之后的usage块和连接关闭
+type tokenTimer struct {
+	first time.Time
+	last  time.Time
+}
+
+// observe 收到包含输出内容的数据块时调用
+func (t *tokenTimer) observe() {
+	now := time.Now()
+	if t.first.IsZero() {
+		t.first = now
+	}
+	t.last = now
+}
+
+// tokensPerSecond 返回输出token数除以第一个和最后一个token之间的时间得到的生成速率
+// 第一个token的到达是计时的起点，之后的 outputTokens-1 个token在两者之间生成；
+// 只有一个token、没有输出或者所有内容在同一个数据块中到达时无法计算，返回0
+func (t *tokenTimer) tokensPerSecond(outputTokens int) float64 {
+	elapsed := t.last.Sub(t.first)
+	if outputTokens <= 1 || elapsed <= 0 {
+		return 0
+	}
+	return float64(outputTokens-1) / elapsed.Seconds()
+}
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// connTrace 通过 httptrace 记录一个请求建立连接各阶段的耗时，用于区分网络开销和模型推理时间
+// 拨号时可能同时尝试多个地址，回调可能在不同的协程中执行，需要加锁
+type connTrace struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	dns          time.Duration
+	connect      time.Duration
+	tlsHandshake time.Duration
+	ttfb         time.Duration
+	reused       bool
+}
+
+// traceRequest 返回带有连接耗时跟踪的请求，需要在请求头设置完成（包括签名）后、发送前调用
+func traceRequest(req *http.Request) (*http.Request, *connTrace) {
+	t := &connTrace{start: time.Now()}
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.mu.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.dns = time.Since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		// 同时尝试多个地址时只记录第一个成功建立的连接
+		ConnectDone: func(_, _ string, err error) {
+			t.mu.Lock()
+			if err == nil && t.connect == 0 {
+				t.connect = time.Since(t.connectStart)
+			}
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.tlsHandshake = time.Since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.ttfb = time.Since(t.start)
+			t.mu.Unlock()
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), t
+}
+
+// apply 将记录的耗时写入响应，收到响应头之后调用
+func (t *connTrace) apply(result *LLMResponse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result.DNSTime = t.dns
+	result.ConnectTime = t.connect
+	result.TLSTime = t.tlsHandshake
+	result.TTFB = t.ttfb
+	result.ConnReused = t.reused
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/lemonlinger/llm-test/config"
+)
+
+// 连接池配置应当原样设置到 Transport，max_conns_per_host 为-1时不限制
+func TestNewHTTPTransportConnectionLimits(t *testing.T) {
+	for _, tc := range []struct {
+		maxConnsPerHost int
+		want            int
+	}{
+		{32, 32},
+		{-1, 0},
+		{0, 0},
+	} {
+		transport := newHTTPTransport(config.HTTPConfig{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 32,
+			MaxConnsPerHost:     tc.maxConnsPerHost,
+		}, nil, nil)
+		if transport.MaxIdleConns != 100 || transport.MaxIdleConnsPerHost != 32 {
+			t.Errorf("空闲连接数为 %d/%d，期望 100/32", transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+		}
+		if transport.MaxConnsPerHost != tc.want {
+			t.Errorf("max_conns_per_host 为 %d 时 Transport.MaxConnsPerHost 为 %d，期望 %d",
+				tc.maxConnsPerHost, transport.MaxConnsPerHost, tc.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// APIKeyRecord JSON报告中单个API密钥的请求统计
+type APIKeyRecord struct {
+	// 密钥的序号和最后4个字符，不包含完整的密钥
+	Key      string `json:"key"`
+	Requests int    `json:"requests"`
+	Failed   int    `json:"failed"`
+}
+
+// newAPIKeyRecords 转换每个API密钥的请求统计，只有一个密钥时返回nil
+func newAPIKeyRecords(stats []engine.APIKeyStats) []APIKeyRecord {
+	if len(stats) == 0 {
+		return nil
+	}
+	records := make([]APIKeyRecord, len(stats))
+	for i, s := range stats {
+		records[i] = APIKeyRecord{Key: s.Key, Requests: s.Requests, Failed: s.Failed}
+	}
+	return records
+}
+
+// parseAPIKeyRecords 从JSON报告中的记录恢复每个API密钥的请求统计
+func parseAPIKeyRecords(records []APIKeyRecord) []engine.APIKeyStats {
+	if len(records) == 0 {
+		return nil
+	}
+	stats := make([]engine.APIKeyStats, len(records))
+	for i, record := range records {
+		stats[i] = engine.APIKeyStats{Key: record.Key, Requests: record.Requests, Failed: record.Failed}
+	}
+	return stats
+}
+
+// writeAPIKeys 写入配置了多个API密钥的模型每个密钥的请求数和失败率，便于发现失效或被限流的密钥
+func writeAPIKeys(sb *strings.Builder, results []*engine.TestResult) {
+	hasKeys := false
+	for _, result := range results {
+		if len(result.APIKeys) > 0 {
+			hasKeys = true
+			break
+		}
+	}
+	if !hasKeys {
+		return
+	}
+
+	sb.WriteString("## API密钥\n\n")
+	sb.WriteString("| 模型 | 场景 | 并发度 | 密钥 | 请求数 | 失败数 | 失败率 |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+	for _, result := range results {
+		for _, key := range result.APIKeys {
+			failureRate := 0.0
+			if key.Requests > 0 {
+				failureRate = float64(key.Failed) / float64(key.Requests) * 100
+			}
+			sb.WriteString(fmt.Sprintf("| %s | %s | %d | %s | %d | %d | %.2f%% |\n",
+				result.ModelName,
+				result.Scenario,
+				result.ConcurrencyLevel,
+				key.Key,
+				key.Requests,
+				key.Failed,
+				failureRate))
+		}
+	}
+	sb.WriteString("\n注: 每次请求（包括重试）分别计数，某个密钥的失败率明显高于其他密钥时，该密钥可能已失效或被限流\n\n")
+}
@@ -0,0 +1,29 @@
+package report
+
+import "github.com/lemonlinger/llm-test/engine"
+
+// PromptCacheRecord JSON报告中的提示词缓存Token统计
+type PromptCacheRecord struct {
+	CacheCreationInputTokens    int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens        int64   `json:"cache_read_input_tokens"`
+	AvgCacheCreationInputTokens float64 `json:"avg_cache_creation_input_tokens"`
+	AvgCacheReadInputTokens     float64 `json:"avg_cache_read_input_tokens"`
+}
+
+// newPromptCacheRecord 转换提示词缓存统计，服务端没有返回缓存Token数时返回nil
+func newPromptCacheRecord(result *engine.TestResult) *PromptCacheRecord {
+	if !hasPromptCache(result) {
+		return nil
+	}
+	return &PromptCacheRecord{
+		CacheCreationInputTokens:    result.CacheCreationInputTokens,
+		CacheReadInputTokens:        result.CacheReadInputTokens,
+		AvgCacheCreationInputTokens: result.AvgCacheCreationInputTokens,
+		AvgCacheReadInputTokens:     result.AvgCacheReadInputTokens,
+	}
+}
+
+// hasPromptCache 返回结果中是否有写入或读取提示词缓存的Token
+func hasPromptCache(result *engine.TestResult) bool {
+	return result.CacheCreationInputTokens > 0 || result.CacheReadInputTokens > 0
+}
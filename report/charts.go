@@ -0,0 +1,272 @@
+package report
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/lemonlinger/llm-test/engine"
+	"golang.org/x/image/font/opentype"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// CDF曲线最多保留的数据点数，请求数很多时按等间隔抽样
+const maxCDFPoints = 500
+
+// 图表尺寸
+const (
+	chartWidth  = 8 * vg.Inch
+	chartHeight = 5 * vg.Inch
+)
+
+// 数据系列的配色
+var chartPalette = []color.RGBA{
+	{31, 119, 180, 255},
+	{255, 127, 14, 255},
+	{44, 160, 44, 255},
+	{214, 39, 40, 255},
+	{148, 103, 189, 255},
+	{140, 86, 75, 255},
+	{227, 119, 194, 255},
+	{127, 127, 127, 255},
+	{188, 189, 34, 255},
+	{23, 190, 207, 255},
+}
+
+// SetChartFont 从TTF或OTF文件加载图表使用的字体，未设置时使用 gonum/plot 内置的Liberation字体，
+// 内置字体不包含中日韩文字，模型名称包含这些文字时需要指定包含对应字形的字体
+func (r *Reporter) SetChartFont(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取图表字体失败: %w", err)
+	}
+	face, err := opentype.Parse(data)
+	if err != nil {
+		return fmt.Errorf("解析图表字体 %s 失败: %w", path, err)
+	}
+	typeface := font.Typeface("llm-test:" + path)
+	font.DefaultCache.Add(font.Collection{{Font: font.Font{Typeface: typeface}, Face: face}})
+	r.chartFont = typeface
+	return nil
+}
+
+// chartSeries 折线图中的一条数据系列
+type chartSeries struct {
+	label  string
+	points [][2]float64 // (x, y)
+}
+
+// lineChart 折线图，使用 gonum/plot 绘制
+type lineChart struct {
+	title  string
+	xLabel string
+	yLabel string
+	series []chartSeries
+	// 是否在数据点上绘制标记
+	markers bool
+	// 所有文字使用的字体，为空时使用默认字体
+	typeface font.Typeface
+}
+
+// addSeries 添加数据系列，颜色按添加顺序从调色板中选取
+func (c *lineChart) addSeries(label string, points [][2]float64) {
+	c.series = append(c.series, chartSeries{label: label, points: points})
+}
+
+// plot 创建图表对应的 gonum/plot 图
+func (c *lineChart) plot() (*plot.Plot, error) {
+	p := plot.New()
+	p.Title.Text = c.title
+	p.X.Label.Text = c.xLabel
+	p.Y.Label.Text = c.yLabel
+	p.Legend.Top = true
+	p.Add(plotter.NewGrid())
+	if c.typeface != "" {
+		for _, style := range []*font.Font{
+			&p.Title.TextStyle.Font, &p.Legend.TextStyle.Font,
+			&p.X.Label.TextStyle.Font, &p.X.Tick.Label.Font,
+			&p.Y.Label.TextStyle.Font, &p.Y.Tick.Label.Font,
+		} {
+			style.Typeface, style.Variant = c.typeface, ""
+		}
+	}
+
+	for i, series := range c.series {
+		xys := make(plotter.XYs, len(series.points))
+		for j, point := range series.points {
+			xys[j].X, xys[j].Y = point[0], point[1]
+		}
+		line, err := plotter.NewLine(xys)
+		if err != nil {
+			return nil, err
+		}
+		line.Color = chartPalette[i%len(chartPalette)]
+		line.Width = vg.Points(1.5)
+		p.Add(line)
+		p.Legend.Add(series.label, line)
+
+		if c.markers {
+			scatter, err := plotter.NewScatter(xys)
+			if err != nil {
+				return nil, err
+			}
+			scatter.Color = line.Color
+			scatter.Shape = draw.CircleGlyph{}
+			p.Add(scatter)
+		}
+	}
+	return p, nil
+}
+
+// GenerateCharts 为每个模型（和场景）生成PNG图表，写入 outDir 目录：
+//   - <模型>_latency_cdf.png：各并发度下成功请求的延迟累积分布
+//   - <模型>_rps.png：RPS随并发度变化的折线图，少于两个并发度时跳过
+//   - <模型>_rps_windows.png：配置了吞吐量收敛检测时，各并发度每个窗口的RPS
+func (r *Reporter) GenerateCharts(results map[string]*engine.TestResult, outDir string) error {
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("创建图表目录失败: %w", err)
+	}
+
+	// 收集所有测试结果，按模型和场景分组
+	allResults := make([]*engine.TestResult, 0, len(results))
+	for _, result := range measuredResults(results) {
+		allResults = append(allResults, result)
+	}
+	sortResults(allResults)
+
+	var groupNames []string
+	groups := make(map[string][]*engine.TestResult)
+	for _, result := range allResults {
+		name := result.ModelName
+		if result.Scenario != "" {
+			name += "_" + result.Scenario
+		}
+		if result.StreamMode != "" {
+			name += "_" + result.StreamMode
+		}
+		if _, ok := groups[name]; !ok {
+			groupNames = append(groupNames, name)
+		}
+		groups[name] = append(groups[name], result)
+	}
+
+	for _, name := range groupNames {
+		group := groups[name]
+
+		cdf := &lineChart{
+			title:    name + " latency CDF",
+			xLabel:   "latency (ms)",
+			yLabel:   "CDF (%)",
+			typeface: r.chartFont,
+		}
+		for _, result := range group {
+			if points := latencyCDF(result); len(points) > 0 {
+				cdf.addSeries(fmt.Sprintf("c=%d", result.ConcurrencyLevel), points)
+			}
+		}
+		if len(cdf.series) > 0 {
+			if err := writeChart(cdf, outDir, name+"_latency_cdf.png"); err != nil {
+				return err
+			}
+		}
+
+		windows := &lineChart{
+			title:    name + " RPS per window",
+			xLabel:   "window",
+			yLabel:   "RPS",
+			typeface: r.chartFont,
+		}
+		for _, result := range group {
+			if len(result.RPSWindows) == 0 {
+				continue
+			}
+			points := make([][2]float64, len(result.RPSWindows))
+			for i, v := range result.RPSWindows {
+				points[i] = [2]float64{float64(i + 1), v}
+			}
+			windows.addSeries(fmt.Sprintf("c=%d", result.ConcurrencyLevel), points)
+		}
+		if len(windows.series) > 0 {
+			if err := writeChart(windows, outDir, name+"_rps_windows.png"); err != nil {
+				return err
+			}
+		}
+
+		if len(group) < 2 {
+			continue
+		}
+		rps := &lineChart{
+			title:    name + " RPS vs concurrency",
+			xLabel:   "concurrency",
+			yLabel:   "RPS",
+			markers:  true,
+			typeface: r.chartFont,
+		}
+		points := make([][2]float64, 0, len(group))
+		for _, result := range group {
+			points = append(points, [2]float64{float64(result.ConcurrencyLevel), result.RequestsPerSec})
+		}
+		rps.addSeries("RPS", points)
+		if err := writeChart(rps, outDir, name+"_rps.png"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// latencyCDF 计算延迟(毫秒)的累积分布曲线
+func latencyCDF(result *engine.TestResult) [][2]float64 {
+	n := len(result.AllLatencies)
+	if n == 0 {
+		return nil
+	}
+	latencies := make([]float64, n)
+	for i, latency := range result.AllLatencies {
+		latencies[i] = float64(latency.Microseconds()) / 1000
+	}
+	sort.Float64s(latencies)
+
+	step := 1
+	if n > maxCDFPoints {
+		step = n / maxCDFPoints
+	}
+	points := make([][2]float64, 0, n/step+1)
+	for i := 0; i < n; i += step {
+		points = append(points, [2]float64{latencies[i], float64(i+1) / float64(n) * 100})
+	}
+	if last := points[len(points)-1]; last[1] < 100 {
+		points = append(points, [2]float64{latencies[n-1], 100})
+	}
+	return points
+}
+
+// writeChart 渲染图表并写入PNG文件
+func writeChart(chart *lineChart, outDir, name string) error {
+	p, err := chart.plot()
+	if err != nil {
+		return fmt.Errorf("生成图表失败: %w", err)
+	}
+	if err := p.Save(chartWidth, chartHeight, filepath.Join(outDir, sanitizeFileName(name))); err != nil {
+		return fmt.Errorf("保存图表失败: %w", err)
+	}
+	return nil
+}
+
+// sanitizeFileName 将文件名中的路径分隔符等非法字符替换为下划线，保留非ASCII的字母和数字
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
+}
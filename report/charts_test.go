@@ -0,0 +1,90 @@
+package report
+
+import (
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lemonlinger/llm-test/engine"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func chartTestResult(modelName string, concurrency int, rps float64) *engine.TestResult {
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[i] = time.Duration(50+i) * time.Millisecond
+	}
+	return &engine.TestResult{
+		ModelName:        modelName,
+		ConcurrencyLevel: concurrency,
+		TotalRequests:    100,
+		SuccessRequests:  100,
+		RequestsPerSec:   rps,
+		AllLatencies:     latencies,
+		ErrorsByCategory: map[string]int{},
+	}
+}
+
+// 每个模型生成延迟分布图，只有一个并发度的模型不生成RPS折线图，非ASCII的模型名称保留在文件名中
+func TestGenerateCharts(t *testing.T) {
+	results := map[string]*engine.TestResult{}
+	for _, result := range []*engine.TestResult{
+		chartTestResult("gpt-4o", 1, 10),
+		chartTestResult("gpt-4o", 4, 35),
+		chartTestResult("通义千问", 1, 8),
+	} {
+		results[result.Key()] = result
+	}
+
+	dir := t.TempDir()
+	if err := NewReporter("text").GenerateCharts(results, dir); err != nil {
+		t.Fatalf("生成图表失败: %v", err)
+	}
+
+	for _, name := range []string{"gpt-4o_latency_cdf.png", "gpt-4o_rps.png", "通义千问_latency_cdf.png"} {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("缺少图表 %s: %v", name, err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s 不是有效的PNG: %v", name, err)
+		}
+		if img.Bounds().Dx() == 0 || img.Bounds().Dy() == 0 {
+			t.Errorf("%s 的尺寸为空", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "通义千问_rps.png")); !os.IsNotExist(err) {
+		t.Error("只有一个并发度时不应生成RPS折线图")
+	}
+}
+
+// 指定的字体文件用于图表中的文字，无效的字体文件返回错误
+func TestSetChartFont(t *testing.T) {
+	dir := t.TempDir()
+	fontFile := filepath.Join(dir, "go.ttf")
+	if err := os.WriteFile(fontFile, goregular.TTF, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reporter := NewReporter("text")
+	if err := reporter.SetChartFont(fontFile); err != nil {
+		t.Fatalf("加载字体失败: %v", err)
+	}
+	results := map[string]*engine.TestResult{}
+	result := chartTestResult("gpt-4o", 1, 10)
+	results[result.Key()] = result
+	if err := reporter.GenerateCharts(results, filepath.Join(dir, "charts")); err != nil {
+		t.Fatalf("使用指定字体生成图表失败: %v", err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.ttf")
+	if err := os.WriteFile(invalid, []byte("not a font"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewReporter("text").SetChartFont(invalid); err == nil {
+		t.Error("无效的字体文件应当返回错误")
+	}
+}
@@ -0,0 +1,72 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// ConnectionRecord JSON报告中通过 httptrace 记录的连接耗时，DNS、TCP连接和TLS握手耗时为新建连接的请求的平均值
+type ConnectionRecord struct {
+	NewConnRequests int     `json:"new_conn_requests"`
+	AvgDNSMs        float64 `json:"avg_dns_ms"`
+	AvgConnectMs    float64 `json:"avg_connect_ms"`
+	AvgTLSMs        float64 `json:"avg_tls_ms"`
+	AvgTTFBMs       float64 `json:"avg_ttfb_ms"`
+}
+
+// newConnectionRecord 转换连接耗时统计，模型没有记录连接耗时时返回nil
+func newConnectionRecord(result *engine.TestResult, latency LatencyFormat) *ConnectionRecord {
+	if result.AvgTTFB == 0 {
+		return nil
+	}
+	return &ConnectionRecord{
+		NewConnRequests: result.NewConnRequests,
+		AvgDNSMs:        latency.jsonMillis(result.AvgDNSTime),
+		AvgConnectMs:    latency.jsonMillis(result.AvgConnectTime),
+		AvgTLSMs:        latency.jsonMillis(result.AvgTLSTime),
+		AvgTTFBMs:       latency.jsonMillis(result.AvgTTFB),
+	}
+}
+
+// writeConnectionTimings 写入每个结果的连接建立耗时和TTFB，用于区分网络开销和模型推理时间，没有记录连接耗时时不输出
+func writeConnectionTimings(sb *strings.Builder, results []*engine.TestResult, latency LatencyFormat) {
+	hasTimings := false
+	for _, result := range results {
+		if result.AvgTTFB > 0 {
+			hasTimings = true
+			break
+		}
+	}
+	if !hasTimings {
+		return
+	}
+
+	sb.WriteString("## 连接耗时\n\n")
+	sb.WriteString("| 模型 | 场景 | 并发度 | 新建连接/成功请求 | 平均DNS解析 | 平均TCP连接 | 平均TLS握手 | 平均TTFB | 平均延迟 |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- | --- | --- | --- | --- |\n")
+	for _, result := range results {
+		if result.AvgTTFB == 0 {
+			continue
+		}
+		dns, connect, tlsHandshake := "-", "-", "-"
+		if result.NewConnRequests > 0 {
+			dns = latency.Format(result.AvgDNSTime)
+			connect = latency.Format(result.AvgConnectTime)
+			tlsHandshake = latency.Format(result.AvgTLSTime)
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s | %d | %d/%d | %s | %s | %s | %s | %s |\n",
+			result.ModelName,
+			result.Scenario,
+			result.ConcurrencyLevel,
+			result.NewConnRequests,
+			result.SuccessRequests-result.DiscardedRequests,
+			dns,
+			connect,
+			tlsHandshake,
+			latency.Format(result.AvgTTFB),
+			latency.Format(result.AvgLatency)))
+	}
+	sb.WriteString("\n注: DNS解析、TCP连接和TLS握手耗时为新建连接的请求的平均值，TTFB为从发送请求到收到响应第一个字节的时间；新建连接数接近请求数时说明连接没有被复用，可以检查 http 连接池配置\n\n")
+}
@@ -0,0 +1,85 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// 文本报告中每个结果最多显示的窗口RPS个数，完整序列见JSON报告
+const maxReportedWindows = 20
+
+// ConvergenceRecord JSON报告中的吞吐量收敛检测结果
+type ConvergenceRecord struct {
+	Converged bool `json:"converged"`
+	// 从测试开始到吞吐量稳定的时间，未收敛时为0
+	TimeToSteadyStateMs int64     `json:"time_to_steady_state_ms"`
+	RPSWindows          []float64 `json:"rps_windows"`
+}
+
+// newConvergenceRecord 转换吞吐量收敛检测结果，未配置收敛检测（没有窗口数据）时返回nil
+func newConvergenceRecord(result *engine.TestResult) *ConvergenceRecord {
+	if len(result.RPSWindows) == 0 {
+		return nil
+	}
+	return &ConvergenceRecord{
+		Converged:           result.Converged,
+		TimeToSteadyStateMs: result.TimeToSteadyState.Milliseconds(),
+		RPSWindows:          result.RPSWindows,
+	}
+}
+
+// writeConvergence 写入每个结果的吞吐量收敛情况，稳定时间按报告的延迟格式显示，没有窗口数据时不输出
+func writeConvergence(sb *strings.Builder, results []*engine.TestResult, latency LatencyFormat) {
+	hasWindows := false
+	for _, result := range results {
+		if len(result.RPSWindows) > 0 {
+			hasWindows = true
+			break
+		}
+	}
+	if !hasWindows {
+		return
+	}
+
+	sb.WriteString("## 吞吐量收敛\n\n")
+	sb.WriteString("| 模型 | 场景 | 并发度 | 窗口数 | 稳定时间 | 窗口RPS |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	notConverged := false
+	for _, result := range results {
+		if len(result.RPSWindows) == 0 {
+			continue
+		}
+
+		steady := "未收敛"
+		if result.Converged {
+			steady = latency.Format(result.TimeToSteadyState)
+		} else {
+			notConverged = true
+		}
+
+		windows := result.RPSWindows
+		suffix := ""
+		if len(windows) > maxReportedWindows {
+			windows = windows[:maxReportedWindows]
+			suffix = ", ..."
+		}
+		rps := make([]string, len(windows))
+		for i, v := range windows {
+			rps[i] = fmt.Sprintf("%.2f", v)
+		}
+
+		sb.WriteString(fmt.Sprintf("| %s | %s | %d | %d | %s | %s%s |\n",
+			result.ModelName,
+			result.Scenario,
+			result.ConcurrencyLevel,
+			len(result.RPSWindows),
+			steady,
+			strings.Join(rps, ", "), suffix))
+	}
+	sb.WriteString("\n")
+	if notConverged {
+		sb.WriteString("注: 未收敛的并发度在测试期间吞吐量仍在变化，可以适当增加测试时长\n\n")
+	}
+}
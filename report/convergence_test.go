@@ -0,0 +1,31 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// 吞吐量收敛的稳定时间应当使用报告配置的延迟单位和小数位数
+func TestConvergenceUsesLatencyFormat(t *testing.T) {
+	results := []*engine.TestResult{{
+		ModelName:         "gpt-4o",
+		ConcurrencyLevel:  4,
+		RPSWindows:        []float64{10, 12, 12},
+		Converged:         true,
+		TimeToSteadyState: 1500 * time.Millisecond,
+	}}
+	latency := LatencyFormat{Unit: "ms", Precision: 0}
+
+	var sb strings.Builder
+	writeConvergence(&sb, results, latency)
+	want := latency.Format(1500 * time.Millisecond)
+	if !strings.Contains(sb.String(), "| "+want+" |") {
+		t.Fatalf("稳定时间应当显示为 %s，实际报告:\n%s", want, sb.String())
+	}
+	if strings.Contains(sb.String(), DefaultLatencyFormat.Format(1500*time.Millisecond)) {
+		t.Fatalf("稳定时间不应使用默认的延迟格式，实际报告:\n%s", sb.String())
+	}
+}
@@ -0,0 +1,156 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// diffKey 用于匹配基线和当前结果（模型名称+场景+并发度+对比流式模式）
+type diffKey struct {
+	modelName   string
+	scenario    string
+	concurrency int
+	streamMode  string
+}
+
+// GenerateDiffReport 生成当前结果与基线结果的对比报告
+// 按模型名称、场景和并发度匹配结果，输出各项指标的变化量和变化百分比
+func (r *Reporter) GenerateDiffReport(baseline, current map[string]*engine.TestResult) (string, error) {
+	baselineByKey := indexResults(baseline)
+	currentByKey := indexResults(current)
+
+	// 收集两边出现过的所有键
+	keys := make([]diffKey, 0, len(currentByKey))
+	for key := range currentByKey {
+		keys = append(keys, key)
+	}
+	for key := range baselineByKey {
+		if _, ok := currentByKey[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	// 按模型名称、场景和并发度排序
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].modelName != keys[j].modelName {
+			return keys[i].modelName < keys[j].modelName
+		}
+		if keys[i].scenario != keys[j].scenario {
+			return keys[i].scenario < keys[j].scenario
+		}
+		if keys[i].concurrency != keys[j].concurrency {
+			return keys[i].concurrency < keys[j].concurrency
+		}
+		return keys[i].streamMode < keys[j].streamMode
+	})
+
+	var sb strings.Builder
+	sb.WriteString("# LLM API 性能对比报告\n\n")
+	sb.WriteString("每个单元格格式为：当前值 (变化量, 变化百分比)\n\n")
+
+	sb.WriteString("| 模型 | 并发度 | RPS | TPS | 平均延迟 | P99 | 成功率 |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+
+	for _, key := range keys {
+		base, hasBase := baselineByKey[key]
+		cur, hasCur := currentByKey[key]
+
+		modelLabel := key.modelName
+		if key.scenario != "" {
+			modelLabel = fmt.Sprintf("%s (%s)", key.modelName, key.scenario)
+		}
+		if key.streamMode != "" {
+			modelLabel += " [" + key.streamMode + "]"
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %d", modelLabel, key.concurrency))
+
+		switch {
+		case !hasBase:
+			sb.WriteString(" | 新增 | - | - | - | - |\n")
+			continue
+		case !hasCur:
+			sb.WriteString(" | 缺失 | - | - | - | - |\n")
+			continue
+		}
+
+		sb.WriteString(" | " + formatFloatDiff(base.RequestsPerSec, cur.RequestsPerSec))
+		sb.WriteString(" | " + formatFloatDiff(base.TokensPerSec, cur.TokensPerSec))
+		sb.WriteString(" | " + formatDurationDiff(base.AvgLatency, cur.AvgLatency, r.latency))
+
+		baseP99, baseOK := base.LatencyPercentiles[99]
+		curP99, curOK := cur.LatencyPercentiles[99]
+		if baseOK && curOK {
+			sb.WriteString(" | " + formatDurationDiff(baseP99, curP99, r.latency))
+		} else {
+			sb.WriteString(" | -")
+		}
+
+		sb.WriteString(" | " + formatSuccessRateDiff(base, cur))
+		sb.WriteString(" |\n")
+	}
+
+	sb.WriteString("\n")
+
+	return sb.String(), nil
+}
+
+// 按模型名称、场景和并发度为结果建立索引
+func indexResults(results map[string]*engine.TestResult) map[diffKey]*engine.TestResult {
+	index := make(map[diffKey]*engine.TestResult, len(results))
+	for _, result := range results {
+		// 测试出错的模型没有可比较的指标
+		if result.ModelError != "" {
+			continue
+		}
+		key := diffKey{
+			modelName:   result.ModelName,
+			scenario:    result.Scenario,
+			concurrency: result.ConcurrencyLevel,
+			streamMode:  result.StreamMode,
+		}
+		index[key] = result
+	}
+	return index
+}
+
+// 格式化数值指标的变化
+func formatFloatDiff(base, cur float64) string {
+	return fmt.Sprintf("%.2f (%+.2f, %s)", cur, cur-base, formatPercentChange(base, cur))
+}
+
+// 格式化延迟指标的变化
+func formatDurationDiff(base, cur time.Duration, latency LatencyFormat) string {
+	delta := cur - base
+	sign := "+"
+	if delta < 0 {
+		sign = "-"
+		delta = -delta
+	}
+	return fmt.Sprintf("%s (%s%s, %s)", latency.Format(cur), sign, latency.Format(delta),
+		formatPercentChange(float64(base), float64(cur)))
+}
+
+// 格式化成功率的变化，变化量以百分点表示
+func formatSuccessRateDiff(base, cur *engine.TestResult) string {
+	baseRate := 0.0
+	if base.TotalRequests > 0 {
+		baseRate = float64(base.SuccessRequests) / float64(base.TotalRequests) * 100
+	}
+	curRate := 0.0
+	if cur.TotalRequests > 0 {
+		curRate = float64(cur.SuccessRequests) / float64(cur.TotalRequests) * 100
+	}
+	return fmt.Sprintf("%.2f%% (%+.2f, %s)", curRate, curRate-baseRate, formatPercentChange(baseRate, curRate))
+}
+
+// 计算变化百分比，基线为0时无法计算
+func formatPercentChange(base, cur float64) string {
+	if base == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.2f%%", (cur-base)/base*100)
+}
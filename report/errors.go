@@ -0,0 +1,73 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// ErrorCountRecord JSON报告中相同错误信息的失败请求数
+type ErrorCountRecord struct {
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// newErrorCountRecords 转换出现次数最多的错误，没有失败请求时返回nil
+func newErrorCountRecords(errs []engine.ErrorCount) []ErrorCountRecord {
+	if len(errs) == 0 {
+		return nil
+	}
+	records := make([]ErrorCountRecord, len(errs))
+	for i, e := range errs {
+		records[i] = ErrorCountRecord{Message: e.Message, Count: e.Count}
+	}
+	return records
+}
+
+// parseErrorCountRecords 从JSON报告中的记录恢复出现次数最多的错误
+func parseErrorCountRecords(records []ErrorCountRecord) []engine.ErrorCount {
+	if len(records) == 0 {
+		return nil
+	}
+	errs := make([]engine.ErrorCount, len(records))
+	for i, record := range records {
+		errs[i] = engine.ErrorCount{Message: record.Message, Count: record.Count}
+	}
+	return errs
+}
+
+// writeTopErrors 写入每个结果中出现次数最多的错误及次数，比逐条列出所有失败更容易看出主要原因，没有失败请求时不输出
+func writeTopErrors(sb *strings.Builder, results []*engine.TestResult) {
+	hasErrors := false
+	for _, result := range results {
+		if len(result.TopErrors) > 0 {
+			hasErrors = true
+			break
+		}
+	}
+	if !hasErrors {
+		return
+	}
+
+	sb.WriteString("## 常见错误\n\n")
+	for _, result := range results {
+		if len(result.TopErrors) == 0 {
+			continue
+		}
+		title := result.ModelName
+		if result.Scenario != "" {
+			title += " / " + result.Scenario
+		}
+		if result.StreamMode != "" {
+			title += " / " + result.StreamMode
+		}
+		sb.WriteString(fmt.Sprintf("### %s (并发度 %d，%d 个失败请求)\n\n", title, result.ConcurrencyLevel, result.FailedRequests))
+		sb.WriteString("| 次数 | 错误 |\n")
+		sb.WriteString("| --- | --- |\n")
+		for _, e := range result.TopErrors {
+			sb.WriteString(fmt.Sprintf("| %d | %s |\n", e.Count, strings.ReplaceAll(e.Message, "|", "\\|")))
+		}
+		sb.WriteString("\n")
+	}
+}
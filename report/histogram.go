@@ -0,0 +1,113 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// 文本报告中直方图柱的最大宽度
+const histogramBarWidth = 40
+
+// 超过最后一个桶上界的桶在报告中的表示
+const overflowBound = "+Inf"
+
+// sortedBounds 返回直方图按从小到大排序的桶上界
+func sortedBounds(histogram map[time.Duration]int) []time.Duration {
+	bounds := make([]time.Duration, 0, len(histogram))
+	for bound := range histogram {
+		bounds = append(bounds, bound)
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	return bounds
+}
+
+// newHistogramBuckets 将延迟直方图转换为JSON报告中的桶列表
+func newHistogramBuckets(histogram map[time.Duration]int) []HistogramBucket {
+	if len(histogram) == 0 {
+		return nil
+	}
+	buckets := make([]HistogramBucket, 0, len(histogram))
+	for _, bound := range sortedBounds(histogram) {
+		upperBound := overflowBound
+		if bound != engine.LatencyHistogramOverflow {
+			upperBound = bound.String()
+		}
+		buckets = append(buckets, HistogramBucket{UpperBound: upperBound, Count: histogram[bound]})
+	}
+	return buckets
+}
+
+// parseHistogramBuckets 从JSON报告中的桶列表恢复延迟直方图
+func parseHistogramBuckets(buckets []HistogramBucket) (map[time.Duration]int, error) {
+	histogram := make(map[time.Duration]int, len(buckets))
+	for _, bucket := range buckets {
+		bound := engine.LatencyHistogramOverflow
+		if bucket.UpperBound != overflowBound {
+			d, err := time.ParseDuration(bucket.UpperBound)
+			if err != nil {
+				return nil, err
+			}
+			bound = d
+		}
+		histogram[bound] = bucket.Count
+	}
+	return histogram, nil
+}
+
+// 写入每个测试结果的延迟分布柱状图，没有直方图时不输出
+func writeLatencyHistograms(sb *strings.Builder, results []*engine.TestResult) {
+	hasHistogram := false
+	for _, result := range results {
+		if len(result.LatencyHistogram) > 0 {
+			hasHistogram = true
+			break
+		}
+	}
+	if !hasHistogram {
+		return
+	}
+
+	sb.WriteString("## 延迟分布\n\n")
+	for _, result := range results {
+		if len(result.LatencyHistogram) == 0 {
+			continue
+		}
+
+		title := result.ModelName
+		if result.Scenario != "" {
+			title += " / " + result.Scenario
+		}
+		sb.WriteString(fmt.Sprintf("### %s (并发度 %d)\n\n", title, result.ConcurrencyLevel))
+
+		bounds := sortedBounds(result.LatencyHistogram)
+		maxCount := 0
+		for _, count := range result.LatencyHistogram {
+			maxCount = max(maxCount, count)
+		}
+
+		sb.WriteString("```\n")
+		var previous time.Duration
+		for _, bound := range bounds {
+			label := "≤ " + bound.String()
+			if bound == engine.LatencyHistogramOverflow {
+				label = "> " + previous.String()
+			}
+			count := result.LatencyHistogram[bound]
+			width := 0
+			if maxCount > 0 {
+				width = count * histogramBarWidth / maxCount
+			}
+			if count > 0 && width == 0 {
+				// 有请求的桶至少显示一格，与空桶区分
+				width = 1
+			}
+			sb.WriteString(fmt.Sprintf("%9s | %-*s %d\n", label, histogramBarWidth, strings.Repeat("█", width), count))
+			previous = bound
+		}
+		sb.WriteString("```\n\n")
+	}
+}
@@ -0,0 +1,112 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// InputLengthRecord JSON报告中一个输入Token数区间的延迟统计，max_tokens 为0表示超过最后一个区间上界
+type InputLengthRecord struct {
+	MinTokens      int     `json:"min_tokens"`
+	MaxTokens      int     `json:"max_tokens"`
+	Requests       int     `json:"requests"`
+	AvgInputTokens float64 `json:"avg_input_tokens"`
+	AvgLatencyMs   float64 `json:"avg_latency_ms"`
+	P50LatencyMs   float64 `json:"p50_latency_ms"`
+	P95LatencyMs   float64 `json:"p95_latency_ms"`
+	AvgTTFTMs      float64 `json:"avg_ttft_ms,omitempty"`
+}
+
+// newInputLengthRecords 转换按输入Token数区间的延迟统计，场景未配置 prompt_length 时返回nil
+func newInputLengthRecords(stats []engine.InputLengthStats, latency LatencyFormat) []InputLengthRecord {
+	if len(stats) == 0 {
+		return nil
+	}
+	records := make([]InputLengthRecord, len(stats))
+	for i, s := range stats {
+		records[i] = InputLengthRecord{
+			MinTokens:      s.MinTokens,
+			MaxTokens:      s.MaxTokens,
+			Requests:       s.Requests,
+			AvgInputTokens: s.AvgInputTokens,
+			AvgLatencyMs:   latency.jsonMillis(s.AvgLatency),
+			P50LatencyMs:   latency.jsonMillis(s.P50Latency),
+			P95LatencyMs:   latency.jsonMillis(s.P95Latency),
+			AvgTTFTMs:      latency.jsonMillis(s.AvgTimeToFirstToken),
+		}
+	}
+	return records
+}
+
+// parseInputLengthRecords 从JSON报告中的记录恢复按输入Token数区间的延迟统计
+func parseInputLengthRecords(records []InputLengthRecord) []engine.InputLengthStats {
+	if len(records) == 0 {
+		return nil
+	}
+	stats := make([]engine.InputLengthStats, len(records))
+	for i, record := range records {
+		stats[i] = engine.InputLengthStats{
+			MinTokens:           record.MinTokens,
+			MaxTokens:           record.MaxTokens,
+			Requests:            record.Requests,
+			AvgInputTokens:      record.AvgInputTokens,
+			AvgLatency:          millisToDuration(record.AvgLatencyMs),
+			P50Latency:          millisToDuration(record.P50LatencyMs),
+			P95Latency:          millisToDuration(record.P95LatencyMs),
+			AvgTimeToFirstToken: millisToDuration(record.AvgTTFTMs),
+		}
+	}
+	return stats
+}
+
+// inputLengthLabel 返回输入Token数区间在报告中的表示
+func inputLengthLabel(s engine.InputLengthStats) string {
+	switch {
+	case s.MaxTokens == 0:
+		return fmt.Sprintf("> %d", s.MinTokens)
+	case s.MinTokens == 0:
+		return fmt.Sprintf("≤ %d", s.MaxTokens)
+	default:
+		return fmt.Sprintf("%d - %d", s.MinTokens+1, s.MaxTokens)
+	}
+}
+
+// writeInputLengths 写入配置了 prompt_length 的场景按输入Token数区间统计的延迟，用于观察延迟随输入长度的变化
+func writeInputLengths(sb *strings.Builder, results []*engine.TestResult, latency LatencyFormat) {
+	hasInputLengths := false
+	for _, result := range results {
+		if len(result.InputLengths) > 0 {
+			hasInputLengths = true
+			break
+		}
+	}
+	if !hasInputLengths {
+		return
+	}
+
+	sb.WriteString("## 按输入长度的延迟\n\n")
+	sb.WriteString("| 模型 | 场景 | 并发度 | 输入Token数 | 请求数 | 平均输入Token | 平均延迟 | P50延迟 | P95延迟 | 平均首Token时间 |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- | --- | --- | --- | --- | --- |\n")
+	for _, result := range results {
+		for _, s := range result.InputLengths {
+			ttft := "-"
+			if s.AvgTimeToFirstToken > 0 {
+				ttft = latency.Format(s.AvgTimeToFirstToken)
+			}
+			sb.WriteString(fmt.Sprintf("| %s | %s | %d | %s | %d | %.0f | %s | %s | %s | %s |\n",
+				result.ModelName,
+				result.Scenario,
+				result.ConcurrencyLevel,
+				inputLengthLabel(s),
+				s.Requests,
+				s.AvgInputTokens,
+				latency.Format(s.AvgLatency),
+				latency.Format(s.P50Latency),
+				latency.Format(s.P95Latency),
+				ttft))
+		}
+	}
+	sb.WriteString("\n注: 输入Token数优先使用服务端返回的值，未返回时使用按 prompt_length 抽取的目标值；只列出有成功请求的区间\n\n")
+}
@@ -0,0 +1,26 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// StreamResults 将通道中每个已完成的测试结果以JSON Lines格式写入w，直到通道关闭
+// 写入失败后仍会继续读取通道以免阻塞测试引擎，并返回第一个错误
+func (r *Reporter) StreamResults(w io.Writer, resultCh <-chan *engine.TestResult) error {
+	encoder := json.NewEncoder(w)
+
+	var firstErr error
+	for result := range resultCh {
+		if firstErr != nil {
+			continue
+		}
+		if err := encoder.Encode(newResultRecord(result, r.latency)); err != nil {
+			firstErr = fmt.Errorf("写入JSON Lines结果失败: %w", err)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// LoadResults 从之前保存的JSON报告中读取测试结果
+func LoadResults(file string) (map[string]*engine.TestResult, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("读取JSON报告失败: %w", err)
+	}
+
+	var report JSONReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("解析JSON报告失败: %w", err)
+	}
+
+	results := make(map[string]*engine.TestResult, len(report.TestResults))
+	for _, record := range report.TestResults {
+		result := &engine.TestResult{
+			ModelName:              record.ModelName,
+			Scenario:               record.Scenario,
+			StreamMode:             record.StreamMode,
+			ConcurrencyLevel:       record.ConcurrencyLevel,
+			IsKnee:                 record.IsKnee,
+			ConcurrencyCap:         record.ConcurrencyCap,
+			PeakConcurrency:        record.PeakConcurrency,
+			AvgConcurrency:         record.AvgConcurrency,
+			TotalRequests:          record.TotalRequests,
+			SuccessRequests:        record.SuccessRequests,
+			FailedRequests:         record.FailedRequests,
+			AvgLatency:             millisToDuration(record.AvgLatencyMs),
+			AvgTimeToFirstToken:    millisToDuration(record.AvgTTFTMs),
+			MinLatency:             millisToDuration(record.MinLatencyMs),
+			MaxLatency:             millisToDuration(record.MaxLatencyMs),
+			StdDevLatency:          millisToDuration(record.StdDevLatencyMs),
+			AvgInputTokens:         record.AvgInputTokens,
+			AvgOutputTokens:        record.AvgOutputTokens,
+			AvgTotalTokens:         record.AvgTotalTokens,
+			RequestsPerSec:         record.RequestsPerSec,
+			TokensPerSec:           record.TokensPerSec,
+			ResponseBytes:          record.ResponseBytes,
+			AvgResponseBytes:       record.AvgResponseBytes,
+			BytesPerSec:            record.BytesPerSec,
+			EstimatedTokenRequests: record.EstimatedTokenRequests,
+			StoppedRequests:        record.StoppedRequests,
+			TruncatedRequests:      record.TruncatedRequests,
+			NearTimeoutRequests:    record.NearTimeoutRequests,
+			ToolCallRequests:       record.ToolCallRequests,
+			SchemaValidRequests:    record.SchemaValidRequests,
+			SchemaInvalidRequests:  record.SchemaInvalidRequests,
+			SchemaErrors:           record.SchemaErrors,
+			ReasoningTokens:        record.ReasoningTokens,
+			AvgReasoningTokens:     record.AvgReasoningTokens,
+			AvgTokensPerSecond:     record.AvgTokensPerSecond,
+			ResponseFormat:         record.ResponseFormat,
+			DiscardedRequests:      record.DiscardedRequests,
+			Incomplete:             record.Incomplete,
+			ModelError:             record.ModelError,
+			TotalCost:              record.TotalCost,
+			AvgCostPerRequest:      record.AvgCostPerRequest,
+			ErrorsByCategory:       record.ErrorsByCategory,
+			TopErrors:              parseErrorCountRecords(record.TopErrors),
+			SampleResponses:        record.SampleResponses,
+			RetriedRequests:        record.RetriedRequests,
+			RateLimit:              parseRateLimitRecord(record.RateLimit),
+			APIKeys:                parseAPIKeyRecords(record.APIKeys),
+			Proxies:                parseProxyRecords(record.Proxies),
+			InputLengths:           parseInputLengthRecords(record.InputLengths),
+		}
+
+		if cache := record.PromptCache; cache != nil {
+			result.CacheCreationInputTokens = cache.CacheCreationInputTokens
+			result.CacheReadInputTokens = cache.CacheReadInputTokens
+			result.AvgCacheCreationInputTokens = cache.AvgCacheCreationInputTokens
+			result.AvgCacheReadInputTokens = cache.AvgCacheReadInputTokens
+		}
+		if connection := record.Connection; connection != nil {
+			result.NewConnRequests = connection.NewConnRequests
+			result.AvgDNSTime = millisToDuration(connection.AvgDNSMs)
+			result.AvgConnectTime = millisToDuration(connection.AvgConnectMs)
+			result.AvgTLSTime = millisToDuration(connection.AvgTLSMs)
+			result.AvgTTFB = millisToDuration(connection.AvgTTFBMs)
+		}
+		if record.Convergence != nil {
+			result.RPSWindows = record.Convergence.RPSWindows
+			result.Converged = record.Convergence.Converged
+			result.TimeToSteadyState = time.Duration(record.Convergence.TimeToSteadyStateMs) * time.Millisecond
+		}
+		if len(record.LatencyHistogram) > 0 {
+			histogram, err := parseHistogramBuckets(record.LatencyHistogram)
+			if err != nil {
+				return nil, fmt.Errorf("解析模型 %s 的延迟直方图失败: %w", record.ModelName, err)
+			}
+			result.LatencyHistogram = histogram
+		}
+
+		if len(record.Percentiles) > 0 {
+			result.LatencyPercentiles = make(map[int]time.Duration, len(record.Percentiles))
+			for _, p := range record.Percentiles {
+				result.LatencyPercentiles[p.Percentile] = millisToDuration(p.LatencyMs)
+			}
+		}
+		if len(record.TTFTPercentiles) > 0 {
+			result.TTFTPercentiles = make(map[int]time.Duration, len(record.TTFTPercentiles))
+			for _, p := range record.TTFTPercentiles {
+				result.TTFTPercentiles[p.Percentile] = millisToDuration(p.TTFTMs)
+			}
+		}
+
+		results[result.Key()] = result
+	}
+
+	return results, nil
+}
+
+// millisToDuration 将JSON报告中以毫秒为单位的延迟转换为 time.Duration，延迟可以带小数
+func millisToDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,53 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// 同一模型和并发度的多个场景在保存后重新加载时应当保持各自的键，不能合并成一个结果
+func TestLoadResultsKeepsScenarios(t *testing.T) {
+	results := map[string]*engine.TestResult{}
+	for _, scenario := range []string{"short", "long"} {
+		result := &engine.TestResult{
+			ModelName:        "gpt-4o",
+			Scenario:         scenario,
+			ConcurrencyLevel: 8,
+			TotalRequests:    10,
+			SuccessRequests:  10,
+			AvgLatency:       100 * time.Millisecond,
+			ErrorsByCategory: map[string]int{},
+		}
+		results[result.Key()] = result
+	}
+
+	data, err := NewReporter("json").GenerateResultsReport(results)
+	if err != nil {
+		t.Fatalf("生成JSON报告失败: %v", err)
+	}
+	file := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadResults(file)
+	if err != nil {
+		t.Fatalf("LoadResults 失败: %v", err)
+	}
+	if len(loaded) != len(results) {
+		t.Fatalf("加载了 %d 个结果，期望 %d 个", len(loaded), len(results))
+	}
+	for key, want := range results {
+		got, ok := loaded[key]
+		if !ok {
+			t.Fatalf("加载的结果中缺少 %s", key)
+		}
+		if got.Scenario != want.Scenario {
+			t.Errorf("%s 的场景为 %q，期望 %q", key, got.Scenario, want.Scenario)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lemonlinger/llm-test/config"
+	"gopkg.in/yaml.v3"
+)
+
+// RunMetadata 一次测试运行的元数据，使报告可以独立说明测试的条件
+type RunMetadata struct {
+	StartTime   time.Time
+	EndTime     time.Time
+	Config      *config.Config // 本次测试使用的配置（包含命令行覆盖的值）
+	ToolVersion string
+	Host        string
+}
+
+// MetadataRecord JSON报告中的运行元数据
+type MetadataRecord struct {
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	DurationSec float64   `json:"duration_sec"`
+	ToolVersion string    `json:"tool_version,omitempty"`
+	Host        string    `json:"host,omitempty"`
+	// 配置快照，字段名与配置文件一致，API密钥和代理密码已隐藏
+	Config map[string]interface{} `json:"config,omitempty"`
+}
+
+// newMetadataRecord 将运行元数据转换为JSON报告中的记录
+func newMetadataRecord(metadata *RunMetadata) (*MetadataRecord, error) {
+	record := &MetadataRecord{
+		StartTime:   metadata.StartTime,
+		EndTime:     metadata.EndTime,
+		DurationSec: metadata.EndTime.Sub(metadata.StartTime).Seconds(),
+		ToolVersion: metadata.ToolVersion,
+		Host:        metadata.Host,
+	}
+	if metadata.Config != nil {
+		// 经过YAML转换，使快照的字段名和时长格式与配置文件一致
+		data, err := yaml.Marshal(metadata.Config.Redacted())
+		if err != nil {
+			return nil, fmt.Errorf("序列化配置快照失败: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &record.Config); err != nil {
+			return nil, fmt.Errorf("转换配置快照失败: %w", err)
+		}
+	}
+	return record, nil
+}
+
+// writeMetadata 在文本报告开头写入运行元数据，没有元数据时不输出
+func writeMetadata(sb *strings.Builder, metadata *RunMetadata) {
+	if metadata == nil {
+		return
+	}
+	if !metadata.StartTime.IsZero() {
+		sb.WriteString(fmt.Sprintf("- 开始时间: %s\n", metadata.StartTime.Format("2006-01-02 15:04:05")))
+	}
+	if !metadata.EndTime.IsZero() {
+		sb.WriteString(fmt.Sprintf("- 结束时间: %s（耗时 %s）\n",
+			metadata.EndTime.Format("2006-01-02 15:04:05"), metadata.EndTime.Sub(metadata.StartTime).Round(time.Second)))
+	}
+	if metadata.ToolVersion != "" {
+		sb.WriteString(fmt.Sprintf("- 工具版本: %s\n", metadata.ToolVersion))
+	}
+	if metadata.Host != "" {
+		sb.WriteString(fmt.Sprintf("- 主机: %s\n", metadata.Host))
+	}
+	sb.WriteString("\n")
+}
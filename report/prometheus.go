@@ -0,0 +1,189 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// prometheusMetric 定义一个Prometheus指标及其取值方式
+type prometheusMetric struct {
+	name  string
+	help  string
+	value func(result *engine.TestResult) float64
+}
+
+// 导出的Prometheus指标，均为gauge类型
+var prometheusMetrics = []prometheusMetric{
+	{
+		name:  "llm_requests_per_second",
+		help:  "每秒成功请求数(RPS)",
+		value: func(r *engine.TestResult) float64 { return r.RequestsPerSec },
+	},
+	{
+		name:  "llm_tokens_per_second",
+		help:  "每秒Token数(TPS)",
+		value: func(r *engine.TestResult) float64 { return r.TokensPerSec },
+	},
+	{
+		name:  "llm_response_bytes_per_second",
+		help:  "每秒接收的响应字节数",
+		value: func(r *engine.TestResult) float64 { return r.BytesPerSec },
+	},
+	{
+		name:  "llm_avg_latency_seconds",
+		help:  "成功请求的平均延迟(秒)",
+		value: func(r *engine.TestResult) float64 { return r.AvgLatency.Seconds() },
+	},
+	{
+		name: "llm_success_rate",
+		help: "请求成功率(0-1)",
+		value: func(r *engine.TestResult) float64 {
+			if r.TotalRequests == 0 {
+				return 0
+			}
+			return float64(r.SuccessRequests) / float64(r.TotalRequests)
+		},
+	},
+	{
+		name:  "llm_requests_total",
+		help:  "总请求数",
+		value: func(r *engine.TestResult) float64 { return float64(r.TotalRequests) },
+	},
+	{
+		name:  "llm_requests_failed_total",
+		help:  "失败请求数",
+		value: func(r *engine.TestResult) float64 { return float64(r.FailedRequests) },
+	},
+	{
+		name:  "llm_requests_stopped_total",
+		help:  "正常结束（finish_reason为stop）的请求数",
+		value: func(r *engine.TestResult) float64 { return float64(r.StoppedRequests) },
+	},
+	{
+		name:  "llm_requests_truncated_total",
+		help:  "因达到最大token数被截断的请求数",
+		value: func(r *engine.TestResult) float64 { return float64(r.TruncatedRequests) },
+	},
+	{
+		name:  "llm_requests_near_timeout_total",
+		help:  "延迟接近请求超时时间的成功请求数",
+		value: func(r *engine.TestResult) float64 { return float64(r.NearTimeoutRequests) },
+	},
+	{
+		name:  "llm_requests_retried_total",
+		help:  "限流或服务端错误后的重试次数",
+		value: func(r *engine.TestResult) float64 { return float64(r.RetriedRequests) },
+	},
+	{
+		name:  "llm_requests_rate_limited_total",
+		help:  "收到429限流响应的次数",
+		value: func(r *engine.TestResult) float64 { return float64(rateLimitedRequests(r)) },
+	},
+	{
+		name:  "llm_requests_tool_calls_total",
+		help:  "返回工具调用的成功请求数",
+		value: func(r *engine.TestResult) float64 { return float64(r.ToolCallRequests) },
+	},
+	{
+		name:  "llm_requests_schema_valid_total",
+		help:  "响应内容满足 response_schema 的成功请求数",
+		value: func(r *engine.TestResult) float64 { return float64(r.SchemaValidRequests) },
+	},
+	{
+		name:  "llm_requests_schema_invalid_total",
+		help:  "响应内容不满足 response_schema 的成功请求数",
+		value: func(r *engine.TestResult) float64 { return float64(r.SchemaInvalidRequests) },
+	},
+	{
+		name:  "llm_avg_concurrency",
+		help:  "平均同时进行中的请求数",
+		value: func(r *engine.TestResult) float64 { return r.AvgConcurrency },
+	},
+	{
+		name:  "llm_avg_reasoning_tokens",
+		help:  "平均推理Token数(包含在输出Token中)",
+		value: func(r *engine.TestResult) float64 { return r.AvgReasoningTokens },
+	},
+	{
+		name:  "llm_avg_cache_creation_input_tokens",
+		help:  "平均写入提示词缓存的输入Token数",
+		value: func(r *engine.TestResult) float64 { return r.AvgCacheCreationInputTokens },
+	},
+	{
+		name:  "llm_avg_cache_read_input_tokens",
+		help:  "平均从提示词缓存读取的输入Token数",
+		value: func(r *engine.TestResult) float64 { return r.AvgCacheReadInputTokens },
+	},
+	{
+		name:  "llm_avg_input_tokens",
+		help:  "平均输入Token数",
+		value: func(r *engine.TestResult) float64 { return r.AvgInputTokens },
+	},
+	{
+		name:  "llm_avg_output_tokens",
+		help:  "平均输出Token数",
+		value: func(r *engine.TestResult) float64 { return r.AvgOutputTokens },
+	},
+}
+
+// 生成Prometheus文本格式报告
+func (r *Reporter) generatePrometheusReport(results map[string]*engine.TestResult) (string, error) {
+	var sb strings.Builder
+
+	// 收集所有测试结果
+	allResults := make([]*engine.TestResult, 0, len(results))
+	for _, result := range results {
+		allResults = append(allResults, result)
+	}
+
+	// 按模型名称、场景和并发度排序
+	sortResults(allResults)
+
+	for _, metric := range prometheusMetrics {
+		sb.WriteString(fmt.Sprintf("# HELP %s %s\n", metric.name, metric.help))
+		sb.WriteString(fmt.Sprintf("# TYPE %s gauge\n", metric.name))
+		for _, result := range allResults {
+			sb.WriteString(fmt.Sprintf("%s{%s} %g\n", metric.name, prometheusLabels(result), metric.value(result)))
+		}
+	}
+
+	// 延迟百分位，使用quantile标签
+	allPercentiles := getAllPercentiles(allResults)
+	if len(allPercentiles) > 0 {
+		sb.WriteString("# HELP llm_latency_seconds 延迟百分位(秒)\n")
+		sb.WriteString("# TYPE llm_latency_seconds gauge\n")
+		for _, result := range allResults {
+			for _, p := range allPercentiles {
+				latency, ok := result.LatencyPercentiles[p]
+				if !ok {
+					continue
+				}
+				sb.WriteString(fmt.Sprintf("llm_latency_seconds{%s,quantile=\"%g\"} %g\n",
+					prometheusLabels(result), float64(p)/100, latency.Seconds()))
+			}
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// 生成测试结果的标签，对比流式模式下加上 stream_mode 标签，避免同一模型和并发度的两个结果产生重复的序列
+func prometheusLabels(result *engine.TestResult) string {
+	labels := fmt.Sprintf("model=\"%s\",concurrency=\"%d\"", escapeLabelValue(result.ModelName), result.ConcurrencyLevel)
+	if result.Scenario != "" {
+		labels += fmt.Sprintf(",scenario=\"%s\"", escapeLabelValue(result.Scenario))
+	}
+	if result.StreamMode != "" {
+		labels += fmt.Sprintf(",stream_mode=\"%s\"", escapeLabelValue(result.StreamMode))
+	}
+	return labels
+}
+
+// 按Prometheus文本格式转义标签值
+func escapeLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
@@ -0,0 +1,47 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// 对比流式模式下同一模型和并发度的流式与非流式结果不能产生相同的序列，否则抓取端会拒绝整个文件
+func TestPrometheusReportSeriesAreUnique(t *testing.T) {
+	results := map[string]*engine.TestResult{}
+	for _, mode := range []string{engine.StreamModeStandard, engine.StreamModeStream} {
+		result := &engine.TestResult{
+			ModelName:          "gpt-4o",
+			ConcurrencyLevel:   4,
+			StreamMode:         mode,
+			TotalRequests:      10,
+			SuccessRequests:    10,
+			RequestsPerSec:     2,
+			AvgLatency:         time.Second,
+			LatencyPercentiles: map[int]time.Duration{99: 2 * time.Second},
+			ErrorsByCategory:   map[string]int{},
+		}
+		results[result.Key()] = result
+	}
+
+	report, err := NewReporter("prometheus").GenerateResultsReport(results)
+	if err != nil {
+		t.Fatalf("生成Prometheus报告失败: %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(report, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		series := line[:strings.LastIndexByte(line, ' ')]
+		if seen[series] {
+			t.Errorf("重复的序列: %s", series)
+		}
+		seen[series] = true
+	}
+	if !strings.Contains(report, `stream_mode="stream"`) || !strings.Contains(report, `stream_mode="standard"`) {
+		t.Errorf("报告中缺少 stream_mode 标签:\n%s", report)
+	}
+}
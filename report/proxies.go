@@ -0,0 +1,78 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// ProxyRecord JSON报告中代理池里单个代理的请求统计
+type ProxyRecord struct {
+	Name     string `json:"name"`
+	Requests int    `json:"requests"`
+	Failed   int    `json:"failed"`
+}
+
+// newProxyRecords 转换每个代理的请求统计，未配置代理池时返回nil
+func newProxyRecords(stats []engine.ProxyStats) []ProxyRecord {
+	if len(stats) == 0 {
+		return nil
+	}
+	records := make([]ProxyRecord, len(stats))
+	for i, s := range stats {
+		records[i] = ProxyRecord{Name: s.Name, Requests: s.Requests, Failed: s.Failed}
+	}
+	return records
+}
+
+// parseProxyRecords 从JSON报告中的记录恢复每个代理的请求统计
+func parseProxyRecords(records []ProxyRecord) []engine.ProxyStats {
+	if len(records) == 0 {
+		return nil
+	}
+	stats := make([]engine.ProxyStats, len(records))
+	for i, record := range records {
+		stats[i] = engine.ProxyStats{Name: record.Name, Requests: record.Requests, Failed: record.Failed}
+	}
+	return stats
+}
+
+// writeProxies 写入配置了代理池的模型每个代理的请求数、占比和失败数，用于确认请求是否均匀分布
+func writeProxies(sb *strings.Builder, results []*engine.TestResult) {
+	hasProxies := false
+	for _, result := range results {
+		if len(result.Proxies) > 0 {
+			hasProxies = true
+			break
+		}
+	}
+	if !hasProxies {
+		return
+	}
+
+	sb.WriteString("## 代理池\n\n")
+	sb.WriteString("| 模型 | 场景 | 并发度 | 代理 | 请求数 | 占比 | 失败数 |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+	for _, result := range results {
+		total := 0
+		for _, proxy := range result.Proxies {
+			total += proxy.Requests
+		}
+		for _, proxy := range result.Proxies {
+			share := 0.0
+			if total > 0 {
+				share = float64(proxy.Requests) / float64(total) * 100
+			}
+			sb.WriteString(fmt.Sprintf("| %s | %s | %d | %s | %d | %.2f%% | %d |\n",
+				result.ModelName,
+				result.Scenario,
+				result.ConcurrencyLevel,
+				proxy.Name,
+				proxy.Requests,
+				share,
+				proxy.Failed))
+		}
+	}
+	sb.WriteString("\n")
+}
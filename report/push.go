@@ -0,0 +1,185 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// 等待发送的请求最多为几批，超过后丢弃新的请求，避免接收端变慢时占用过多内存
+const pushBufferBatches = 10
+
+// RequestMetric 推送的单个请求的指标，每个请求一行JSON，字段与逐请求的CSV报告相同
+type RequestMetric struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Model         string    `json:"model"`
+	Scenario      string    `json:"scenario,omitempty"`
+	StreamMode    string    `json:"stream_mode,omitempty"`
+	Concurrency   int       `json:"concurrency"`
+	LatencyMs     float64   `json:"latency_ms"`
+	TTFTMs        float64   `json:"ttft_ms,omitempty"`
+	InputTokens   int       `json:"input_tokens"`
+	OutputTokens  int       `json:"output_tokens"`
+	Success       bool      `json:"success"`
+	ErrorCategory string    `json:"error_category,omitempty"`
+}
+
+// PushStats 推送的统计，Dropped 包括推送失败的批次中的请求和缓冲区已满时丢弃的请求
+type PushStats struct {
+	Sent          int
+	Dropped       int
+	FailedBatches int
+}
+
+// MetricsPusher 在测试进行中按批推送逐请求指标，Push 可以作为 TestEngine.SetRequestCallback 的回调
+// 攒满 batch_size 个请求或距上次发送超过 flush_interval 时发送一批，请求体为JSON Lines；
+// 推送失败时丢弃该批指标，不重试，只记录第一次失败的原因
+type MetricsPusher struct {
+	config config.MetricsPushConfig
+	client *http.Client
+	events chan engine.RequestEvent
+	done   chan struct{}
+
+	// 队列已满时丢弃的请求数，由工作协程并发修改
+	overflow int64
+	// 以下字段只由发送协程修改，Close 等待发送协程结束后读取
+	stats    PushStats
+	firstErr error
+}
+
+// NewMetricsPusher 创建指标推送器并启动发送协程，测试结束后需要调用 Close 发送剩余的指标
+func NewMetricsPusher(cfg config.MetricsPushConfig) *MetricsPusher {
+	batchSize := max(cfg.BatchSize, 1)
+	p := &MetricsPusher{
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		events: make(chan engine.RequestEvent, batchSize*pushBufferBatches),
+		done:   make(chan struct{}),
+	}
+	go p.run(batchSize)
+	return p
+}
+
+// Push 将一个请求加入待发送的队列，队列已满（接收端跟不上）时丢弃，不阻塞测试
+func (p *MetricsPusher) Push(event engine.RequestEvent) {
+	select {
+	case p.events <- event:
+	default:
+		atomic.AddInt64(&p.overflow, 1)
+	}
+}
+
+// Close 发送剩余的指标，等待发送完成后返回推送的统计，之后不能再调用 Push
+func (p *MetricsPusher) Close() PushStats {
+	close(p.events)
+	<-p.done
+	stats := p.stats
+	stats.Dropped += int(atomic.LoadInt64(&p.overflow))
+	return stats
+}
+
+// run 发送协程：攒批并发送，直到 events 关闭
+func (p *MetricsPusher) run(batchSize int) {
+	defer close(p.done)
+
+	// 未通过 config.LoadConfigs 加载的配置可能没有默认值
+	interval := p.config.FlushInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	batch := make([]RequestMetric, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := p.send(batch); err != nil {
+			p.stats.Dropped += len(batch)
+			p.stats.FailedBatches++
+			if p.firstErr == nil {
+				p.firstErr = err
+				log.Printf("推送请求指标失败，丢弃该批 %d 个请求的指标（之后的失败不再逐条输出）: %v", len(batch), err)
+			}
+		} else {
+			p.stats.Sent += len(batch)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case event, ok := <-p.events:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, newRequestMetric(event))
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// newRequestMetric 将请求事件转换为推送的指标
+func newRequestMetric(event engine.RequestEvent) RequestMetric {
+	return RequestMetric{
+		Timestamp:     event.StartTime,
+		Model:         event.ModelName,
+		Scenario:      event.Scenario,
+		StreamMode:    event.StreamMode,
+		Concurrency:   event.ConcurrencyLevel,
+		LatencyMs:     float64(event.Latency) / float64(time.Millisecond),
+		TTFTMs:        float64(event.TimeToFirstToken) / float64(time.Millisecond),
+		InputTokens:   event.InputTokens,
+		OutputTokens:  event.OutputTokens,
+		Success:       event.Success,
+		ErrorCategory: event.ErrorCategory,
+	}
+}
+
+// send 以JSON Lines格式发送一批指标，接收端返回非2xx状态码时返回错误
+func (p *MetricsPusher) send(batch []RequestMetric) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, metric := range batch {
+		if err := encoder.Encode(metric); err != nil {
+			return fmt.Errorf("序列化请求指标失败: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, p.config.URL, &body)
+	if err != nil {
+		return fmt.Errorf("创建推送请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	for name, value := range p.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送推送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("接收端返回状态码 %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	// 读完响应体以便复用连接
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,112 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// RateLimitRecord JSON报告中的限流信息
+type RateLimitRecord struct {
+	// 是否收到过429限流响应
+	RateLimited         bool `json:"rate_limited"`
+	RateLimitedRequests int  `json:"rate_limited_requests"`
+	// 响应头中剩余请求数和Token数的最小值，服务端未返回时省略
+	MinRemainingRequests *int `json:"min_remaining_requests,omitempty"`
+	MinRemainingTokens   *int `json:"min_remaining_tokens,omitempty"`
+}
+
+// newRateLimitRecord 转换限流信息，没有限流信息时返回nil
+func newRateLimitRecord(stats *engine.RateLimitStats) *RateLimitRecord {
+	if stats == nil {
+		return nil
+	}
+	record := &RateLimitRecord{
+		RateLimited:         stats.RateLimitedRequests > 0,
+		RateLimitedRequests: stats.RateLimitedRequests,
+	}
+	if stats.MinRemainingRequests >= 0 {
+		record.MinRemainingRequests = &stats.MinRemainingRequests
+	}
+	if stats.MinRemainingTokens >= 0 {
+		record.MinRemainingTokens = &stats.MinRemainingTokens
+	}
+	return record
+}
+
+// parseRateLimitRecord 从JSON报告中的记录恢复限流信息
+func parseRateLimitRecord(record *RateLimitRecord) *engine.RateLimitStats {
+	if record == nil {
+		return nil
+	}
+	stats := &engine.RateLimitStats{
+		RateLimitedRequests:  record.RateLimitedRequests,
+		MinRemainingRequests: -1,
+		MinRemainingTokens:   -1,
+	}
+	if record.MinRemainingRequests != nil {
+		stats.MinRemainingRequests = *record.MinRemainingRequests
+	}
+	if record.MinRemainingTokens != nil {
+		stats.MinRemainingTokens = *record.MinRemainingTokens
+	}
+	return stats
+}
+
+// writeRateLimits 写入每个结果的限流情况，服务端没有返回限流信息且没有重试时不输出
+func writeRateLimits(sb *strings.Builder, results []*engine.TestResult) {
+	hasRateLimit := false
+	for _, result := range results {
+		if result.RateLimit != nil || result.RetriedRequests > 0 {
+			hasRateLimit = true
+			break
+		}
+	}
+	if !hasRateLimit {
+		return
+	}
+
+	sb.WriteString("## 限流\n\n")
+	sb.WriteString("| 模型 | 场景 | 并发度 | 是否被限流 | 429次数 | 重试次数 | 最少剩余请求数 | 最少剩余Token数 |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- | --- | --- | --- |\n")
+	for _, result := range results {
+		if result.RateLimit == nil && result.RetriedRequests == 0 {
+			continue
+		}
+		stats := result.RateLimit
+		if stats == nil {
+			stats = &engine.RateLimitStats{MinRemainingRequests: -1, MinRemainingTokens: -1}
+		}
+		limited := "否"
+		if stats.RateLimitedRequests > 0 {
+			limited = "是"
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s | %d | %s | %d | %d | %s | %s |\n",
+			result.ModelName,
+			result.Scenario,
+			result.ConcurrencyLevel,
+			limited,
+			stats.RateLimitedRequests,
+			result.RetriedRequests,
+			formatRemaining(stats.MinRemainingRequests),
+			formatRemaining(stats.MinRemainingTokens)))
+	}
+	sb.WriteString("\n")
+}
+
+// rateLimitedRequests 返回收到429响应的次数，没有限流信息时为0
+func rateLimitedRequests(result *engine.TestResult) int {
+	if result.RateLimit == nil {
+		return 0
+	}
+	return result.RateLimit.RateLimitedRequests
+}
+
+// formatRemaining 格式化剩余配额，服务端未返回时显示"-"
+func formatRemaining(n int) string {
+	if n < 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d", n)
+}
@@ -8,7 +8,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lemonlinger/llm-test/config"
 	"github.com/lemonlinger/llm-test/engine"
+	"gonum.org/v1/plot/font"
 )
 
 // ModelPerformance 存储模型在不同并发度下的性能数据
@@ -26,35 +28,93 @@ type ModelPerformance struct {
 
 // Reporter 报告生成器结构体
 type Reporter struct {
-	format string
+	format  string
+	latency LatencyFormat
+	// 时间序列报告的统计窗口
+	timelineInterval time.Duration
+	// 图表使用的字体，见 SetChartFont
+	chartFont font.Typeface
 }
 
+// SupportedFormats 支持的报告格式
+var SupportedFormats = []string{"text", "json", "csv", "csv-raw", "timeline", "prometheus"}
+
 // NewReporter 创建新的报告生成器
 func NewReporter(format string) *Reporter {
 	return &Reporter{
-		format: format,
+		format:  format,
+		latency: DefaultLatencyFormat,
 	}
 }
 
-// GenerateReport 生成测试报告
-func (r *Reporter) GenerateReport(results map[string]*engine.TestResult) (string, error) {
+// SetLatencyFormat 设置报告中延迟的单位和小数位数，未设置时使用 DefaultLatencyFormat
+func (r *Reporter) SetLatencyFormat(latency LatencyFormat) {
+	r.latency = latency
+}
+
+// FileExtension 返回报告文件的扩展名
+func (r *Reporter) FileExtension() string {
+	switch r.format {
+	case "json", "csv":
+		return r.format
+	case "csv-raw":
+		return "requests.csv"
+	case "timeline":
+		return "timeline.csv"
+	case "prometheus":
+		return "prom"
+	default:
+		return "text"
+	}
+}
+
+// GenerateReport 生成测试报告，metadata 为本次运行的元数据，文本报告和JSON报告中会包含它，可以为nil
+// 测试出错的模型（ModelError不为空）只出现在文本报告的失败列表和JSON报告中
+func (r *Reporter) GenerateReport(results map[string]*engine.TestResult, metadata *RunMetadata) (string, error) {
 	switch r.format {
 	case "json":
-		return r.generateJSONReport(results)
+		return r.generateJSONReport(results, metadata)
 	case "csv":
-		return r.generateCSVReport(results)
+		return r.generateCSVReport(measuredResults(results))
+	case "csv-raw":
+		return r.generateRawCSVReport(measuredResults(results))
+	case "timeline":
+		return r.generateTimelineCSVReport(measuredResults(results))
+	case "prometheus":
+		return r.generatePrometheusReport(measuredResults(results))
 	default:
-		return r.generateTextReport(results)
+		return r.generateTextReport(results, metadata)
+	}
+}
+
+// GenerateResultsReport 只根据测试结果生成报告，不包含运行元数据
+func (r *Reporter) GenerateResultsReport(results map[string]*engine.TestResult) (string, error) {
+	return r.GenerateReport(results, nil)
+}
+
+// measuredResults 返回去掉测试出错的模型后的结果
+func measuredResults(results map[string]*engine.TestResult) map[string]*engine.TestResult {
+	measured := make(map[string]*engine.TestResult, len(results))
+	for key, result := range results {
+		if result.ModelError == "" {
+			measured[key] = result
+		}
 	}
+	return measured
 }
 
 // 生成文本格式报告
-func (r *Reporter) generateTextReport(results map[string]*engine.TestResult) (string, error) {
+func (r *Reporter) generateTextReport(results map[string]*engine.TestResult, metadata *RunMetadata) (string, error) {
 	var sb strings.Builder
 
-	// 收集所有测试结果
+	// 收集所有测试结果，测试出错的模型单独列出
 	allResults := make([]*engine.TestResult, 0, len(results))
+	var failedModels []*engine.TestResult
 	for _, result := range results {
+		if result.ModelError != "" {
+			failedModels = append(failedModels, result)
+			continue
+		}
 		allResults = append(allResults, result)
 	}
 
@@ -63,17 +123,114 @@ func (r *Reporter) generateTextReport(results map[string]*engine.TestResult) (st
 
 	// 报告摘要
 	sb.WriteString("# LLM API 性能测试报告\n\n")
+	writeMetadata(&sb, metadata)
 
 	// 报告设置
 	sb.WriteString("## 测试设置\n\n")
-	sb.WriteString("\n")
+	var cfg *config.Config
+	if metadata != nil {
+		cfg = metadata.Config
+	}
+	writeSettings(&sb, cfg)
 
 	// 详细结果
 	sb.WriteString("## 测试结果\n\n")
 
+	// 多场景测试时增加场景列，对比流式模式下增加模式列，有流式请求时增加首Token列
+	showScenario := hasScenarios(allResults)
+	showStreamMode := false
+	showTTFT := false
+	showBytes := false
+	for _, result := range allResults {
+		if result.StreamMode != "" {
+			showStreamMode = true
+		}
+		if result.AvgTimeToFirstToken > 0 {
+			showTTFT = true
+		}
+		if result.ResponseBytes > 0 {
+			showBytes = true
+		}
+	}
+
+	// 有正常结束的请求时增加正常结束列，有被截断的请求时增加截断列，有工具调用时增加工具调用列，有推理Token时增加推理Token列，配置了Token价格时增加费用列
+	showStopped := false
+	showTruncated := false
+	showNearTimeout := false
+	showToolCalls := false
+	showSchema := false
+	showReasoning := false
+	showCache := false
+	showCost := false
+	for _, result := range allResults {
+		if result.StoppedRequests > 0 {
+			showStopped = true
+		}
+		if result.TruncatedRequests > 0 {
+			showTruncated = true
+		}
+		if result.NearTimeoutRequests > 0 {
+			showNearTimeout = true
+		}
+		if result.ToolCallRequests > 0 {
+			showToolCalls = true
+		}
+		if result.SchemaValidRequests+result.SchemaInvalidRequests > 0 {
+			showSchema = true
+		}
+		if result.ReasoningTokens > 0 {
+			showReasoning = true
+		}
+		if hasPromptCache(result) {
+			showCache = true
+		}
+		if result.TotalCost > 0 {
+			showCost = true
+		}
+	}
+
 	// 生成单个合并表格（标准Markdown格式）
 	// 表头
-	sb.WriteString("| 模型 | 并发度 | 成功/总请求 | 成功率 | 平均延迟 | 平均输入Token | 平均输出Token | 平均总Token | RPS | TPS")
+	sb.WriteString("| 模型")
+	if showScenario {
+		sb.WriteString(" | 场景")
+	}
+	if showStreamMode {
+		sb.WriteString(" | 模式")
+	}
+	sb.WriteString(" | 并发度 | 实际并发(峰值/平均) | 成功/总请求 | 成功率")
+	if showSchema {
+		sb.WriteString(" | Schema有效率")
+	}
+	sb.WriteString(" | 平均延迟 | 最小延迟 | 最大延迟 | 延迟标准差 | 平均输入Token | 平均输出Token | 平均总Token | RPS | TPS")
+	if showTTFT {
+		sb.WriteString(" | 平均首Token")
+	}
+	if showBytes {
+		sb.WriteString(" | 平均响应大小 | 吞吐量(字节/秒)")
+	}
+
+	if showStopped {
+		sb.WriteString(" | 正常结束")
+	}
+	if showTruncated {
+		sb.WriteString(" | 截断请求")
+	}
+	if showNearTimeout {
+		sb.WriteString(" | 接近超时")
+	}
+	if showToolCalls {
+		sb.WriteString(" | 工具调用/文本")
+	}
+	if showReasoning {
+		sb.WriteString(" | 平均推理Token")
+	}
+	if showCache {
+		sb.WriteString(" | 平均缓存写入Token | 平均缓存读取Token")
+	}
+	if showCost {
+		sb.WriteString(" | 总费用 | 单次请求费用")
+	}
 
 	// 添加百分位列
 	for _, p := range allPercentiles {
@@ -82,21 +239,56 @@ func (r *Reporter) generateTextReport(results map[string]*engine.TestResult) (st
 	sb.WriteString(" |\n")
 
 	// 分隔线
-	sb.WriteString("| --- | --- | --- | --- | --- | --- | --- | --- | --- | ---")
+	sb.WriteString("| ---")
+	if showScenario {
+		sb.WriteString(" | ---")
+	}
+	if showStreamMode {
+		sb.WriteString(" | ---")
+	}
+	sb.WriteString(" | --- | --- | --- | ---")
+	if showSchema {
+		sb.WriteString(" | ---")
+	}
+	sb.WriteString(" | --- | --- | --- | --- | --- | --- | --- | --- | ---")
+	if showTTFT {
+		sb.WriteString(" | ---")
+	}
+	if showBytes {
+		sb.WriteString(" | --- | ---")
+	}
+	if showStopped {
+		sb.WriteString(" | ---")
+	}
+	if showTruncated {
+		sb.WriteString(" | ---")
+	}
+	if showNearTimeout {
+		sb.WriteString(" | ---")
+	}
+	if showToolCalls {
+		sb.WriteString(" | ---")
+	}
+	if showReasoning {
+		sb.WriteString(" | ---")
+	}
+	if showCache {
+		sb.WriteString(" | --- | ---")
+	}
+	if showCost {
+		sb.WriteString(" | --- | ---")
+	}
 	for range allPercentiles {
 		sb.WriteString(" | ---")
 	}
 	sb.WriteString(" |\n")
 
-	// 按模型名称和并发度排序
-	sort.Slice(allResults, func(i, j int) bool {
-		if allResults[i].ModelName != allResults[j].ModelName {
-			return allResults[i].ModelName < allResults[j].ModelName
-		}
-		return allResults[i].ConcurrencyLevel < allResults[j].ConcurrencyLevel
-	})
+	// 按模型名称、场景和并发度排序
+	sortResults(allResults)
 
 	// 内容
+	hasEstimated := false
+	hasCapped := false
 	for _, result := range allResults {
 		// 计算成功率
 		successRate := 0.0
@@ -104,22 +296,84 @@ func (r *Reporter) generateTextReport(results map[string]*engine.TestResult) (st
 			successRate = float64(result.SuccessRequests) / float64(result.TotalRequests) * 100
 		}
 
-		sb.WriteString(fmt.Sprintf("| %s | %d | %d/%d | %.2f%% | %s | %.2f | %.2f | %.2f | %.2f | %.2f",
-			result.ModelName,
-			result.ConcurrencyLevel,
+		// Token数包含估算值时加上"~"前缀
+		tokenPrefix := ""
+		if result.EstimatedTokenRequests > 0 {
+			tokenPrefix = "~"
+			hasEstimated = true
+		}
+
+		sb.WriteString(fmt.Sprintf("| %s", result.ModelName))
+		if showScenario {
+			sb.WriteString(fmt.Sprintf(" | %s", result.Scenario))
+		}
+		if showStreamMode {
+			sb.WriteString(fmt.Sprintf(" | %s", result.StreamMode))
+		}
+		concurrency := fmt.Sprintf("%d", result.ConcurrencyLevel)
+		if result.ConcurrencyCap > 0 {
+			concurrency = fmt.Sprintf("%d (上限%d)", result.ConcurrencyLevel, result.ConcurrencyCap)
+			hasCapped = true
+		}
+		sb.WriteString(fmt.Sprintf(" | %s | %d/%.1f | %d/%d | %.2f%%",
+			concurrency,
+			result.PeakConcurrency, result.AvgConcurrency,
 			result.SuccessRequests, result.TotalRequests,
-			successRate,
-			formatDuration(result.AvgLatency),
-			result.AvgInputTokens,
-			result.AvgOutputTokens,
-			result.AvgTotalTokens,
+			successRate))
+		if showSchema {
+			if checked := result.SchemaValidRequests + result.SchemaInvalidRequests; checked > 0 {
+				sb.WriteString(fmt.Sprintf(" | %.2f%% (%d/%d)", float64(result.SchemaValidRequests)/float64(checked)*100, result.SchemaValidRequests, checked))
+			} else {
+				sb.WriteString(" | -")
+			}
+		}
+		sb.WriteString(fmt.Sprintf(" | %s | %s | %s | %s | %s%.2f | %s%.2f | %s%.2f | %.2f | %s%.2f",
+			r.latency.Format(result.AvgLatency),
+			r.latency.Format(result.MinLatency),
+			r.latency.Format(result.MaxLatency),
+			r.latency.Format(result.StdDevLatency),
+			tokenPrefix, result.AvgInputTokens,
+			tokenPrefix, result.AvgOutputTokens,
+			tokenPrefix, result.AvgTotalTokens,
 			result.RequestsPerSec,
-			result.TokensPerSec))
+			tokenPrefix, result.TokensPerSec))
+
+		if showTTFT {
+			if result.AvgTimeToFirstToken > 0 {
+				sb.WriteString(fmt.Sprintf(" | %s", r.latency.Format(result.AvgTimeToFirstToken)))
+			} else {
+				sb.WriteString(" | -")
+			}
+		}
+		if showBytes {
+			sb.WriteString(fmt.Sprintf(" | %s | %s/s", formatBytes(result.AvgResponseBytes), formatBytes(result.BytesPerSec)))
+		}
+		if showStopped {
+			sb.WriteString(fmt.Sprintf(" | %d", result.StoppedRequests))
+		}
+		if showTruncated {
+			sb.WriteString(fmt.Sprintf(" | %d", result.TruncatedRequests))
+		}
+		if showNearTimeout {
+			sb.WriteString(fmt.Sprintf(" | %d", result.NearTimeoutRequests))
+		}
+		if showToolCalls {
+			sb.WriteString(fmt.Sprintf(" | %d/%d", result.ToolCallRequests, result.SuccessRequests-result.ToolCallRequests))
+		}
+		if showReasoning {
+			sb.WriteString(fmt.Sprintf(" | %s%.2f", tokenPrefix, result.AvgReasoningTokens))
+		}
+		if showCache {
+			sb.WriteString(fmt.Sprintf(" | %.2f | %.2f", result.AvgCacheCreationInputTokens, result.AvgCacheReadInputTokens))
+		}
+		if showCost {
+			sb.WriteString(fmt.Sprintf(" | %s%.4f | %s%.6f", tokenPrefix, result.TotalCost, tokenPrefix, result.AvgCostPerRequest))
+		}
 
 		// 添加百分位数据
 		for _, p := range allPercentiles {
 			if latency, ok := result.LatencyPercentiles[p]; ok {
-				sb.WriteString(fmt.Sprintf(" | %s", formatDuration(latency)))
+				sb.WriteString(fmt.Sprintf(" | %s", r.latency.Format(latency)))
 			} else {
 				sb.WriteString(" | -")
 			}
@@ -130,9 +384,340 @@ func (r *Reporter) generateTextReport(results map[string]*engine.TestResult) (st
 
 	sb.WriteString("\n")
 
+	if hasEstimated {
+		sb.WriteString("注: 带\"~\"前缀的Token数据包含估算值（服务端未返回usage时根据内容估算）\n\n")
+	}
+	if showSchema {
+		sb.WriteString("注: Schema有效率为响应内容满足场景 response_schema 的成功请求占比，返回工具调用的请求和按 discard_first_n 丢弃的请求不参与校验\n\n")
+	}
+	if hasCapped {
+		sb.WriteString("注: 并发度后的\"上限\"为模型配置的 max_concurrency，同时发送给该模型的请求数不超过上限，超出的请求排队等待，排队时间不计入延迟\n\n")
+	}
+
+	// 使用了response_format的模型
+	writeResponseFormats(&sb, allResults)
+
+	writeIncompleteLevels(&sb, allResults)
+
+	for _, result := range allResults {
+		if result.DiscardedRequests > 0 {
+			sb.WriteString("注: 每个并发度的前几个成功请求按 discard_first_n 配置丢弃，计入成功请求数，但不计入延迟、Token和吞吐量统计\n\n")
+			break
+		}
+	}
+
+	// 自动并发扫描的拐点
+	writeKneeSummary(&sb, allResults, r.latency)
+
+	// 错误分类统计
+	writeErrorBreakdown(&sb, allResults)
+
+	// 常见错误
+	writeTopErrors(&sb, allResults)
+
+	// 结构化输出校验错误
+	writeSchemaErrors(&sb, allResults)
+
+	// 流式与非流式对比
+	writeStreamingComparison(&sb, allResults, r.latency)
+
+	// 延迟分布
+	writeLatencyHistograms(&sb, allResults)
+
+	// 按输入长度的延迟
+	writeInputLengths(&sb, allResults, r.latency)
+
+	// 吞吐量收敛
+	writeConvergence(&sb, allResults, r.latency)
+
+	// 限流
+	writeRateLimits(&sb, allResults)
+
+	// API密钥
+	writeAPIKeys(&sb, allResults)
+
+	// 代理池
+	writeProxies(&sb, allResults)
+
+	// 连接耗时
+	writeConnectionTimings(&sb, allResults, r.latency)
+
+	// 响应内容样例
+	writeSampleResponses(&sb, allResults)
+
+	// 测试出错的模型
+	writeModelErrors(&sb, failedModels)
+
 	return sb.String(), nil
 }
 
+// 写入测试出错而被跳过的模型，没有时不输出
+func writeModelErrors(sb *strings.Builder, failed []*engine.TestResult) {
+	if len(failed) == 0 {
+		return
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].ModelName < failed[j].ModelName })
+
+	sb.WriteString("## 测试失败的模型\n\n")
+	sb.WriteString("| 模型 | 错误 |\n")
+	sb.WriteString("| --- | --- |\n")
+	for _, result := range failed {
+		sb.WriteString(fmt.Sprintf("| %s | %s |\n", result.ModelName, strings.ReplaceAll(result.ModelError, "|", "\\|")))
+	}
+	sb.WriteString("\n")
+}
+
+// 写入因中断或达到总时长上限而未完整运行的并发度，没有时不输出
+func writeIncompleteLevels(sb *strings.Builder, results []*engine.TestResult) {
+	var levels []string
+	for _, result := range results {
+		if !result.Incomplete {
+			continue
+		}
+		name := result.ModelName
+		if result.Scenario != "" {
+			name += "/" + result.Scenario
+		}
+		levels = append(levels, fmt.Sprintf("%s 并发度 %d", name, result.ConcurrencyLevel))
+	}
+	if len(levels) > 0 {
+		sb.WriteString(fmt.Sprintf("注: 以下并发度因测试中断或达到总时长上限未完整运行，结果仅供参考: %s\n\n", strings.Join(levels, "、")))
+	}
+}
+
+// 写入使用了response_format的模型，没有时不输出
+func writeResponseFormats(sb *strings.Builder, results []*engine.TestResult) {
+	seen := make(map[string]bool)
+	for _, result := range results {
+		if result.ResponseFormat == "" || seen[result.ModelName] {
+			continue
+		}
+		seen[result.ModelName] = true
+		sb.WriteString(fmt.Sprintf("注: 模型 %s 的请求使用了 response_format=%s\n", result.ModelName, result.ResponseFormat))
+	}
+	if len(seen) > 0 {
+		sb.WriteString("\n")
+	}
+}
+
+// 写入不满足 response_schema 的响应的校验错误示例，没有时不输出
+func writeSchemaErrors(sb *strings.Builder, results []*engine.TestResult) {
+	hasErrors := false
+	for _, result := range results {
+		if len(result.SchemaErrors) > 0 {
+			hasErrors = true
+			break
+		}
+	}
+	if !hasErrors {
+		return
+	}
+
+	sb.WriteString("## Schema校验错误示例\n\n")
+	for _, result := range results {
+		if len(result.SchemaErrors) == 0 {
+			continue
+		}
+		title := result.ModelName
+		if result.Scenario != "" {
+			title += " / " + result.Scenario
+		}
+		sb.WriteString(fmt.Sprintf("### %s (并发度 %d，%d 个响应不满足Schema)\n\n", title, result.ConcurrencyLevel, result.SchemaInvalidRequests))
+		for _, message := range result.SchemaErrors {
+			sb.WriteString(fmt.Sprintf("- %s\n", message))
+		}
+		sb.WriteString("\n")
+	}
+}
+
+// 写入对比流式模式下同一模型、场景和并发度的非流式与流式结果对比，没有对比结果时不输出
+// results 需要已按 sortResults 排序，同一组的非流式结果紧挨在流式结果之前
+func writeStreamingComparison(sb *strings.Builder, results []*engine.TestResult, latency LatencyFormat) {
+	type pair struct{ standard, stream *engine.TestResult }
+	var pairs []pair
+	for i := 0; i+1 < len(results); i++ {
+		standard, stream := results[i], results[i+1]
+		if standard.StreamMode == engine.StreamModeStandard && stream.StreamMode == engine.StreamModeStream &&
+			standard.ModelName == stream.ModelName && standard.Scenario == stream.Scenario &&
+			standard.ConcurrencyLevel == stream.ConcurrencyLevel {
+			pairs = append(pairs, pair{standard, stream})
+		}
+	}
+	if len(pairs) == 0 {
+		return
+	}
+
+	showScenario := hasScenarios(results)
+
+	sb.WriteString("## 流式与非流式对比\n\n")
+	sb.WriteString("| 模型")
+	if showScenario {
+		sb.WriteString(" | 场景")
+	}
+	sb.WriteString(" | 并发度 | 非流式平均延迟 | 流式平均延迟 | 流式平均首Token | 非流式RPS | 流式RPS |\n")
+	sb.WriteString("| ---")
+	if showScenario {
+		sb.WriteString(" | ---")
+	}
+	sb.WriteString(" | --- | --- | --- | --- | --- | --- |\n")
+	for _, p := range pairs {
+		sb.WriteString(fmt.Sprintf("| %s", p.standard.ModelName))
+		if showScenario {
+			sb.WriteString(fmt.Sprintf(" | %s", p.standard.Scenario))
+		}
+		sb.WriteString(fmt.Sprintf(" | %d | %s | %s | %s | %.2f | %.2f |\n",
+			p.standard.ConcurrencyLevel,
+			latency.Format(p.standard.AvgLatency),
+			formatDurationDiff(p.standard.AvgLatency, p.stream.AvgLatency, latency),
+			latency.Format(p.stream.AvgTimeToFirstToken),
+			p.standard.RequestsPerSec,
+			p.stream.RequestsPerSec))
+	}
+	sb.WriteString("\n注: 流式平均延迟的格式为：流式值 (相对非流式的变化量, 变化百分比)\n\n")
+}
+
+// 写入自动并发扫描选出的拐点，没有拐点时不输出
+func writeKneeSummary(sb *strings.Builder, results []*engine.TestResult, latency LatencyFormat) {
+	knees := make([]*engine.TestResult, 0)
+	for _, result := range results {
+		if result.IsKnee {
+			knees = append(knees, result)
+		}
+	}
+	if len(knees) == 0 {
+		return
+	}
+
+	sb.WriteString("## 自动并发扫描\n\n")
+	sb.WriteString("| 模型 | 场景 | 拐点并发度 | RPS | 平均延迟 | 成功率 |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, result := range knees {
+		successRate := 0.0
+		if result.TotalRequests > 0 {
+			successRate = float64(result.SuccessRequests) / float64(result.TotalRequests) * 100
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s | %d | %.2f | %s | %.2f%% |\n",
+			result.ModelName,
+			result.Scenario,
+			result.ConcurrencyLevel,
+			result.RequestsPerSec,
+			latency.Format(result.AvgLatency),
+			successRate))
+	}
+	sb.WriteString("\n")
+}
+
+// 错误分类在报告中的显示名称
+var errorCategoryLabels = map[string]string{
+	engine.ErrorCategoryTimeout: "超时",
+	engine.ErrorCategoryHTTP4xx: "HTTP 4xx",
+	engine.ErrorCategoryHTTP5xx: "HTTP 5xx",
+	engine.ErrorCategoryNetwork: "网络错误",
+	engine.ErrorCategoryParse:   "解析错误",
+	engine.ErrorCategoryInvalid: "无效响应",
+	engine.ErrorCategoryOther:   "其他",
+}
+
+// 写入错误分类统计表格，没有失败请求时不输出
+func writeErrorBreakdown(sb *strings.Builder, results []*engine.TestResult) {
+	hasErrors := false
+	for _, result := range results {
+		if result.FailedRequests > 0 {
+			hasErrors = true
+			break
+		}
+	}
+	if !hasErrors {
+		return
+	}
+
+	sb.WriteString("## 错误分类\n\n")
+
+	showScenario := hasScenarios(results)
+
+	// 表头
+	sb.WriteString("| 模型")
+	if showScenario {
+		sb.WriteString(" | 场景")
+	}
+	sb.WriteString(" | 并发度 | 失败请求")
+	for _, category := range engine.ErrorCategories {
+		sb.WriteString(fmt.Sprintf(" | %s", errorCategoryLabels[category]))
+	}
+	sb.WriteString(" |\n")
+
+	// 分隔线
+	sb.WriteString("| --- | --- | ---")
+	if showScenario {
+		sb.WriteString(" | ---")
+	}
+	for range engine.ErrorCategories {
+		sb.WriteString(" | ---")
+	}
+	sb.WriteString(" |\n")
+
+	// 内容，只列出有失败请求的结果
+	for _, result := range results {
+		if result.FailedRequests == 0 {
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf("| %s", result.ModelName))
+		if showScenario {
+			sb.WriteString(fmt.Sprintf(" | %s", result.Scenario))
+		}
+		sb.WriteString(fmt.Sprintf(" | %d | %d", result.ConcurrencyLevel, result.FailedRequests))
+		for _, category := range engine.ErrorCategories {
+			sb.WriteString(fmt.Sprintf(" | %d", result.ErrorsByCategory[category]))
+		}
+		sb.WriteString(" |\n")
+	}
+
+	sb.WriteString("\n")
+}
+
+// 文本报告中每条响应样例最多显示的字符数，完整内容见JSON报告
+const maxSampleRunes = 500
+
+// 写入保存的响应内容样例，没有样例时不输出
+func writeSampleResponses(sb *strings.Builder, results []*engine.TestResult) {
+	hasSamples := false
+	for _, result := range results {
+		if len(result.SampleResponses) > 0 {
+			hasSamples = true
+			break
+		}
+	}
+	if !hasSamples {
+		return
+	}
+
+	sb.WriteString("## 响应样例\n\n")
+	for _, result := range results {
+		if len(result.SampleResponses) == 0 {
+			continue
+		}
+
+		title := result.ModelName
+		if result.Scenario != "" {
+			title += " / " + result.Scenario
+		}
+		sb.WriteString(fmt.Sprintf("### %s (并发度 %d)\n\n", title, result.ConcurrencyLevel))
+
+		for i, content := range result.SampleResponses {
+			if strings.TrimSpace(content) == "" {
+				sb.WriteString(fmt.Sprintf("%d. (空响应)\n\n", i+1))
+				continue
+			}
+			runes := []rune(content)
+			if len(runes) > maxSampleRunes {
+				content = string(runes[:maxSampleRunes]) + "..."
+			}
+			sb.WriteString(fmt.Sprintf("%d.\n\n```\n%s\n```\n\n", i+1, content))
+		}
+	}
+}
+
 // 获取所有结果中使用的百分位值，并按升序排序
 func getAllPercentiles(results []*engine.TestResult) []int {
 	// 使用map去重
@@ -156,8 +741,35 @@ func getAllPercentiles(results []*engine.TestResult) []int {
 	return percentiles
 }
 
+// 按模型名称、场景和并发度排序
+func sortResults(results []*engine.TestResult) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].ModelName != results[j].ModelName {
+			return results[i].ModelName < results[j].ModelName
+		}
+		if results[i].Scenario != results[j].Scenario {
+			return results[i].Scenario < results[j].Scenario
+		}
+		if results[i].ConcurrencyLevel != results[j].ConcurrencyLevel {
+			return results[i].ConcurrencyLevel < results[j].ConcurrencyLevel
+		}
+		return results[i].StreamMode < results[j].StreamMode
+	})
+}
+
+// 判断结果中是否包含命名的提示词场景
+func hasScenarios(results []*engine.TestResult) bool {
+	for _, result := range results {
+		if result.Scenario != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // 生成CSV格式报告
 func (r *Reporter) generateCSVReport(results map[string]*engine.TestResult) (string, error) {
+	unit := r.latency.csvUnit()
 	var sb strings.Builder
 	writer := csv.NewWriter(&sb)
 
@@ -170,25 +782,24 @@ func (r *Reporter) generateCSVReport(results map[string]*engine.TestResult) (str
 	// 获取所有使用的百分位
 	allPercentiles := getAllPercentiles(allResults)
 
-	// 按模型名称和并发度排序
-	sort.Slice(allResults, func(i, j int) bool {
-		if allResults[i].ModelName != allResults[j].ModelName {
-			return allResults[i].ModelName < allResults[j].ModelName
-		}
-		return allResults[i].ConcurrencyLevel < allResults[j].ConcurrencyLevel
-	})
+	// 按模型名称、场景和并发度排序
+	sortResults(allResults)
 
 	// 写入表头
 	headers := []string{
-		"模型名称", "并发度", "平均延迟(ms)",
+		"模型名称", "场景", "模式", "并发度", "并发上限", "峰值并发", "平均并发",
+		"平均延迟(" + unit + ")", "平均首Token(" + unit + ")", "最小延迟(" + unit + ")", "最大延迟(" + unit + ")", "延迟标准差(" + unit + ")",
 		"平均输入Token", "平均输出Token", "平均总Token",
-		"每秒请求数(RPS)", "每秒Token数(TPS)", "成功率(%)",
-		"总请求数", "成功请求数", "失败请求数",
+		"每秒请求数(RPS)", "每秒Token数(TPS)", "平均响应字节数", "每秒响应字节数", "成功率(%)",
+		"总请求数", "成功请求数", "失败请求数", "估算Token请求数", "正常结束请求数", "截断请求数", "接近超时请求数", "工具调用请求数", "Schema有效请求数", "Schema无效请求数", "丢弃指标请求数", "重试次数", "429次数",
+		"平均推理Token", "平均缓存写入Token", "平均缓存读取Token",
+		"总费用", "单次请求费用",
+		"新建连接请求数", "平均DNS解析(" + unit + ")", "平均TCP连接(" + unit + ")", "平均TLS握手(" + unit + ")", "平均TTFB(" + unit + ")",
 	}
 
 	// 添加百分位表头
 	for _, p := range allPercentiles {
-		headers = append(headers, fmt.Sprintf("P%d(ms)", p))
+		headers = append(headers, fmt.Sprintf("P%d(%s)", p, unit))
 	}
 
 	if err := writer.Write(headers); err != nil {
@@ -204,23 +815,54 @@ func (r *Reporter) generateCSVReport(results map[string]*engine.TestResult) (str
 
 		row := []string{
 			result.ModelName,
+			result.Scenario,
+			result.StreamMode,
 			fmt.Sprintf("%d", result.ConcurrencyLevel),
-			fmt.Sprintf("%d", result.AvgLatency.Milliseconds()),
+			fmt.Sprintf("%d", result.ConcurrencyCap),
+			fmt.Sprintf("%d", result.PeakConcurrency),
+			fmt.Sprintf("%.2f", result.AvgConcurrency),
+			r.latency.csvValue(result.AvgLatency),
+			r.latency.csvValue(result.AvgTimeToFirstToken),
+			r.latency.csvValue(result.MinLatency),
+			r.latency.csvValue(result.MaxLatency),
+			r.latency.csvValue(result.StdDevLatency),
 			fmt.Sprintf("%.2f", result.AvgInputTokens),
 			fmt.Sprintf("%.2f", result.AvgOutputTokens),
 			fmt.Sprintf("%.2f", result.AvgTotalTokens),
 			fmt.Sprintf("%.2f", result.RequestsPerSec),
 			fmt.Sprintf("%.2f", result.TokensPerSec),
+			fmt.Sprintf("%.2f", result.AvgResponseBytes),
+			fmt.Sprintf("%.2f", result.BytesPerSec),
 			fmt.Sprintf("%.2f", successRate),
 			fmt.Sprintf("%d", result.TotalRequests),
 			fmt.Sprintf("%d", result.SuccessRequests),
 			fmt.Sprintf("%d", result.FailedRequests),
+			fmt.Sprintf("%d", result.EstimatedTokenRequests),
+			fmt.Sprintf("%d", result.StoppedRequests),
+			fmt.Sprintf("%d", result.TruncatedRequests),
+			fmt.Sprintf("%d", result.NearTimeoutRequests),
+			fmt.Sprintf("%d", result.ToolCallRequests),
+			fmt.Sprintf("%d", result.SchemaValidRequests),
+			fmt.Sprintf("%d", result.SchemaInvalidRequests),
+			fmt.Sprintf("%d", result.DiscardedRequests),
+			fmt.Sprintf("%d", result.RetriedRequests),
+			fmt.Sprintf("%d", rateLimitedRequests(result)),
+			fmt.Sprintf("%.2f", result.AvgReasoningTokens),
+			fmt.Sprintf("%.2f", result.AvgCacheCreationInputTokens),
+			fmt.Sprintf("%.2f", result.AvgCacheReadInputTokens),
+			fmt.Sprintf("%.4f", result.TotalCost),
+			fmt.Sprintf("%.6f", result.AvgCostPerRequest),
+			fmt.Sprintf("%d", result.NewConnRequests),
+			r.latency.csvValue(result.AvgDNSTime),
+			r.latency.csvValue(result.AvgConnectTime),
+			r.latency.csvValue(result.AvgTLSTime),
+			r.latency.csvValue(result.AvgTTFB),
 		}
 
 		// 添加百分位数据
 		for _, p := range allPercentiles {
 			if latency, ok := result.LatencyPercentiles[p]; ok {
-				row = append(row, fmt.Sprintf("%d", latency.Milliseconds()))
+				row = append(row, r.latency.csvValue(latency))
 			} else {
 				row = append(row, "-")
 			}
@@ -239,38 +881,126 @@ func (r *Reporter) generateCSVReport(results map[string]*engine.TestResult) (str
 	return sb.String(), nil
 }
 
-// 生成JSON格式报告
-func (r *Reporter) generateJSONReport(results map[string]*engine.TestResult) (string, error) {
-	// 创建一个结构化的JSON报告
-	type LatencyPercentile struct {
-		Percentile int   `json:"percentile"`
-		LatencyMs  int64 `json:"latency_ms"`
-	}
+// LatencyPercentile JSON报告中的延迟百分位
+type LatencyPercentile struct {
+	Percentile int     `json:"percentile"`
+	LatencyMs  float64 `json:"latency_ms"`
+}
 
-	type ResultRecord struct {
-		ModelName        string              `json:"model_name"`
-		ConcurrencyLevel int                 `json:"concurrency"`
-		AvgLatencyMs     int64               `json:"avg_latency_ms"`
-		AvgInputTokens   float64             `json:"avg_input_tokens"`
-		AvgOutputTokens  float64             `json:"avg_output_tokens"`
-		AvgTotalTokens   float64             `json:"avg_total_tokens"`
-		RequestsPerSec   float64             `json:"requests_per_sec"`
-		TokensPerSec     float64             `json:"tokens_per_sec"`
-		SuccessRate      float64             `json:"success_rate"`
-		TotalRequests    int                 `json:"total_requests"`
-		SuccessRequests  int                 `json:"success_requests"`
-		FailedRequests   int                 `json:"failed_requests"`
-		Percentiles      []LatencyPercentile `json:"percentiles,omitempty"`
-	}
+// TTFTPercentile JSON报告中的首Token时间百分位
+type TTFTPercentile struct {
+	Percentile int     `json:"percentile"`
+	TTFTMs     float64 `json:"ttft_ms"`
+}
 
-	type Report struct {
-		TestResults []*ResultRecord `json:"test_results"`
-	}
+// HistogramBucket JSON报告中的延迟直方图桶，le 为桶上界（例如"100ms"），超过最后一个上界的桶为"+Inf"
+type HistogramBucket struct {
+	UpperBound string `json:"le"`
+	Count      int    `json:"count"`
+}
 
+// ResultRecord JSON报告中单个模型/并发度的测试结果
+type ResultRecord struct {
+	ModelName        string  `json:"model_name"`
+	Scenario         string  `json:"scenario,omitempty"`
+	StreamMode       string  `json:"stream_mode,omitempty"`
+	ConcurrencyLevel int     `json:"concurrency"`
+	IsKnee           bool    `json:"knee,omitempty"`
+	ConcurrencyCap   int     `json:"concurrency_cap,omitempty"`
+	PeakConcurrency  int     `json:"peak_concurrency"`
+	AvgConcurrency   float64 `json:"avg_concurrency"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms"`
+	AvgTTFTMs        float64 `json:"avg_ttft_ms,omitempty"`
+	MinLatencyMs     float64 `json:"min_latency_ms"`
+	MaxLatencyMs     float64 `json:"max_latency_ms"`
+	StdDevLatencyMs  float64 `json:"stddev_latency_ms"`
+	AvgInputTokens   float64 `json:"avg_input_tokens"`
+	AvgOutputTokens  float64 `json:"avg_output_tokens"`
+	AvgTotalTokens   float64 `json:"avg_total_tokens"`
+	RequestsPerSec   float64 `json:"requests_per_sec"`
+	TokensPerSec     float64 `json:"tokens_per_sec"`
+	// 响应体字节数统计，不依赖各服务商的Token统计
+	ResponseBytes    int64   `json:"response_bytes"`
+	AvgResponseBytes float64 `json:"avg_response_bytes"`
+	BytesPerSec      float64 `json:"bytes_per_sec"`
+	SuccessRate      float64 `json:"success_rate"`
+	TotalRequests    int     `json:"total_requests"`
+	SuccessRequests  int     `json:"success_requests"`
+	FailedRequests   int     `json:"failed_requests"`
+	// Token数为估算值的成功请求数
+	EstimatedTokenRequests int `json:"estimated_token_requests"`
+	// finish_reason为stop的请求数
+	StoppedRequests int `json:"stopped_requests"`
+	// finish_reason为length的请求数
+	TruncatedRequests int `json:"truncated_requests"`
+	// 延迟接近请求超时时间（超过 near_timeout_ratio）的成功请求数
+	NearTimeoutRequests int `json:"near_timeout_requests,omitempty"`
+	// 返回工具调用的成功请求数
+	ToolCallRequests int `json:"tool_call_requests,omitempty"`
+	// 场景配置了 response_schema 时响应内容满足和不满足Schema的成功请求数，以及前几个校验错误
+	SchemaValidRequests   int      `json:"schema_valid_requests,omitempty"`
+	SchemaInvalidRequests int      `json:"schema_invalid_requests,omitempty"`
+	SchemaErrors          []string `json:"schema_errors,omitempty"`
+	// 推理模型的推理Token数（包含在输出Token中），非推理模型省略
+	ReasoningTokens    int64   `json:"reasoning_tokens,omitempty"`
+	AvgReasoningTokens float64 `json:"avg_reasoning_tokens,omitempty"`
+	// 流式成功请求的首Token时间百分位和平均单请求输出速率（token/s），非流式时省略
+	TTFTPercentiles    []TTFTPercentile `json:"ttft_percentiles,omitempty"`
+	AvgTokensPerSecond float64          `json:"avg_tokens_per_second,omitempty"`
+	// 请求使用的response_format类型
+	ResponseFormat string `json:"response_format,omitempty"`
+	// 按 discard_first_n 丢弃指标的成功请求数
+	DiscardedRequests int `json:"discarded_requests,omitempty"`
+	// 因测试中断或达到总时长上限未完整运行
+	Incomplete bool `json:"incomplete,omitempty"`
+	// 模型测试出错时的错误信息，不为空时其他指标无效
+	ModelError string `json:"model_error,omitempty"`
+	// 按Token价格估算的费用，未配置价格时省略
+	TotalCost         float64             `json:"total_cost,omitempty"`
+	AvgCostPerRequest float64             `json:"avg_cost_per_request,omitempty"`
+	Percentiles       []LatencyPercentile `json:"percentiles,omitempty"`
+	LatencyHistogram  []HistogramBucket   `json:"latency_histogram,omitempty"`
+	ErrorsByCategory  map[string]int      `json:"errors_by_category,omitempty"`
+	// 出现次数最多的不同错误及次数，没有失败请求时省略
+	TopErrors       []ErrorCountRecord `json:"top_errors,omitempty"`
+	SampleResponses []string           `json:"sample_responses,omitempty"`
+	// 吞吐量收敛检测结果，未配置收敛检测时省略
+	Convergence *ConvergenceRecord `json:"convergence,omitempty"`
+	// 限流或服务端错误后的重试次数
+	RetriedRequests int `json:"retried_requests,omitempty"`
+	// 限流信息，服务端没有返回限流信息且没有被限流时省略
+	RateLimit *RateLimitRecord `json:"rate_limit,omitempty"`
+	// 提示词缓存Token统计，服务端没有返回缓存Token数时省略
+	PromptCache *PromptCacheRecord `json:"prompt_cache,omitempty"`
+	// 配置了多个API密钥时每个密钥的请求统计，只有一个密钥时省略
+	APIKeys []APIKeyRecord `json:"api_keys,omitempty"`
+	// 配置了代理池时每个代理的请求统计，未配置代理池时省略
+	Proxies []ProxyRecord `json:"proxies,omitempty"`
+	// 连接建立耗时和TTFB，模型没有记录连接耗时（例如模拟模型）时省略
+	Connection *ConnectionRecord `json:"connection,omitempty"`
+	// 配置了 prompt_length 时按输入Token数区间的延迟统计，未配置时省略
+	InputLengths []InputLengthRecord `json:"input_lengths,omitempty"`
+}
+
+// JSONReport JSON格式报告的整体结构
+type JSONReport struct {
+	Metadata    *MetadataRecord `json:"metadata,omitempty"`
+	TestResults []*ResultRecord `json:"test_results"`
+}
+
+// 生成JSON格式报告
+func (r *Reporter) generateJSONReport(results map[string]*engine.TestResult, metadata *RunMetadata) (string, error) {
 	// 填充报告
-	report := Report{
+	report := JSONReport{
 		TestResults: make([]*ResultRecord, 0),
 	}
+	if metadata != nil {
+		record, err := newMetadataRecord(metadata)
+		if err != nil {
+			return "", err
+		}
+		report.Metadata = record
+	}
 
 	// 收集所有测试结果
 	allResults := make([]*engine.TestResult, 0, len(results))
@@ -278,55 +1008,12 @@ func (r *Reporter) generateJSONReport(results map[string]*engine.TestResult) (st
 		allResults = append(allResults, result)
 	}
 
-	// 按模型名称和并发度排序
-	sort.Slice(allResults, func(i, j int) bool {
-		if allResults[i].ModelName != allResults[j].ModelName {
-			return allResults[i].ModelName < allResults[j].ModelName
-		}
-		return allResults[i].ConcurrencyLevel < allResults[j].ConcurrencyLevel
-	})
+	// 按模型名称、场景和并发度排序
+	sortResults(allResults)
 
 	// 添加所有测试结果
 	for _, result := range allResults {
-		// 计算成功率，防止除以零
-		successRate := 0.0
-		if result.TotalRequests > 0 {
-			successRate = float64(result.SuccessRequests) / float64(result.TotalRequests)
-		}
-
-		// 创建百分位数据
-		percentiles := make([]LatencyPercentile, 0)
-		if result.LatencyPercentiles != nil {
-			for p, latency := range result.LatencyPercentiles {
-				percentiles = append(percentiles, LatencyPercentile{
-					Percentile: p,
-					LatencyMs:  latency.Milliseconds(),
-				})
-			}
-
-			// 按百分位排序
-			sort.Slice(percentiles, func(i, j int) bool {
-				return percentiles[i].Percentile < percentiles[j].Percentile
-			})
-		}
-
-		resultRecord := &ResultRecord{
-			ModelName:        result.ModelName,
-			ConcurrencyLevel: result.ConcurrencyLevel,
-			AvgLatencyMs:     result.AvgLatency.Milliseconds(),
-			AvgInputTokens:   result.AvgInputTokens,
-			AvgOutputTokens:  result.AvgOutputTokens,
-			AvgTotalTokens:   result.AvgTotalTokens,
-			RequestsPerSec:   result.RequestsPerSec,
-			TokensPerSec:     result.TokensPerSec,
-			SuccessRate:      successRate,
-			TotalRequests:    result.TotalRequests,
-			SuccessRequests:  result.SuccessRequests,
-			FailedRequests:   result.FailedRequests,
-			Percentiles:      percentiles,
-		}
-
-		report.TestResults = append(report.TestResults, resultRecord)
+		report.TestResults = append(report.TestResults, newResultRecord(result, r.latency))
 	}
 
 	// 序列化为JSON
@@ -338,13 +1025,107 @@ func (r *Reporter) generateJSONReport(results map[string]*engine.TestResult) (st
 	return string(jsonData), nil
 }
 
-// 格式化持续时间
-func formatDuration(d time.Duration) string {
-	if d < time.Millisecond {
-		return fmt.Sprintf("%.2f µs", float64(d.Microseconds()))
-	} else if d < time.Second {
-		return fmt.Sprintf("%.2f ms", float64(d.Milliseconds()))
-	} else {
-		return fmt.Sprintf("%.2f s", d.Seconds())
+// newResultRecord 将测试结果转换为JSON报告中的记录，延迟按 latency 的小数位数保留
+func newResultRecord(result *engine.TestResult, latency LatencyFormat) *ResultRecord {
+	// 计算成功率，防止除以零
+	successRate := 0.0
+	if result.TotalRequests > 0 {
+		successRate = float64(result.SuccessRequests) / float64(result.TotalRequests)
+	}
+
+	// 创建百分位数据
+	percentiles := make([]LatencyPercentile, 0)
+	if result.LatencyPercentiles != nil {
+		for p, value := range result.LatencyPercentiles {
+			percentiles = append(percentiles, LatencyPercentile{
+				Percentile: p,
+				LatencyMs:  latency.jsonMillis(value),
+			})
+		}
+
+		// 按百分位排序
+		sort.Slice(percentiles, func(i, j int) bool {
+			return percentiles[i].Percentile < percentiles[j].Percentile
+		})
+	}
+
+	// 首Token时间百分位，按百分位排序
+	var ttftPercentiles []TTFTPercentile
+	for p, ttft := range result.TTFTPercentiles {
+		ttftPercentiles = append(ttftPercentiles, TTFTPercentile{Percentile: p, TTFTMs: latency.jsonMillis(ttft)})
+	}
+	sort.Slice(ttftPercentiles, func(i, j int) bool {
+		return ttftPercentiles[i].Percentile < ttftPercentiles[j].Percentile
+	})
+
+	return &ResultRecord{
+		ModelName:              result.ModelName,
+		Scenario:               result.Scenario,
+		StreamMode:             result.StreamMode,
+		ConcurrencyLevel:       result.ConcurrencyLevel,
+		IsKnee:                 result.IsKnee,
+		ConcurrencyCap:         result.ConcurrencyCap,
+		PeakConcurrency:        result.PeakConcurrency,
+		AvgConcurrency:         result.AvgConcurrency,
+		AvgLatencyMs:           latency.jsonMillis(result.AvgLatency),
+		AvgTTFTMs:              latency.jsonMillis(result.AvgTimeToFirstToken),
+		MinLatencyMs:           latency.jsonMillis(result.MinLatency),
+		MaxLatencyMs:           latency.jsonMillis(result.MaxLatency),
+		StdDevLatencyMs:        latency.jsonMillis(result.StdDevLatency),
+		AvgInputTokens:         result.AvgInputTokens,
+		AvgOutputTokens:        result.AvgOutputTokens,
+		AvgTotalTokens:         result.AvgTotalTokens,
+		RequestsPerSec:         result.RequestsPerSec,
+		TokensPerSec:           result.TokensPerSec,
+		ResponseBytes:          result.ResponseBytes,
+		AvgResponseBytes:       result.AvgResponseBytes,
+		BytesPerSec:            result.BytesPerSec,
+		SuccessRate:            successRate,
+		TotalRequests:          result.TotalRequests,
+		SuccessRequests:        result.SuccessRequests,
+		FailedRequests:         result.FailedRequests,
+		EstimatedTokenRequests: result.EstimatedTokenRequests,
+		StoppedRequests:        result.StoppedRequests,
+		TruncatedRequests:      result.TruncatedRequests,
+		NearTimeoutRequests:    result.NearTimeoutRequests,
+		ToolCallRequests:       result.ToolCallRequests,
+		SchemaValidRequests:    result.SchemaValidRequests,
+		SchemaInvalidRequests:  result.SchemaInvalidRequests,
+		SchemaErrors:           result.SchemaErrors,
+		ReasoningTokens:        result.ReasoningTokens,
+		AvgReasoningTokens:     result.AvgReasoningTokens,
+		TTFTPercentiles:        ttftPercentiles,
+		AvgTokensPerSecond:     result.AvgTokensPerSecond,
+		ResponseFormat:         result.ResponseFormat,
+		DiscardedRequests:      result.DiscardedRequests,
+		Incomplete:             result.Incomplete,
+		ModelError:             result.ModelError,
+		TotalCost:              result.TotalCost,
+		AvgCostPerRequest:      result.AvgCostPerRequest,
+		Percentiles:            percentiles,
+		LatencyHistogram:       newHistogramBuckets(result.LatencyHistogram),
+		ErrorsByCategory:       result.ErrorsByCategory,
+		TopErrors:              newErrorCountRecords(result.TopErrors),
+		SampleResponses:        result.SampleResponses,
+		Convergence:            newConvergenceRecord(result),
+		RetriedRequests:        result.RetriedRequests,
+		RateLimit:              newRateLimitRecord(result.RateLimit),
+		PromptCache:            newPromptCacheRecord(result),
+		APIKeys:                newAPIKeyRecords(result.APIKeys),
+		Proxies:                newProxyRecords(result.Proxies),
+		Connection:             newConnectionRecord(result, latency),
+		InputLengths:           newInputLengthRecords(result.InputLengths, latency),
+	}
+}
+
+// formatBytes 将字节数格式化为 B、KB 或 MB
+func formatBytes(n float64) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%.0f B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.2f KB", n/1024)
+	default:
+		return fmt.Sprintf("%.2f MB", n/1024/1024)
 	}
 }
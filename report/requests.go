@@ -0,0 +1,76 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// generateRawCSVReport 生成逐请求的CSV报告，每个请求一行，便于在表格或pandas中自行统计
+// 需要在测试时开启 RecordRequests，否则结果中没有请求明细
+func (r *Reporter) generateRawCSVReport(results map[string]*engine.TestResult) (string, error) {
+	allResults := make([]*engine.TestResult, 0, len(results))
+	recorded := false
+	for _, result := range results {
+		allResults = append(allResults, result)
+		if len(result.Requests) > 0 {
+			recorded = true
+		}
+	}
+	if !recorded && len(allResults) > 0 {
+		return "", fmt.Errorf("测试结果中没有逐请求记录，需要开启 test.record_requests")
+	}
+	sortResults(allResults)
+
+	var sb strings.Builder
+	writer := csv.NewWriter(&sb)
+
+	// 列名使用英文，便于直接作为DataFrame的列名
+	headers := []string{
+		"model", "scenario", "stream_mode", "concurrency", "start_time",
+		"latency_ms", "ttft_ms", "input_tokens", "output_tokens", "success", "error_category",
+	}
+	if err := writer.Write(headers); err != nil {
+		return "", fmt.Errorf("写入CSV表头失败: %w", err)
+	}
+
+	for _, result := range allResults {
+		// 引擎按完成顺序保存，报告中按开始时间排列
+		requests := slices.Clone(result.Requests)
+		sort.SliceStable(requests, func(i, j int) bool { return requests[i].StartTime.Before(requests[j].StartTime) })
+		for _, request := range requests {
+			row := []string{
+				result.ModelName,
+				result.Scenario,
+				result.StreamMode,
+				fmt.Sprintf("%d", result.ConcurrencyLevel),
+				request.StartTime.Format(time.RFC3339Nano),
+				formatMillis(request.Latency),
+				formatMillis(request.TimeToFirstToken),
+				fmt.Sprintf("%d", request.InputTokens),
+				fmt.Sprintf("%d", request.OutputTokens),
+				fmt.Sprintf("%t", request.Success),
+				request.ErrorCategory,
+			}
+			if err := writer.Write(row); err != nil {
+				return "", fmt.Errorf("写入CSV数据失败: %w", err)
+			}
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", fmt.Errorf("刷新CSV写入器失败: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// formatMillis 将时长格式化为保留三位小数的毫秒数
+func formatMillis(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d)/float64(time.Millisecond))
+}
@@ -0,0 +1,228 @@
+package report
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lemonlinger/llm-test/config"
+)
+
+// 报告中提示词摘要的最大字符数
+const promptSummaryLength = 60
+
+// writeSettings 写入测试设置：时长、超时、预热、百分位、流式设置、使用的代理和提示词摘要
+func writeSettings(sb *strings.Builder, cfg *config.Config) {
+	if cfg == nil {
+		sb.WriteString("未提供测试配置\n\n")
+		return
+	}
+	test := cfg.Test
+
+	switch {
+	case test.AutoSweep != nil:
+		sb.WriteString(fmt.Sprintf("- 并发度: 自动扫描，从 %d 开始，最大 %d\n", test.Concurrency, test.AutoSweep.MaxConcurrency))
+	case len(test.ConcurrencyLevels) > 0:
+		sb.WriteString(fmt.Sprintf("- 并发度: %s\n", joinInts(test.ConcurrencyLevels)))
+	default:
+		sb.WriteString(fmt.Sprintf("- 并发度: %d\n", test.Concurrency))
+	}
+	if len(test.TrafficMix) > 0 {
+		mix := make([]string, len(test.TrafficMix))
+		for i, entry := range test.TrafficMix {
+			mix[i] = fmt.Sprintf("%s(权重%d)", entry.Model, entry.Weight)
+		}
+		sb.WriteString(fmt.Sprintf("- 流量混合: %s\n", strings.Join(mix, ", ")))
+	}
+
+	if test.TotalRequests > 0 {
+		sb.WriteString(fmt.Sprintf("- 每个并发度的请求数: %d\n", test.TotalRequests))
+	} else {
+		sb.WriteString(fmt.Sprintf("- 每个并发度的测试时长: %s\n", test.Duration))
+		if len(test.DurationPerLevel) > 0 {
+			levels := make([]int, 0, len(test.DurationPerLevel))
+			for level := range test.DurationPerLevel {
+				levels = append(levels, level)
+			}
+			sort.Ints(levels)
+			overrides := make([]string, len(levels))
+			for i, level := range levels {
+				overrides[i] = fmt.Sprintf("%d=%s", level, test.DurationPerLevel[level])
+			}
+			sb.WriteString(fmt.Sprintf("- 按并发度覆盖的测试时长: %s\n", strings.Join(overrides, ", ")))
+		}
+	}
+	if test.MaxTotalDuration > 0 {
+		sb.WriteString(fmt.Sprintf("- 总时长上限: %s\n", test.MaxTotalDuration))
+	}
+	sb.WriteString(fmt.Sprintf("- 预热时间: %s\n", test.WarmupDuration))
+	sb.WriteString(fmt.Sprintf("- 请求超时: %s\n", test.RequestTimeout))
+	if test.DispatchJitter > 0 {
+		sb.WriteString(fmt.Sprintf("- 派发抖动: [0, %s)\n", test.DispatchJitter))
+	}
+	if len(test.LatencyPercentiles) > 0 {
+		percentiles := make([]string, len(test.LatencyPercentiles))
+		for i, p := range test.LatencyPercentiles {
+			percentiles[i] = fmt.Sprintf("P%d", p)
+		}
+		mode := "精确计算"
+		if test.StreamingPercentiles {
+			mode = "t-digest估算"
+		}
+		sb.WriteString(fmt.Sprintf("- 延迟百分位: %s（%s）\n", strings.Join(percentiles, ", "), mode))
+	}
+	if convergence := test.Convergence; convergence != nil {
+		sb.WriteString(fmt.Sprintf("- 吞吐量收敛检测: 窗口 %s，连续 %d 个窗口变异系数不超过 %.2f\n",
+			convergence.Window, convergence.Windows, convergence.MaxCV))
+	}
+	sb.WriteString(fmt.Sprintf("- 随机种子: %d\n", test.RandomSeed))
+
+	// 模型单独设置了流式输出时覆盖场景的设置
+	var streamOverrides []string
+	for _, mdl := range cfg.Models {
+		if !mdl.Skip && mdl.Stream != nil {
+			streamOverrides = append(streamOverrides, fmt.Sprintf("%s=%v", mdl.Name, *mdl.Stream))
+		}
+	}
+	if len(streamOverrides) > 0 {
+		sb.WriteString(fmt.Sprintf("- 模型流式设置: %s\n", strings.Join(streamOverrides, ", ")))
+	}
+
+	if cfg.TLS.InsecureSkipVerify {
+		sb.WriteString("- TLS: 不校验服务端证书\n")
+	} else if cfg.TLS.CACertFile != "" || cfg.TLS.ClientCertFile != "" {
+		sb.WriteString(fmt.Sprintf("- TLS: CA证书=%s, 客户端证书=%s\n", orDash(cfg.TLS.CACertFile), orDash(cfg.TLS.ClientCertFile)))
+	}
+
+	var localAddrs []string
+	for _, mdl := range cfg.Models {
+		if !mdl.Skip && mdl.HTTP.LocalAddr != "" {
+			localAddrs = append(localAddrs, fmt.Sprintf("%s=%s", mdl.Name, mdl.HTTP.LocalAddr))
+		}
+	}
+	if len(localAddrs) > 0 {
+		sb.WriteString(fmt.Sprintf("- 本地地址: %s\n", strings.Join(localAddrs, ", ")))
+	}
+
+	// 只列出被模型使用的代理，代理地址中的密码不输出
+	proxies := make(map[string]string, len(cfg.Proxies))
+	for _, proxy := range cfg.Proxies {
+		if proxyURL, err := proxy.ProxyURL(); err == nil {
+			proxies[proxy.Name] = proxyURL.Redacted()
+		}
+	}
+	var usedProxies []string
+	for _, mdl := range cfg.Models {
+		if mdl.Skip {
+			continue
+		}
+		if mdl.ProxyName != "" {
+			usedProxies = append(usedProxies, fmt.Sprintf("%s → %s (%s)", mdl.Name, mdl.ProxyName, proxies[mdl.ProxyName]))
+		}
+		// 代理池中的代理按配置顺序列出，请求轮流使用
+		if len(mdl.ProxyNames) > 0 {
+			pool := make([]string, len(mdl.ProxyNames))
+			for i, name := range mdl.ProxyNames {
+				pool[i] = fmt.Sprintf("%s (%s)", name, proxies[name])
+			}
+			usedProxies = append(usedProxies, fmt.Sprintf("%s → 轮流使用 [%s]", mdl.Name, strings.Join(pool, ", ")))
+		}
+	}
+	if len(usedProxies) > 0 {
+		sb.WriteString(fmt.Sprintf("- 代理: %s\n", strings.Join(usedProxies, ", ")))
+	} else {
+		sb.WriteString("- 代理: 无\n")
+	}
+	sb.WriteString("\n")
+
+	// 提示词摘要，有场景带图片时增加图片列
+	showImages := slices.ContainsFunc(cfg.Prompts, func(prompt config.PromptConfig) bool { return len(prompt.Images) > 0 })
+	if showImages {
+		sb.WriteString("| 场景 | 流式 | 系统消息 | 用户消息 | 工具调用 | 图片 |\n")
+		sb.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	} else {
+		sb.WriteString("| 场景 | 流式 | 系统消息 | 用户消息 | 工具调用 |\n")
+		sb.WriteString("| --- | --- | --- | --- | --- |\n")
+	}
+	for _, prompt := range cfg.Prompts {
+		name := prompt.Name
+		if name == "" {
+			name = "-"
+		}
+		tools := "否"
+		if prompt.Tools != "" {
+			tools = "是"
+		}
+		systemSummary, userSummary := summarizePrompt(prompt.SystemMessage), summarizePrompt(prompt.UserMessage)
+		if len(prompt.Messages) > 0 {
+			systemSummary, userSummary = summarizeMessages(prompt.Messages)
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %v | %s | %s | %s |",
+			name, prompt.Stream, systemSummary, userSummary, tools))
+		if showImages {
+			sb.WriteString(fmt.Sprintf(" %s |", summarizeImages(prompt.Images)))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+}
+
+// summarizePrompt 返回提示词的单行摘要，过长时截断并注明总字符数
+func summarizePrompt(text string) string {
+	if text == "" {
+		return "-"
+	}
+	summary := strings.Join(strings.Fields(text), " ")
+	if n := utf8.RuneCountInString(summary); n > promptSummaryLength {
+		summary = fmt.Sprintf("%s...（共%d字符）", string([]rune(summary)[:promptSummaryLength]), utf8.RuneCountInString(text))
+	}
+	return strings.ReplaceAll(summary, "|", "\\|")
+}
+
+// summarizeMessages 返回多轮对话的摘要：第一条系统消息，以及最后一条用户消息和对话的消息总数
+func summarizeMessages(messages []config.MessageConfig) (string, string) {
+	var system, user string
+	for _, message := range messages {
+		switch {
+		case message.Role == "system" && system == "":
+			system = message.Content
+		case message.Role == "user":
+			user = message.Content
+		}
+	}
+	return summarizePrompt(system), fmt.Sprintf("%s（对话共%d条消息）", summarizePrompt(user), len(messages))
+}
+
+// summarizeImages 返回场景中图片的张数和base64图片数据的总大小
+func summarizeImages(images []config.ImageConfig) string {
+	if len(images) == 0 {
+		return "-"
+	}
+	size := 0
+	for _, image := range images {
+		size += image.Size()
+	}
+	if size == 0 {
+		return fmt.Sprintf("%d张", len(images))
+	}
+	return fmt.Sprintf("%d张（内联数据%.1fKB）", len(images), float64(size)/1024)
+}
+
+// orDash 返回字符串本身，为空时返回"-"
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// joinInts 将整数列表格式化为逗号分隔的字符串
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%d", v)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,148 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// DefaultTimelineInterval 时间序列报告默认的统计窗口
+const DefaultTimelineInterval = time.Second
+
+// SetTimelineInterval 设置时间序列报告的统计窗口，未设置或不大于0时使用 DefaultTimelineInterval
+func (r *Reporter) SetTimelineInterval(interval time.Duration) {
+	r.timelineInterval = interval
+}
+
+// timelineWindow 一个统计窗口内开始的请求
+type timelineWindow struct {
+	requests     int
+	failed       int
+	latencies    []time.Duration // 成功请求的延迟
+	ttftTotal    time.Duration
+	ttftCount    int
+	outputTokens int
+}
+
+// generateTimelineCSVReport 生成时间序列CSV报告，按请求的开始时间把每个测试结果的请求划分到固定长度的窗口，
+// 每个窗口一行，用于绘制延迟随时间变化的曲线，观察测试过程中是否出现限流等导致的性能下降
+// 与逐请求的CSV报告一样需要在测试时开启 RecordRequests
+func (r *Reporter) generateTimelineCSVReport(results map[string]*engine.TestResult) (string, error) {
+	interval := r.timelineInterval
+	if interval <= 0 {
+		interval = DefaultTimelineInterval
+	}
+
+	allResults := make([]*engine.TestResult, 0, len(results))
+	recorded := false
+	for _, result := range results {
+		allResults = append(allResults, result)
+		if len(result.Requests) > 0 {
+			recorded = true
+		}
+	}
+	if !recorded && len(allResults) > 0 {
+		return "", fmt.Errorf("测试结果中没有逐请求记录，需要开启 test.record_requests")
+	}
+	sortResults(allResults)
+
+	var sb strings.Builder
+	writer := csv.NewWriter(&sb)
+
+	// 列名使用英文，与逐请求的CSV报告一致
+	headers := []string{
+		"model", "scenario", "stream_mode", "concurrency", "window_start", "elapsed_s",
+		"requests", "success", "failed", "avg_latency_ms", "p50_latency_ms", "p95_latency_ms", "max_latency_ms",
+		"avg_ttft_ms", "output_tokens_per_sec",
+	}
+	if err := writer.Write(headers); err != nil {
+		return "", fmt.Errorf("写入CSV表头失败: %w", err)
+	}
+
+	for _, result := range allResults {
+		if len(result.Requests) == 0 {
+			continue
+		}
+		// 以最早开始的请求作为时间零点，中间没有请求开始的窗口也输出一行，保证时间轴连续
+		start := result.Requests[0].StartTime
+		for _, request := range result.Requests {
+			if request.StartTime.Before(start) {
+				start = request.StartTime
+			}
+		}
+		var windows []timelineWindow
+		for _, request := range result.Requests {
+			index := int(request.StartTime.Sub(start) / interval)
+			for len(windows) <= index {
+				windows = append(windows, timelineWindow{})
+			}
+			window := &windows[index]
+			window.requests++
+			if !request.Success {
+				window.failed++
+				continue
+			}
+			window.latencies = append(window.latencies, request.Latency)
+			window.outputTokens += request.OutputTokens
+			if request.TimeToFirstToken > 0 {
+				window.ttftTotal += request.TimeToFirstToken
+				window.ttftCount++
+			}
+		}
+
+		for i, window := range windows {
+			windowStart := start.Add(time.Duration(i) * interval)
+			row := []string{
+				result.ModelName,
+				result.Scenario,
+				result.StreamMode,
+				fmt.Sprintf("%d", result.ConcurrencyLevel),
+				windowStart.Format(time.RFC3339Nano),
+				fmt.Sprintf("%.3f", windowStart.Sub(start).Seconds()),
+				fmt.Sprintf("%d", window.requests),
+				fmt.Sprintf("%d", window.requests-window.failed),
+				fmt.Sprintf("%d", window.failed),
+			}
+			// 窗口内没有成功请求时延迟列为空，便于绘图时作为缺失值处理
+			if len(window.latencies) > 0 {
+				slices.Sort(window.latencies)
+				var total time.Duration
+				for _, latency := range window.latencies {
+					total += latency
+				}
+				row = append(row,
+					formatMillis(total/time.Duration(len(window.latencies))),
+					formatMillis(nearestRank(window.latencies, 50)),
+					formatMillis(nearestRank(window.latencies, 95)),
+					formatMillis(window.latencies[len(window.latencies)-1]),
+				)
+			} else {
+				row = append(row, "", "", "", "")
+			}
+			if window.ttftCount > 0 {
+				row = append(row, formatMillis(window.ttftTotal/time.Duration(window.ttftCount)))
+			} else {
+				row = append(row, "")
+			}
+			row = append(row, fmt.Sprintf("%.2f", float64(window.outputTokens)/interval.Seconds()))
+			if err := writer.Write(row); err != nil {
+				return "", fmt.Errorf("写入CSV数据失败: %w", err)
+			}
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", fmt.Errorf("刷新CSV写入器失败: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// nearestRank 返回已排序延迟的百分位，与测试引擎计算百分位的方法相同
+func nearestRank(sorted []time.Duration, percentile int) time.Duration {
+	return sorted[int(float64(len(sorted)-1)*float64(percentile)/100.0)]
+}
@@ -0,0 +1,85 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// LatencyFormat 报告中延迟的单位和小数位数
+// Unit 为空时文本报告按大小自动选择µs、ms或s，CSV和JSON报告使用整数毫秒；
+// 设置 Unit 后文本和CSV报告统一使用该单位，JSON报告的字段仍以毫秒为单位，按 Precision 保留小数
+type LatencyFormat struct {
+	Unit      string // 空、us、ms 或 s
+	Precision int    // 小数位数
+}
+
+// DefaultLatencyFormat 默认的延迟格式：自动选择单位，保留两位小数
+var DefaultLatencyFormat = LatencyFormat{Precision: 2}
+
+// latencyUnits 支持的延迟单位，µs 可以写作 us
+var latencyUnits = map[string]struct {
+	name  string
+	scale time.Duration
+}{
+	"us": {"µs", time.Microsecond},
+	"µs": {"µs", time.Microsecond},
+	"ms": {"ms", time.Millisecond},
+	"s":  {"s", time.Second},
+}
+
+// ParseLatencyFormat 解析延迟单位和小数位数，unit 为空或 auto 时自动选择单位
+func ParseLatencyFormat(unit string, precision int) (LatencyFormat, error) {
+	if unit == "auto" {
+		unit = ""
+	}
+	if _, ok := latencyUnits[unit]; unit != "" && !ok {
+		return LatencyFormat{}, fmt.Errorf("不支持的延迟单位: %s，支持 auto、us、ms、s", unit)
+	}
+	if precision < 0 || precision > 6 {
+		return LatencyFormat{}, fmt.Errorf("延迟的小数位数必须在0到6之间: %d", precision)
+	}
+	return LatencyFormat{Unit: unit, Precision: precision}, nil
+}
+
+// Format 返回文本报告中带单位的延迟
+func (f LatencyFormat) Format(d time.Duration) string {
+	unit, ok := latencyUnits[f.Unit]
+	if !ok {
+		switch {
+		case d < time.Millisecond:
+			unit = latencyUnits["us"]
+		case d < time.Second:
+			unit = latencyUnits["ms"]
+		default:
+			unit = latencyUnits["s"]
+		}
+	}
+	return fmt.Sprintf("%.*f %s", f.Precision, float64(d)/float64(unit.scale), unit.name)
+}
+
+// csvUnit 返回CSV报告中延迟列的单位，自动选择单位时为ms
+func (f LatencyFormat) csvUnit() string {
+	if unit, ok := latencyUnits[f.Unit]; ok {
+		return unit.name
+	}
+	return "ms"
+}
+
+// csvValue 返回CSV报告中的延迟数值，单位为 csvUnit
+func (f LatencyFormat) csvValue(d time.Duration) string {
+	unit, ok := latencyUnits[f.Unit]
+	if !ok {
+		return fmt.Sprintf("%d", d.Milliseconds())
+	}
+	return fmt.Sprintf("%.*f", f.Precision, float64(d)/float64(unit.scale))
+}
+
+// jsonMillis 返回JSON报告中以毫秒为单位的延迟，自动选择单位时取整
+func (f LatencyFormat) jsonMillis(d time.Duration) float64 {
+	if f.Unit == "" {
+		return float64(d.Milliseconds())
+	}
+	scale := math.Pow10(f.Precision)
+	return math.Round(float64(d)/float64(time.Millisecond)*scale) / scale
+}
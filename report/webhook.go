@@ -0,0 +1,234 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/lemonlinger/llm-test/config"
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// 测试摘要中的运行状态
+const (
+	WebhookStatusCompleted   = "completed"   // 所有测试正常完成
+	WebhookStatusInterrupted = "interrupted" // 测试被中断，只包含已完成部分的结果
+	WebhookStatusFailed      = "failed"      // 测试完成，但有结果未达到性能门槛
+)
+
+// WebhookSummary 测试完成后发送给Webhook的摘要，也是自定义模板的数据
+// Text 为可读的摘要文本，Slack的Incoming Webhook直接显示该字段，不需要配置模板
+type WebhookSummary struct {
+	Status              string          `json:"status"`
+	Text                string          `json:"text"`
+	StartTime           time.Time       `json:"start_time"`
+	EndTime             time.Time       `json:"end_time"`
+	DurationSec         float64         `json:"duration_sec"`
+	Host                string          `json:"host,omitempty"`
+	ToolVersion         string          `json:"tool_version,omitempty"`
+	Results             []WebhookResult `json:"results"`
+	ThresholdViolations []string        `json:"threshold_violations,omitempty"`
+}
+
+// WebhookResult 测试摘要中一个（模型, 场景, 并发度）组合的结果
+// 未配置P99延迟百分位且没有延迟记录时 P99LatencyMs 为0
+type WebhookResult struct {
+	Model          string  `json:"model"`
+	Scenario       string  `json:"scenario,omitempty"`
+	StreamMode     string  `json:"stream_mode,omitempty"`
+	Concurrency    int     `json:"concurrency"`
+	TotalRequests  int     `json:"total_requests"`
+	FailedRequests int     `json:"failed_requests"`
+	SuccessRate    float64 `json:"success_rate"` // 百分比
+	RequestsPerSec float64 `json:"requests_per_sec"`
+	AvgLatencyMs   float64 `json:"avg_latency_ms"`
+	P99LatencyMs   float64 `json:"p99_latency_ms,omitempty"`
+	ModelError     string  `json:"model_error,omitempty"`
+}
+
+// WebhookNotifier 在测试完成后向配置的Webhook发送测试摘要
+type WebhookNotifier struct {
+	config   config.WebhookConfig
+	template *template.Template
+	client   *http.Client
+	latency  LatencyFormat
+}
+
+// NewWebhookNotifier 创建Webhook通知器，模板不合法时返回错误，应当在测试开始前调用以便尽早发现问题
+func NewWebhookNotifier(cfg config.WebhookConfig) (*WebhookNotifier, error) {
+	n := &WebhookNotifier{
+		config:  cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		latency: DefaultLatencyFormat,
+	}
+	if cfg.Template != "" {
+		tmpl, err := template.New("webhook").Funcs(template.FuncMap{"json": marshalJSON}).Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("解析 webhook 模板失败: %w", err)
+		}
+		n.template = tmpl
+	}
+	return n, nil
+}
+
+// SetLatencyFormat 设置摘要文本中延迟的单位和小数位数
+func (n *WebhookNotifier) SetLatencyFormat(latency LatencyFormat) {
+	n.latency = latency
+}
+
+// marshalJSON 模板函数 json，将值转换为JSON，用于在模板中安全地嵌入包含引号或换行的文本
+func marshalJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// Notify 发送测试摘要，violations 为性能门槛检查的结果；服务端返回非2xx状态码时返回错误
+func (n *WebhookNotifier) Notify(ctx context.Context, results map[string]*engine.TestResult, metadata *RunMetadata, violations []engine.ThresholdViolation) error {
+	summary := n.summarize(results, metadata, violations)
+
+	var body []byte
+	if n.template != nil {
+		var buf bytes.Buffer
+		if err := n.template.Execute(&buf, summary); err != nil {
+			return fmt.Errorf("生成 webhook 请求体失败: %w", err)
+		}
+		body = buf.Bytes()
+	} else {
+		data, err := json.Marshal(summary)
+		if err != nil {
+			return fmt.Errorf("序列化测试摘要失败: %w", err)
+		}
+		body = data
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建 webhook 请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range n.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送 webhook 请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook 返回状态码 %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// summarize 汇总测试结果，结果按模型名称、场景和并发度排序
+func (n *WebhookNotifier) summarize(results map[string]*engine.TestResult, metadata *RunMetadata, violations []engine.ThresholdViolation) *WebhookSummary {
+	allResults := make([]*engine.TestResult, 0, len(results))
+	for _, result := range results {
+		allResults = append(allResults, result)
+	}
+	sortResults(allResults)
+
+	summary := &WebhookSummary{
+		Status:  WebhookStatusCompleted,
+		Results: make([]WebhookResult, 0, len(allResults)),
+	}
+	if metadata != nil {
+		summary.StartTime = metadata.StartTime
+		summary.EndTime = metadata.EndTime
+		summary.DurationSec = metadata.EndTime.Sub(metadata.StartTime).Seconds()
+		summary.Host = metadata.Host
+		summary.ToolVersion = metadata.ToolVersion
+	}
+
+	var text strings.Builder
+	for _, result := range allResults {
+		if result.Incomplete {
+			summary.Status = WebhookStatusInterrupted
+		}
+		record := WebhookResult{
+			Model:          result.ModelName,
+			Scenario:       result.Scenario,
+			StreamMode:     result.StreamMode,
+			Concurrency:    result.ConcurrencyLevel,
+			TotalRequests:  result.TotalRequests,
+			FailedRequests: result.FailedRequests,
+			RequestsPerSec: result.RequestsPerSec,
+			AvgLatencyMs:   n.latency.jsonMillis(result.AvgLatency),
+			ModelError:     result.ModelError,
+		}
+		if result.TotalRequests > 0 {
+			record.SuccessRate = float64(result.SuccessRequests) / float64(result.TotalRequests) * 100
+		}
+		p99 := p99Latency(result)
+		if p99 > 0 {
+			record.P99LatencyMs = n.latency.jsonMillis(p99)
+		}
+		summary.Results = append(summary.Results, record)
+
+		name := result.ModelName
+		if result.Scenario != "" {
+			name += "/" + result.Scenario
+		}
+		if result.StreamMode != "" {
+			name += "/" + result.StreamMode
+		}
+		if result.ModelError != "" {
+			text.WriteString(fmt.Sprintf("• %s: 测试出错 %s\n", name, result.ModelError))
+			continue
+		}
+		p99Text := "-"
+		if p99 > 0 {
+			p99Text = n.latency.Format(p99)
+		}
+		text.WriteString(fmt.Sprintf("• %s 并发度 %d: RPS %.2f, P99 %s, 成功率 %.2f%%\n",
+			name, result.ConcurrencyLevel, result.RequestsPerSec, p99Text, record.SuccessRate))
+	}
+	for _, violation := range violations {
+		summary.ThresholdViolations = append(summary.ThresholdViolations, violation.String())
+	}
+	if len(violations) > 0 && summary.Status == WebhookStatusCompleted {
+		summary.Status = WebhookStatusFailed
+	}
+
+	title := "LLM性能测试完成"
+	switch summary.Status {
+	case WebhookStatusInterrupted:
+		title = "LLM性能测试被中断（仅包含已完成部分）"
+	case WebhookStatusFailed:
+		title = "LLM性能测试完成，性能门槛检查未通过"
+	}
+	if duration := time.Duration(summary.DurationSec * float64(time.Second)).Round(time.Second); duration > 0 {
+		title += fmt.Sprintf("，耗时 %s", duration)
+	}
+	if summary.Host != "" {
+		title += "，主机 " + summary.Host
+	}
+	summary.Text = title + "\n" + text.String()
+	if len(summary.ThresholdViolations) > 0 {
+		summary.Text += "未达到性能门槛:\n• " + strings.Join(summary.ThresholdViolations, "\n• ") + "\n"
+	}
+	summary.Text = strings.TrimSuffix(summary.Text, "\n")
+	return summary
+}
+
+// p99Latency 返回结果的P99延迟：优先使用已计算的百分位，未配置P99时根据延迟记录计算，都没有时返回0
+func p99Latency(result *engine.TestResult) time.Duration {
+	if p99, ok := result.LatencyPercentiles[99]; ok {
+		return p99
+	}
+	if len(result.AllLatencies) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(result.AllLatencies)
+	slices.Sort(sorted)
+	return nearestRank(sorted, 99)
+}
@@ -0,0 +1,217 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/lemonlinger/llm-test/engine"
+)
+
+// 已完成结果最多显示的行数，超过时只显示最近完成的结果
+const maxCompletedRows = 10
+
+// 控制终端的ANSI转义序列
+const (
+	enterAltScreen = "\033[?1049h\033[?25l" // 切换到备用屏幕并隐藏光标
+	leaveAltScreen = "\033[?25h\033[?1049l" // 显示光标并回到原来的屏幕
+	clearScreen    = "\033[H\033[2J"
+)
+
+// Dashboard 终端实时面板，在备用屏幕中显示正在测试的并发度的实时统计和已完成的结果
+// 面板每次更新都重绘整个屏幕，Stop 之后回到原来的屏幕，测试期间的其他输出不会保留
+type Dashboard struct {
+	out         io.Writer
+	percentiles []int
+	start       time.Time
+
+	mu        sync.Mutex
+	live      []engine.LiveStats
+	completed []*engine.TestResult
+}
+
+// New 创建实时面板，percentiles 为要显示的延迟百分位
+func New(out io.Writer, percentiles []int) *Dashboard {
+	return &Dashboard{out: out, percentiles: percentiles}
+}
+
+// IsTerminal 判断文件是否为终端，标准输出被重定向到文件或管道时返回false
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Start 切换到备用屏幕并显示面板
+func (d *Dashboard) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.start = time.Now()
+	fmt.Fprint(d.out, enterAltScreen)
+	d.render()
+}
+
+// Stop 回到原来的屏幕
+func (d *Dashboard) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fmt.Fprint(d.out, leaveAltScreen)
+}
+
+// Update 更新正在测试的并发度的实时统计，可以作为 TestEngine.SetLiveStatsCallback 的回调
+func (d *Dashboard) Update(stats []engine.LiveStats) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.live = stats
+	d.render()
+}
+
+// AddResult 添加一个已完成的结果，并从实时统计中移除对应的并发度
+func (d *Dashboard) AddResult(result *engine.TestResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.completed = append(d.completed, result)
+	live := d.live[:0:0]
+	for _, stats := range d.live {
+		if stats.ModelName != result.ModelName || stats.Scenario != result.Scenario ||
+			stats.ConcurrencyLevel != result.ConcurrencyLevel || stats.StreamMode != result.StreamMode {
+			live = append(live, stats)
+		}
+	}
+	d.live = live
+	d.render()
+}
+
+// render 重绘整个面板，调用方需要持有锁
+func (d *Dashboard) render() {
+	var sb strings.Builder
+	sb.WriteString(clearScreen)
+	sb.WriteString(fmt.Sprintf("LLM API 性能测试  已运行 %s  （Ctrl-C 停止并生成部分报告）\n\n", time.Since(d.start).Round(time.Second)))
+
+	sb.WriteString("正在测试\n")
+	if len(d.live) == 0 {
+		sb.WriteString("  -\n")
+	} else {
+		header := []string{"模型", "场景", "并发度", "进行中", "已完成", "成功率", "RPS"}
+		for _, p := range d.percentiles {
+			header = append(header, fmt.Sprintf("P%d", p))
+		}
+		rows := [][]string{header}
+		for _, stats := range d.live {
+			row := []string{
+				modelLabel(stats.ModelName, stats.StreamMode),
+				orDash(stats.Scenario),
+				fmt.Sprintf("%d", stats.ConcurrencyLevel),
+				fmt.Sprintf("%d", stats.InFlight),
+				fmt.Sprintf("%d", stats.Completed),
+				fmt.Sprintf("%.1f%%", stats.SuccessRate()),
+				fmt.Sprintf("%.2f", stats.RequestsPerSec),
+			}
+			for _, p := range d.percentiles {
+				row = append(row, formatLatency(stats.LatencyPercentiles, p))
+			}
+			rows = append(rows, row)
+		}
+		writeTable(&sb, rows)
+	}
+
+	sb.WriteString("\n已完成\n")
+	if len(d.completed) == 0 {
+		sb.WriteString("  -\n")
+	} else {
+		completed := d.completed
+		if len(completed) > maxCompletedRows {
+			sb.WriteString(fmt.Sprintf("  （共 %d 个，只显示最近 %d 个）\n", len(completed), maxCompletedRows))
+			completed = completed[len(completed)-maxCompletedRows:]
+		}
+		rows := [][]string{{"模型", "场景", "并发度", "成功/总请求", "成功率", "RPS", "TPS", "平均延迟", "P99"}}
+		for _, result := range completed {
+			if result.ModelError != "" {
+				rows = append(rows, []string{result.ModelName, "测试出错", "-", "-", "-", "-", "-", "-", "-"})
+				continue
+			}
+			successRate := 0.0
+			if result.TotalRequests > 0 {
+				successRate = float64(result.SuccessRequests) / float64(result.TotalRequests) * 100
+			}
+			rows = append(rows, []string{
+				modelLabel(result.ModelName, result.StreamMode),
+				orDash(result.Scenario),
+				fmt.Sprintf("%d", result.ConcurrencyLevel),
+				fmt.Sprintf("%d/%d", result.SuccessRequests, result.TotalRequests),
+				fmt.Sprintf("%.1f%%", successRate),
+				fmt.Sprintf("%.2f", result.RequestsPerSec),
+				fmt.Sprintf("%.2f", result.TokensPerSec),
+				result.AvgLatency.Round(time.Millisecond).String(),
+				formatLatency(result.LatencyPercentiles, 99),
+			})
+		}
+		writeTable(&sb, rows)
+	}
+
+	fmt.Fprint(d.out, sb.String())
+}
+
+// writeTable 按显示宽度对齐输出表格，第一行为表头
+func writeTable(sb *strings.Builder, rows [][]string) {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], displayWidth(cell))
+		}
+	}
+	for _, row := range rows {
+		sb.WriteString(" ")
+		for i, cell := range row {
+			sb.WriteString(" ")
+			sb.WriteString(cell)
+			sb.WriteString(strings.Repeat(" ", widths[i]-displayWidth(cell)))
+		}
+		sb.WriteString("\n")
+	}
+}
+
+// displayWidth 返回字符串在终端中的显示宽度，中日韩字符和全角符号占两列
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) ||
+			unicode.Is(unicode.Hangul, r) || (r >= 0x3000 && r <= 0x303f) || (r >= 0xff01 && r <= 0xff60) {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// modelLabel 返回模型名称，对比流式模式下加上模式
+func modelLabel(name, streamMode string) string {
+	if streamMode == "" {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, streamMode)
+}
+
+// formatLatency 返回指定百分位的延迟，没有该百分位时返回"-"
+func formatLatency(percentiles map[int]time.Duration, p int) string {
+	latency, ok := percentiles[p]
+	if !ok {
+		return "-"
+	}
+	return latency.Round(time.Millisecond).String()
+}
+
+// orDash 返回字符串本身，为空时返回"-"
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}